@@ -50,6 +50,11 @@ func (r *AddDeviceNotificationRequest) Decode(b *Buffer) error {
 	return b.Err()
 }
 
+// IsAddDeviceNotificationRequest returns true if the packet is an Add Device Notification request.
+func IsAddDeviceNotificationRequest(h AMSHeader) bool {
+	return h.CmdID == CmdADSAddDeviceNotification && !HasState(h, StateResponse)
+}
+
 // AddDeviceNotificationResponse is the response for adding a device notification.
 type AddDeviceNotificationResponse struct {
 	tcpHeader          TCPHeader
@@ -115,6 +120,11 @@ func (r *DeleteDeviceNotificationRequest) Decode(b *Buffer) error {
 	return b.Err()
 }
 
+// IsDeleteDeviceNotificationRequest returns true if the packet is a Delete Device Notification request.
+func IsDeleteDeviceNotificationRequest(h AMSHeader) bool {
+	return h.CmdID == CmdADSDeleteDeviceNotification && !HasState(h, StateResponse)
+}
+
 // DeleteDeviceNotificationResponse is the response for deleting a device notification.
 type DeleteDeviceNotificationResponse struct {
 	tcpHeader TCPHeader
@@ -188,3 +198,44 @@ func NewDeleteDeviceNotificationRequest(
 		NotificationHandle: notificationHandle,
 	}
 }
+
+// NewAddDeviceNotificationResponse creates a new AddDeviceNotification response.
+func NewAddDeviceNotificationResponse(
+	target, sender Addr,
+	result, notificationHandle uint32,
+) *AddDeviceNotificationResponse {
+	return &AddDeviceNotificationResponse{
+		tcpHeader: TCPHeader{
+			Length: amsHeaderLen + 8,
+		},
+		amsHeader: AMSHeader{
+			Target:     target,
+			Sender:     sender,
+			CmdID:      CmdADSAddDeviceNotification,
+			StateFlags: StateADSCommand | StateResponse,
+			Length:     8,
+		},
+		Result:             result,
+		NotificationHandle: notificationHandle,
+	}
+}
+
+// NewDeleteDeviceNotificationResponse creates a new DeleteDeviceNotification response.
+func NewDeleteDeviceNotificationResponse(
+	target, sender Addr,
+	result uint32,
+) *DeleteDeviceNotificationResponse {
+	return &DeleteDeviceNotificationResponse{
+		tcpHeader: TCPHeader{
+			Length: amsHeaderLen + 4,
+		},
+		amsHeader: AMSHeader{
+			Target:     target,
+			Sender:     sender,
+			CmdID:      CmdADSDeleteDeviceNotification,
+			StateFlags: StateADSCommand | StateResponse,
+			Length:     4,
+		},
+		Result: result,
+	}
+}
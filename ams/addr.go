@@ -10,8 +10,8 @@ import (
 	"strconv"
 )
 
-// reAddr is used for parsing a Twincat NetID.
-var reAddr = regexp.MustCompile(`^(\d+)\.(\d+)\.(\d+)\.(\d+)\.(\d+)\.(\d+):(\d+)$`)
+// reAddr is used for parsing a Twincat NetID with an optional port.
+var reAddr = regexp.MustCompile(`^(\d+)\.(\d+)\.(\d+)\.(\d+)\.(\d+)\.(\d+)(?::(\d+))?$`)
 
 // Addr describes a Twincat NetID and port.
 type Addr struct {
@@ -20,14 +20,17 @@ type Addr struct {
 }
 
 // String formats a Twincat NetID to a.b.c.d.e.f:port.
+// Missing bytes of the NetID are formatted as zero.
 func (a Addr) String() string {
+	var id [6]byte
+	copy(id[:], a.NetID)
 	return fmt.Sprintf("%d.%d.%d.%d.%d.%d:%d",
-		a.NetID[0],
-		a.NetID[1],
-		a.NetID[2],
-		a.NetID[3],
-		a.NetID[4],
-		a.NetID[5],
+		id[0],
+		id[1],
+		id[2],
+		id[3],
+		id[4],
+		id[5],
 		a.Port,
 	)
 }
@@ -42,14 +45,55 @@ func MustParseAddr(s string) Addr {
 }
 
 // ParseAddr parses a 'a.b.c.d.e.f:port' address into a NetID/Port
-// for the AMS protocol. Values for a to e need must be between 0 and 255
-// and the port must be between 0 and 65535.
+// for the AMS protocol, e.g. 192.168.0.1.1.1:851. Values for a to f
+// must be between 0 and 255 and the port must be between 1 and 65535.
+// Earlier versions accepted port 0, which is not a valid AMS port. Use
+// ParseNetID for a NetID without a port.
 func ParseAddr(s string) (Addr, error) {
+	m := reAddr.FindStringSubmatch(s)
+	if m == nil || len(m) != 8 || m[7] == "" {
+		return Addr{}, fmt.Errorf("invalid address: %s", s)
+	}
+	a, err := parseAddr(s, m)
+	if err != nil {
+		return Addr{}, err
+	}
+	if a.Port == 0 {
+		return Addr{}, fmt.Errorf("invalid port: %s", s)
+	}
+	return a, nil
+}
+
+// NewAddr returns the address of a port of the 'a.b.c.d.e.f' NetID.
+// The NetID must not have a port and the port must not be zero, like
+// for ParseAddr.
+func NewAddr(netID string, port uint16) (Addr, error) {
+	if port == 0 {
+		return Addr{}, fmt.Errorf("invalid port: %d", port)
+	}
+	m := reAddr.FindStringSubmatch(netID)
+	if m == nil || len(m) != 8 || m[7] != "" {
+		return Addr{}, fmt.Errorf("invalid NetID: %s", netID)
+	}
+	m[7] = strconv.Itoa(int(port))
+	return parseAddr(netID, m)
+}
+
+// ParseNetID parses a 'a.b.c.d.e.f' NetID with an optional ':port'
+// suffix. The port is zero if it is omitted.
+func ParseNetID(s string) (Addr, error) {
 	m := reAddr.FindStringSubmatch(s)
 	if m == nil || len(m) != 8 {
 		return Addr{}, fmt.Errorf("invalid address: %s", s)
 	}
+	if m[7] == "" {
+		m[7] = "0"
+	}
+	return parseAddr(s, m)
+}
 
+// parseAddr converts the submatches of reAddr.
+func parseAddr(s string, m []string) (Addr, error) {
 	netid := make([]byte, 6)
 	for i := 1; i <= 6; i++ {
 		n, err := strconv.ParseUint(m[i], 10, 32)
@@ -34,3 +34,67 @@ func TestParseAddr(t *testing.T) {
 		})
 	}
 }
+
+func TestParseNetID(t *testing.T) {
+	tests := []struct {
+		s       string
+		a       Addr
+		wantErr bool
+	}{
+		{"1.2.3.4.5.6", Addr{NetID: []byte{1, 2, 3, 4, 5, 6}}, false},
+		{"1.2.3.4.5.6:851", Addr{NetID: []byte{1, 2, 3, 4, 5, 6}, Port: 851}, false},
+		{"1.2.3.4.5", Addr{}, true},
+		{"1.2.3.4.5.256", Addr{}, true},
+		{"1.2.3.4.5.6:65536", Addr{}, true},
+		{"1.2.3.4.5.6:", Addr{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			a, err := ParseNetID(tt.s)
+			if got, want := err != nil, tt.wantErr; got != want {
+				t.Fatalf("got error %v want error %v", err, want)
+			}
+			verify.Values(t, "", a, tt.a)
+		})
+	}
+
+	if _, err := ParseAddr("1.2.3.4.5.6"); err == nil {
+		t.Error("ParseAddr: want error for missing port")
+	}
+}
+
+func TestAddrString(t *testing.T) {
+	verify.Values(t, "addr", MustParseAddr("1.2.3.4.5.6:851").String(), "1.2.3.4.5.6:851")
+	verify.Values(t, "zero", Addr{}.String(), "0.0.0.0.0.0:0")
+}
+
+func TestNewAddr(t *testing.T) {
+	tests := []struct {
+		netID   string
+		port    uint16
+		a       Addr
+		wantErr bool
+	}{
+		{"192.168.0.1.1.1", 851, Addr{NetID: []byte{192, 168, 0, 1, 1, 1}, Port: 851}, false},
+		{"192.168.0.1.1", 851, Addr{}, true},
+		{"192.168.0.1.1.1.1", 851, Addr{}, true},
+		{"192.168.0.1.1.1:851", 851, Addr{}, true},
+		{"192.168.0.1.1.1:0", 851, Addr{}, true},
+		{"192.168.0.1.1.1", 0, Addr{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.netID, func(t *testing.T) {
+			a, err := NewAddr(tt.netID, tt.port)
+			if got, want := err != nil, tt.wantErr; got != want {
+				t.Fatalf("got error %v want error %v", err, want)
+			}
+			verify.Values(t, "", a, tt.a)
+		})
+	}
+
+	if _, err := ParseAddr("1.2.3.4.5.6:0"); err == nil {
+		t.Error("ParseAddr: want error for port 0")
+	}
+}
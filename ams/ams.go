@@ -34,9 +34,17 @@ const (
 	StateBroadcast       = 1 << 15
 )
 
+// ADS error codes
+// https://infosys.beckhoff.com/english.php?content=../content/1033/tc3_ads_intro/374277003.html&id=
 const (
-	NoError               = 0
-	TargetMachineNotFound = 7
+	NoError                   = 0
+	InsertMailboxError        = 4
+	TargetPortNotFound        = 6
+	TargetMachineNotFound     = 7
+	DeviceServiceNotSupported = 0x701
+	DeviceSymbolNotFound      = 0x710
+	DeviceInvalidHandle       = 0x711
+	ClientSyncTimeout         = 0x745
 )
 
 // IndexGroups
@@ -44,6 +52,7 @@ const (
 const (
 	IdxGetSymHandleByName        = 0x0000F003
 	IdxReserved                  = 0x0000F004
+	IdxReadSymValueByName        = 0x0000F004 // ADSIGRP_SYM_VALBYNAME
 	IdxReadWriteSymValueByHandle = 0x0000F005
 	IdxReleaseSymHandle          = 0x0000F006
 	IdxReadIWriteI               = 0x0000F020
@@ -60,7 +69,17 @@ const (
 // https://infosys.beckhoff.com/english.php?content=../content/1033/tc3_ads_intro/115845259.html&id=
 const (
 	PortAMSRouter            = 1
+	PortLogger               = 100
+	PortEventLogger          = 110
+	PortRealtime             = 200
+	PortIO                   = 300
+	PortNC                   = 500
+	PortTC2PLCRuntimeSystem1 = 801
 	PortTC3PLCRuntimeSystem1 = 851
+	PortTC3PLCRuntimeSystem2 = 852
+	PortTC3PLCRuntimeSystem3 = 853
+	PortTC3PLCRuntimeSystem4 = 854
+	PortSystemService        = 10000
 )
 
 // https://infosys.beckhoff.com/english.php?content=../content/1033/tc3_adsdll2/117555851.html&id=
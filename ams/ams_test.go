@@ -0,0 +1,54 @@
+package ams
+
+import (
+	"testing"
+
+	"github.com/pascaldekloe/goe/verify"
+)
+
+func TestPacketClassification(t *testing.T) {
+	type classifier struct {
+		name string
+		cmd  uint16
+		resp bool
+		fn   func(AMSHeader) bool
+	}
+	classifiers := []classifier{
+		{"IsReadDeviceInfoRequest", CmdADSReadDeviceInfo, false, IsReadDeviceInfoRequest},
+		{"IsReadDeviceInfoResponse", CmdADSReadDeviceInfo, true, IsReadDeviceInfoResponse},
+		{"IsReadRequest", CmdADSRead, false, IsReadRequest},
+		{"IsReadResponse", CmdADSRead, true, IsReadResponse},
+		{"IsWriteRequest", CmdADSWrite, false, IsWriteRequest},
+		{"IsWriteResponse", CmdADSWrite, true, IsWriteResponse},
+		{"IsReadStateRequest", CmdADSReadState, false, IsReadStateRequest},
+		{"IsReadStateResponse", CmdADSReadState, true, IsReadStateResponse},
+		{"IsAddDeviceNotificationRequest", CmdADSAddDeviceNotification, false, IsAddDeviceNotificationRequest},
+		{"IsAddDeviceNotificationResponse", CmdADSAddDeviceNotification, true, IsAddDeviceNotificationResponse},
+		{"IsDeleteDeviceNotificationRequest", CmdADSDeleteDeviceNotification, false, IsDeleteDeviceNotificationRequest},
+		{"IsDeleteDeviceNotificationResponse", CmdADSDeleteDeviceNotification, true, IsDeleteDeviceNotificationResponse},
+		{"IsDeviceNotificationRequest", CmdADSDeviceNotification, false, IsDeviceNotificationRequest},
+		{"IsReadWriteRequest", CmdADSReadWrite, false, IsReadWriteRequest},
+		{"IsReadWriteResponse", CmdADSReadWrite, true, IsReadWriteResponse},
+	}
+
+	flags := []uint16{
+		StateADSCommand,
+		StateADSCommand | StateResponse,
+		StateADSCommand | StateNoReturn,
+		StateADSCommand | StateResponse | StateUDPCommand,
+	}
+
+	for _, c := range classifiers {
+		t.Run(c.name, func(t *testing.T) {
+			for _, f := range flags {
+				h := AMSHeader{CmdID: c.cmd, StateFlags: f}
+				want := HasState(h, StateResponse) == c.resp
+				verify.Values(t, "flags", c.fn(h), want)
+
+				// other commands never match
+				h.CmdID = c.cmd + 100
+				verify.Values(t, "other command", c.fn(h), false)
+			}
+		})
+	}
+}
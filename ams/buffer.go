@@ -34,6 +34,11 @@ func (buf *Buffer) Bytes() []byte {
 	return buf.b.Bytes()
 }
 
+// Len returns the number of unread bytes.
+func (buf *Buffer) Len() int {
+	return buf.b.Len()
+}
+
 // Err returns the first error or nil.
 func (buf *Buffer) Err() error {
 	return buf.err
@@ -62,6 +67,11 @@ func (buf *Buffer) ReadN(n int) []byte {
 	if buf.err != nil {
 		return nil
 	}
+	// do not allocate for a length which cannot be read
+	if n < 0 || n > buf.b.Len() {
+		buf.err = io.ErrUnexpectedEOF
+		return nil
+	}
 	b := make([]byte, n)
 	_, buf.err = io.ReadFull(&buf.b, b)
 	if buf.err != nil {
@@ -109,26 +119,45 @@ func (buf *Buffer) ReadUint8() uint8 {
 }
 
 // ReadUint16 reads a uint16 from the buffer.
+// It returns io.ErrUnexpectedEOF if the buffer
+// is too small.
 func (buf *Buffer) ReadUint16() uint16 {
 	if buf.err != nil {
 		return 0
 	}
-	return binary.LittleEndian.Uint16(buf.ReadN(2))
+	b := buf.ReadN(2)
+	if len(b) < 2 {
+		return 0
+	}
+	return binary.LittleEndian.Uint16(b)
 }
 
 // ReadUint32 reads a uint32 from the buffer.
+// It returns io.ErrUnexpectedEOF if the buffer
+// is too small.
 func (buf *Buffer) ReadUint32() uint32 {
 	if buf.err != nil {
 		return 0
 	}
-	return binary.LittleEndian.Uint32(buf.ReadN(4))
+	b := buf.ReadN(4)
+	if len(b) < 4 {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(b)
 }
 
 // ReadUint32Slice reads n uint32 from the buffer.
+// It returns io.ErrUnexpectedEOF if the buffer
+// is too small.
 func (buf *Buffer) ReadUint32Slice(n int) []uint32 {
 	if buf.err != nil {
 		return nil
 	}
+	// do not allocate for a length which cannot be read
+	if n < 0 || n > buf.b.Len()/4 {
+		buf.err = io.ErrUnexpectedEOF
+		return nil
+	}
 	a := make([]uint32, n)
 	for i := range a {
 		a[i] = buf.ReadUint32()
@@ -5,6 +5,7 @@
 package ams
 
 import (
+	"io"
 	"testing"
 
 	"github.com/pascaldekloe/goe/verify"
@@ -75,3 +76,49 @@ func TestBufferFloat32Slice(t *testing.T) {
 	verify.Values(t, "err", br.Err(), nil)
 	verify.Values(t, "data", a, []float32{1, 2})
 }
+
+func TestBufferReset(t *testing.T) {
+	b := NewBuffer([]byte{1})
+	b.ReadN(4)
+	if b.Err() == nil {
+		t.Fatal("want error")
+	}
+
+	b.Reset()
+	b.WriteUint16(0x1234)
+	verify.Values(t, "err", b.Err(), nil)
+	verify.Values(t, "bytes", b.Bytes(), []byte{0x34, 0x12})
+}
+
+func TestBufferUnderflow(t *testing.T) {
+	tests := []struct {
+		name string
+		read func(b *Buffer) interface{}
+		want interface{}
+	}{
+		{"ReadUint8", func(b *Buffer) interface{} { return b.ReadUint8() }, uint8(0)},
+		{"ReadUint16", func(b *Buffer) interface{} { return b.ReadUint16() }, uint16(0)},
+		{"ReadUint32", func(b *Buffer) interface{} { return b.ReadUint32() }, uint32(0)},
+		{"ReadUint32Slice", func(b *Buffer) interface{} { return b.ReadUint32Slice(1 << 30) }, []uint32(nil)},
+		{"ReadN", func(b *Buffer) interface{} { return b.ReadN(-1) }, []byte(nil)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBuffer(nil)
+			verify.Values(t, "value", tt.read(b), tt.want)
+			verify.Values(t, "err", b.Err(), io.ErrUnexpectedEOF)
+		})
+	}
+}
+
+func TestDecodeNotificationCount(t *testing.T) {
+	var b Buffer
+	b.WriteStruct(&tcpHeader)
+	b.WriteStruct(&amsHeader)
+	b.WriteUint32(0)          // length
+	b.WriteUint32(0xFFFFFFFF) // stamps
+
+	var r DeviceNotificationRequest
+	verify.Values(t, "err", r.Decode(NewBuffer(b.Bytes())), io.ErrUnexpectedEOF)
+}
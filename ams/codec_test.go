@@ -93,3 +93,80 @@ func codecTest(t *testing.T, c codec, b []byte) {
 
 	verify.Values(t, "compare", cnew, c)
 }
+
+func TestPacketRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		p    codec
+	}{
+		{"ReadRequest", NewReadRequest(target, sender, 0x4020, 8, 2)},
+		{"ReadResponse", NewReadResponse(target, sender, 0, []byte{1, 2})},
+		{"WriteRequest", NewWriteRequest(target, sender, 0x4020, 8, []byte{1, 2, 3})},
+		{"WriteResponse", NewWriteResponse(target, sender, DeviceInvalidHandle)},
+		{"ReadWriteRequest", NewReadWriteRequest(target, sender, 0xF003, 0, 4, []byte("MAIN.n"))},
+		{"ReadWriteResponse", NewReadWriteResponse(target, sender, 0, []byte{1, 0, 0, 0})},
+		{"ReadStateRequest", NewReadStateRequest(target, sender)},
+		{"ReadStateResponse", NewReadStateResponse(target, sender, 0, ADSStateRun, 1)},
+		{"ReadDeviceInfoRequest", NewReadDeviceInfoRequest(target, sender)},
+		{"ReadDeviceInfoResponse", NewReadDeviceInfoResponse(target, sender, 0, 3, 1, 4024, "Plc30 App")},
+		{"AddDeviceNotificationRequest", NewAddDeviceNotificationRequest(target, sender, 0x4020, 8, 2, 4, 0, 100000)},
+		{"AddDeviceNotificationResponse", NewAddDeviceNotificationResponse(target, sender, 0, 7)},
+		{"DeleteDeviceNotificationRequest", NewDeleteDeviceNotificationRequest(target, sender, 7)},
+		{"DeleteDeviceNotificationResponse", NewDeleteDeviceNotificationResponse(target, sender, 0)},
+		{"DeviceNotificationRequest", NewDeviceNotificationRequest(target, sender, []NotificationStamp{
+			{Timestamp: 132000000000000000, Samples: []NotificationSample{{Handle: 7, Data: []byte{1, 2}}}},
+			{Timestamp: 132000000010000000, Samples: []NotificationSample{{Handle: 7, Data: []byte{3, 4}}, {Handle: 8, Data: []byte{5}}}},
+		})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf Buffer
+			if err := tt.p.Encode(&buf); err != nil {
+				t.Fatalf("encode: %s", err)
+			}
+			if err := ValidatePacket(buf.Bytes()); err != nil {
+				t.Fatal(err)
+			}
+
+			got := reflect.New(reflect.TypeOf(tt.p).Elem()).Interface().(codec)
+			if err := got.Decode(NewBuffer(buf.Bytes())); err != nil {
+				t.Fatalf("decode: %s", err)
+			}
+			verify.Values(t, "decoded", got, tt.p)
+		})
+	}
+}
+
+func TestValidatePacket(t *testing.T) {
+	var buf Buffer
+	if err := NewWriteRequest(target, sender, 0x4020, 0, []byte{1, 2}).Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+	valid := buf.Bytes()
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr bool
+	}{
+		{"valid", valid, false},
+		{"short", valid[:20], true},
+		{"truncated", valid[:len(valid)-1], true},
+		{"trailing", append(append([]byte(nil), valid...), 0), true},
+		{"AMS length", func() []byte {
+			b := append([]byte(nil), valid...)
+			b[tcpHeaderLen+20]++ // AMS header length
+			return b
+		}(), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidatePacket(tt.data)
+			if got, want := err != nil, tt.wantErr; got != want {
+				t.Fatalf("got error %v want error %v", err, want)
+			}
+		})
+	}
+}
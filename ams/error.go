@@ -0,0 +1,24 @@
+package ams
+
+import "fmt"
+
+// ADSError is an ADS return code which is not NoError.
+type ADSError uint32
+
+// adsErrorText contains the descriptions of the known error codes
+var adsErrorText = map[ADSError]string{
+	InsertMailboxError:        "insertion mailbox error",
+	TargetPortNotFound:        "target port not found",
+	TargetMachineNotFound:     "target machine not found",
+	DeviceServiceNotSupported: "service not supported by server",
+	DeviceSymbolNotFound:      "symbol not found",
+	DeviceInvalidHandle:       "invalid symbol handle",
+	ClientSyncTimeout:         "timeout elapsed",
+}
+
+func (e ADSError) Error() string {
+	if s, ok := adsErrorText[e]; ok {
+		return fmt.Sprintf("ADS error 0x%x: %s", uint32(e), s)
+	}
+	return fmt.Sprintf("ADS error 0x%x", uint32(e))
+}
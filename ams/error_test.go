@@ -0,0 +1,21 @@
+package ams
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/pascaldekloe/goe/verify"
+)
+
+func TestADSError(t *testing.T) {
+	verify.Values(t, "known", ADSError(DeviceSymbolNotFound).Error(), "ADS error 0x710: symbol not found")
+	verify.Values(t, "unknown", ADSError(0x1234).Error(), "ADS error 0x1234")
+
+	var e ADSError
+	err := fmt.Errorf("failed to read: %w", ADSError(TargetPortNotFound))
+	if !errors.As(err, &e) {
+		t.Fatal("want ADSError")
+	}
+	verify.Values(t, "code", e, ADSError(TargetPortNotFound))
+}
@@ -0,0 +1,41 @@
+// Copyright 2021 gotwincat authors. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be
+// found in the LICENSE file.
+
+//go:build go1.18
+// +build go1.18
+
+package ams
+
+import "testing"
+
+func FuzzDecode(f *testing.F) {
+	header := append(append([]byte(nil), tcpHeaderBytes...), amsHeaderBytes...)
+	f.Add(header)
+	f.Add(append(header, 0, 0, 0, 0, 4, 0, 0, 0, 1, 2, 3, 4))
+	f.Add(append(header, 0, 0, 0, 0, 1, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		for _, d := range []Decoder{
+			new(Header),
+			new(ReadRequest),
+			new(ReadResponse),
+			new(WriteRequest),
+			new(WriteResponse),
+			new(ReadWriteRequest),
+			new(ReadWriteResponse),
+			new(ReadStateRequest),
+			new(ReadStateResponse),
+			new(ReadDeviceInfoRequest),
+			new(ReadDeviceInfoResponse),
+			new(AddDeviceNotificationRequest),
+			new(AddDeviceNotificationResponse),
+			new(DeleteDeviceNotificationRequest),
+			new(DeleteDeviceNotificationResponse),
+			new(DeviceNotificationRequest),
+		} {
+			// must not panic
+			d.Decode(NewBuffer(data))
+		}
+	})
+}
@@ -4,6 +4,8 @@
 
 package ams
 
+import "fmt"
+
 // TCPHeader is the AMS/TCP packet header.
 //
 // https://infosys.beckhoff.com/english.php?content=../content/1033/tf7xxx_tc3_vision/27021602095817483.html&id=
@@ -24,7 +26,10 @@ func (h *TCPHeader) Decode(b *Buffer) error {
 	return b.Err()
 }
 
-const amsHeaderLen = 32 // 2*8 + 2 + 2 + 4 + 4 + 4
+const (
+	tcpHeaderLen = 6  // 2 + 4
+	amsHeaderLen = 32 // 2*8 + 2 + 2 + 4 + 4 + 4
+)
 
 // AMSHeader is the AMS packet header.
 //
@@ -78,3 +83,23 @@ func (r *Header) Decode(b *Buffer) error {
 	b.ReadStruct(&r.AMSHeader)
 	return b.Err()
 }
+
+// ValidatePacket checks that the lengths in the AMS/TCP and the AMS
+// header of an encoded packet match its data. Servers can use it to
+// reject malformed packets before sending them.
+func ValidatePacket(data []byte) error {
+	if len(data) < tcpHeaderLen+amsHeaderLen {
+		return fmt.Errorf("packet too short: %d bytes", len(data))
+	}
+	var h Header
+	if err := h.Decode(NewBuffer(data)); err != nil {
+		return err
+	}
+	if got, want := int64(h.TCPHeader.Length), int64(len(data)-tcpHeaderLen); got != want {
+		return fmt.Errorf("invalid AMS/TCP header length %d, want %d", got, want)
+	}
+	if got, want := int64(h.AMSHeader.Length), int64(len(data)-tcpHeaderLen-amsHeaderLen); got != want {
+		return fmt.Errorf("invalid AMS header length %d, want %d", got, want)
+	}
+	return nil
+}
@@ -4,6 +4,11 @@
 
 package ams
 
+import (
+	"io"
+	"time"
+)
+
 // DeviceNotificationRequest is the packet for an ADS Device Notification.
 type DeviceNotificationRequest struct {
 	tcpHeader  TCPHeader
@@ -20,6 +25,27 @@ type NotificationStamp struct {
 	Samples     []NotificationSample
 }
 
+// filetimeEpochOffset is the number of 100ns intervals between the
+// FILETIME epoch 1601-01-01 and the Unix epoch 1970-01-01.
+const filetimeEpochOffset = 116444736000000000
+
+// Time converts the Windows FILETIME timestamp of the stamp to a UTC time.
+// FILETIME counts 100ns intervals since 1601-01-01 00:00:00 UTC.
+// A zero timestamp returns the zero time.
+func (s NotificationStamp) Time() time.Time {
+	if s.Timestamp == 0 {
+		return time.Time{}
+	}
+	ticks := int64(s.Timestamp) - filetimeEpochOffset
+	secs := ticks / 1e7
+	rem := ticks % 1e7
+	if rem < 0 {
+		secs--
+		rem += 1e7
+	}
+	return time.Unix(secs, rem*100).UTC()
+}
+
 // NotificationSample represents a single sample within a notification stamp
 type NotificationSample struct {
 	Handle uint32 // Notification handle
@@ -27,11 +53,40 @@ type NotificationSample struct {
 	Data   []byte // Notification data
 }
 
+// NewDeviceNotificationRequest creates a new DeviceNotification request.
+// The lengths and counts are set when the request is encoded.
+func NewDeviceNotificationRequest(target, sender Addr, stamps []NotificationStamp) *DeviceNotificationRequest {
+	return &DeviceNotificationRequest{
+		amsHeader: AMSHeader{
+			Target:     target,
+			Sender:     sender,
+			CmdID:      CmdADSDeviceNotification,
+			StateFlags: StateADSCommand,
+		},
+		Stamps: stamps,
+	}
+}
+
 func (r *DeviceNotificationRequest) Header() *AMSHeader {
 	return &r.amsHeader
 }
 
 func (r *DeviceNotificationRequest) Encode(b *Buffer) error {
+	// Length is the size of the data after the length field
+	r.Length = 4
+	r.StampCount = uint32(len(r.Stamps))
+	for i := range r.Stamps {
+		r.Stamps[i].SampleCount = uint32(len(r.Stamps[i].Samples))
+		r.Length += 12
+		for j := range r.Stamps[i].Samples {
+			r.Stamps[i].Samples[j].Size = uint32(len(r.Stamps[i].Samples[j].Data))
+			r.Length += 8 + r.Stamps[i].Samples[j].Size
+		}
+	}
+
+	r.tcpHeader.Length = amsHeaderLen + 4 + r.Length
+	r.amsHeader.Length = 4 + r.Length
+	r.amsHeader.CmdID = CmdADSDeviceNotification
 	b.WriteStruct(&r.tcpHeader)
 	b.WriteStruct(&r.amsHeader)
 	b.WriteUint32(r.Length)
@@ -79,7 +134,11 @@ func (r *DeviceNotificationRequest) Decode(b *Buffer) error {
 		return b.Err()
 	}
 
-	// Parse stamps (loop StampCount times)
+	// Parse stamps (loop StampCount times). A stamp has at least
+	// 12 bytes, do not allocate for a count which cannot be read.
+	if r.StampCount > uint32(b.Len()/12) {
+		return io.ErrUnexpectedEOF
+	}
 	r.Stamps = make([]NotificationStamp, r.StampCount)
 
 	for i := uint32(0); i < r.StampCount; i++ {
@@ -97,7 +156,10 @@ func (r *DeviceNotificationRequest) Decode(b *Buffer) error {
 			return b.Err()
 		}
 
-		// Read samples
+		// Read samples, which have at least 8 bytes
+		if r.Stamps[i].SampleCount > uint32(b.Len()/8) {
+			return io.ErrUnexpectedEOF
+		}
 		r.Stamps[i].Samples = make([]NotificationSample, r.Stamps[i].SampleCount)
 
 		for j := uint32(0); j < r.Stamps[i].SampleCount; j++ {
@@ -126,5 +188,5 @@ func (r *DeviceNotificationRequest) Decode(b *Buffer) error {
 
 // IsDeviceNotificationRequest returns true if the packet is an ADS Device Notification.
 func IsDeviceNotificationRequest(h AMSHeader) bool {
-	return h.CmdID == CmdADSDeviceNotification
+	return h.CmdID == CmdADSDeviceNotification && !HasState(h, StateResponse)
 }
@@ -0,0 +1,141 @@
+package ams
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pascaldekloe/goe/verify"
+)
+
+func TestNotificationStampTime(t *testing.T) {
+	tests := []struct {
+		name string
+		ts   uint64
+		want time.Time
+	}{
+		{"zero", 0, time.Time{}},
+		{"unix epoch", 116444736000000000, time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"filetime epoch", 1, time.Date(1601, 1, 1, 0, 0, 0, 100, time.UTC)},
+		{"before unix epoch", 116444735999999999, time.Date(1969, 12, 31, 23, 59, 59, 999999900, time.UTC)},
+		{"2021", 132539328000000000, time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"100ns resolution", 132539328000000001, time.Date(2021, 1, 1, 0, 0, 0, 100, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NotificationStamp{Timestamp: tt.ts}.Time()
+			verify.Values(t, "", got, tt.want)
+		})
+	}
+}
+
+func TestDeviceNotification(t *testing.T) {
+	// Encode sets the lengths and command id of the headers
+	notificationTCPHeader := TCPHeader{Reserved: tcpHeader.Reserved, Length: amsHeaderLen + 0x5e}
+	notificationAMSHeader := amsHeader
+	notificationAMSHeader.CmdID = CmdADSDeviceNotification
+	notificationAMSHeader.Length = 0x5e
+
+	var hdr Buffer
+	hdr.WriteStruct(&notificationTCPHeader)
+	hdr.WriteStruct(&notificationAMSHeader)
+	hdrBytes := hdr.Bytes()
+
+	tests := []struct {
+		name string
+		p    codec
+		b    []byte
+	}{
+		{
+			name: "DeviceNotificationRequest",
+			p: &DeviceNotificationRequest{
+				tcpHeader:  notificationTCPHeader,
+				amsHeader:  notificationAMSHeader,
+				Length:     0x5a,
+				StampCount: 2,
+				Stamps: []NotificationStamp{
+					{
+						Timestamp:   0x0102030405060708,
+						SampleCount: 3,
+						Samples: []NotificationSample{
+							{Handle: 1, Size: 1, Data: []byte{0xaa}},
+							{Handle: 2, Size: 4, Data: []byte{0x01, 0x02, 0x03, 0x04}},
+							{Handle: 3, Size: 2, Data: []byte{0x05, 0x06}},
+						},
+					},
+					{
+						Timestamp:   0x1112131415161718,
+						SampleCount: 3,
+						Samples: []NotificationSample{
+							{Handle: 3, Size: 2, Data: []byte{0x07, 0x08}},
+							{Handle: 1, Size: 1, Data: []byte{0xbb}},
+							{Handle: 2, Size: 4, Data: []byte{0x09, 0x0a, 0x0b, 0x0c}},
+						},
+					},
+				},
+			},
+			b: func() []byte {
+				data := []byte{
+					0x5a, 0x00, 0x00, 0x00, // Length
+					0x02, 0x00, 0x00, 0x00, // StampCount
+
+					0x08, 0x07, 0x06, 0x05, 0x04, 0x03, 0x02, 0x01, // Timestamp
+					0x03, 0x00, 0x00, 0x00, // SampleCount
+					0x01, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0xaa, // Handle, Size, Data
+					0x02, 0x00, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00, 0x01, 0x02, 0x03, 0x04, // Handle, Size, Data
+					0x03, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x05, 0x06, // Handle, Size, Data
+
+					0x18, 0x17, 0x16, 0x15, 0x14, 0x13, 0x12, 0x11, // Timestamp
+					0x03, 0x00, 0x00, 0x00, // SampleCount
+					0x03, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x07, 0x08, // Handle, Size, Data
+					0x01, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0xbb, // Handle, Size, Data
+					0x02, 0x00, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00, 0x09, 0x0a, 0x0b, 0x0c, // Handle, Size, Data
+				}
+				return append(append([]byte{}, hdrBytes...), data...)
+			}(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			codecTest(t, tt.p, tt.b)
+		})
+	}
+}
+
+func TestDeviceNotificationEncodeLength(t *testing.T) {
+	r := &DeviceNotificationRequest{
+		amsHeader: AMSHeader{Target: target, Sender: sender},
+		Stamps: []NotificationStamp{
+			{
+				Timestamp: 1,
+				Samples: []NotificationSample{
+					{Handle: 1, Data: []byte{0x01}},
+					{Handle: 2, Data: []byte{0x01, 0x02, 0x03}},
+				},
+			},
+			{
+				Timestamp: 2,
+				Samples:   []NotificationSample{{Handle: 3, Data: []byte{}}},
+			},
+		},
+	}
+
+	var b Buffer
+	if err := r.Encode(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	var got DeviceNotificationRequest
+	if err := got.Decode(NewBuffer(b.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+
+	verify.Values(t, "tcp length", int(got.tcpHeader.Length), len(b.Bytes())-6)
+	verify.Values(t, "ams length", int(got.amsHeader.Length), len(b.Bytes())-6-amsHeaderLen)
+	verify.Values(t, "length", int(got.Length), len(b.Bytes())-6-amsHeaderLen-4)
+	verify.Values(t, "stamps", got.StampCount, uint32(2))
+	verify.Values(t, "samples", got.Stamps[0].SampleCount, uint32(2))
+	verify.Values(t, "size", got.Stamps[0].Samples[1].Size, uint32(3))
+	verify.Values(t, "cmd", got.amsHeader.CmdID, uint16(CmdADSDeviceNotification))
+}
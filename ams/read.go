@@ -53,6 +53,11 @@ func (r *ReadRequest) Decode(b *Buffer) error {
 	return b.Err()
 }
 
+// IsReadRequest returns true if the packet is a read request.
+func IsReadRequest(h AMSHeader) bool {
+	return h.CmdID == CmdADSRead && !HasState(h, StateResponse)
+}
+
 // ReadResponse is the packet for an AMS Read response.
 type ReadResponse struct {
 	tcpHeader TCPHeader
@@ -62,6 +67,25 @@ type ReadResponse struct {
 	Data      []byte
 }
 
+func NewReadResponse(target, sender Addr, result uint32, data []byte) *ReadResponse {
+	dataLen := uint32(len(data))
+	return &ReadResponse{
+		tcpHeader: TCPHeader{
+			Length: amsHeaderLen + dataLen + 8,
+		},
+		amsHeader: AMSHeader{
+			Target:     target,
+			Sender:     sender,
+			CmdID:      CmdADSRead,
+			StateFlags: StateADSCommand | StateResponse,
+			Length:     dataLen + 8,
+		},
+		Result: result,
+		Length: dataLen,
+		Data:   data,
+	}
+}
+
 func (r *ReadResponse) Header() *AMSHeader {
 	return &r.amsHeader
 }
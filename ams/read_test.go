@@ -26,6 +26,26 @@ func TestNewReadRequest(t *testing.T) {
 	verify.Values(t, "", got, want)
 }
 
+func TestNewReadResponse(t *testing.T) {
+	got := NewReadResponse(target, sender, 0x1, []byte{0x2, 0x3})
+	want := &ReadResponse{
+		tcpHeader: TCPHeader{
+			Length: amsHeaderLen + 10,
+		},
+		amsHeader: AMSHeader{
+			Target:     target,
+			Sender:     sender,
+			CmdID:      CmdADSRead,
+			StateFlags: StateADSCommand | StateResponse,
+			Length:     10,
+		},
+		Result: 0x1,
+		Length: 0x2,
+		Data:   []byte{0x2, 0x3},
+	}
+	verify.Values(t, "", got, want)
+}
+
 func TestRead(t *testing.T) {
 	tests := []struct {
 		name string
@@ -36,7 +36,7 @@ func (r *ReadDeviceInfoRequest) Decode(b *Buffer) error {
 }
 
 func IsReadDeviceInfoRequest(h AMSHeader) bool {
-	return h.CmdID == CmdADSReadDeviceInfo && h.StateFlags == StateADSCommand
+	return h.CmdID == CmdADSReadDeviceInfo && !HasState(h, StateResponse)
 }
 
 type ReadDeviceInfoResponse struct {
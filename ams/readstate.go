@@ -36,7 +36,12 @@ func (r *ReadStateRequest) Decode(b *Buffer) error {
 }
 
 func IsReadStateRequest(h AMSHeader) bool {
-	return h.CmdID == CmdADSReadState && h.StateFlags == StateADSCommand
+	return h.CmdID == CmdADSReadState && !HasState(h, StateResponse)
+}
+
+// IsReadStateResponse returns true if the packet is a read state response.
+func IsReadStateResponse(h AMSHeader) bool {
+	return h.CmdID == CmdADSReadState && HasState(h, StateResponse)
 }
 
 type ReadStateResponse struct {
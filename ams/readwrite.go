@@ -62,15 +62,41 @@ func (r *ReadWriteRequest) Decode(b *Buffer) error {
 	return b.Err()
 }
 
+// IsReadWriteRequest returns true if the packet is an AMS ReadWrite request.
+func IsReadWriteRequest(h AMSHeader) bool {
+	return h.CmdID == CmdADSReadWrite && !HasState(h, StateResponse)
+}
+
 // ReadWriteResponse is the packet for an AMS ReadWrite response.
+// Decode reads exactly Length bytes into Data and ignores any
+// trailing bytes.
 type ReadWriteResponse struct {
 	tcpHeader TCPHeader
 	amsHeader AMSHeader
 	Result    uint32
-	Length    uint32
+	Length    uint32 // declared length of Data
 	Data      []byte
 }
 
+func NewReadWriteResponse(target, sender Addr, result uint32, data []byte) *ReadWriteResponse {
+	dataLen := uint32(len(data))
+	return &ReadWriteResponse{
+		tcpHeader: TCPHeader{
+			Length: amsHeaderLen + dataLen + 8,
+		},
+		amsHeader: AMSHeader{
+			Target:     target,
+			Sender:     sender,
+			CmdID:      CmdADSReadWrite,
+			StateFlags: StateADSCommand | StateResponse,
+			Length:     dataLen + 8,
+		},
+		Result: result,
+		Length: dataLen,
+		Data:   data,
+	}
+}
+
 func (r *ReadWriteResponse) Header() *AMSHeader {
 	return &r.amsHeader
 }
@@ -93,7 +119,7 @@ func (r *ReadWriteResponse) Decode(b *Buffer) error {
 	return b.Err()
 }
 
-//
+// IsReadWriteResponse returns true if the packet is an AMS ReadWrite response.
 func IsReadWriteResponse(h AMSHeader) bool {
 	return h.CmdID == CmdADSReadWrite && HasState(h, StateResponse)
 }
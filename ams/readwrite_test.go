@@ -28,6 +28,26 @@ func TestNewReadWriteRequest(t *testing.T) {
 	verify.Values(t, "", got, want)
 }
 
+func TestNewReadWriteResponse(t *testing.T) {
+	got := NewReadWriteResponse(target, sender, 0x1, []byte{0x2, 0x3})
+	want := &ReadWriteResponse{
+		tcpHeader: TCPHeader{
+			Length: amsHeaderLen + 10,
+		},
+		amsHeader: AMSHeader{
+			Target:     target,
+			Sender:     sender,
+			CmdID:      CmdADSReadWrite,
+			StateFlags: StateADSCommand | StateResponse,
+			Length:     10,
+		},
+		Result: 0x1,
+		Length: 0x2,
+		Data:   []byte{0x2, 0x3},
+	}
+	verify.Values(t, "", got, want)
+}
+
 func TestReadWrite(t *testing.T) {
 	tests := []struct {
 		name string
@@ -82,3 +102,54 @@ func TestReadWrite(t *testing.T) {
 		})
 	}
 }
+
+func TestReadWriteResponseLength(t *testing.T) {
+	head := append(append([]byte(nil), tcpHeaderBytes...), amsHeaderBytes...)
+
+	b := append(append([]byte(nil), head...),
+		0x00, 0x00, 0x00, 0x00, // Result
+		0x02, 0x00, 0x00, 0x00, // Length
+		0x0a, 0x0b, // Data
+		0xde, 0xad, 0xbe, 0xef, // trailing garbage
+	)
+	var r ReadWriteResponse
+	if err := r.Decode(NewBuffer(b)); err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "length", r.Length, uint32(2))
+	verify.Values(t, "data", r.Data, []byte{0x0a, 0x0b})
+
+	b = append(append([]byte(nil), head...),
+		0x00, 0x00, 0x00, 0x00, // Result
+		0xff, 0xff, 0xff, 0xff, // Length
+		0x0a, 0x0b, // Data
+	)
+	if err := new(ReadWriteResponse).Decode(NewBuffer(b)); err == nil {
+		t.Fatal("want error for length beyond the data")
+	}
+}
+
+func TestReadWriteZeroReadLength(t *testing.T) {
+	req := NewReadWriteRequest(target, sender, 0xF006, 0, 0, []byte{0x01, 0x00, 0x00, 0x00})
+	var buf Buffer
+	if err := req.Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var gotReq ReadWriteRequest
+	if err := gotReq.Decode(NewBuffer(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "request", &gotReq, req)
+
+	resp := NewReadWriteResponse(target, sender, NoError, nil)
+	buf.Reset()
+	if err := resp.Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var gotResp ReadWriteResponse
+	if err := gotResp.Decode(NewBuffer(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "length", gotResp.Length, uint32(0))
+	verify.Values(t, "data", len(gotResp.Data), 0)
+}
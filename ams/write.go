@@ -58,6 +58,11 @@ func (r *WriteRequest) Decode(b *Buffer) error {
 	return b.Err()
 }
 
+// IsWriteRequest returns true if the packet is an AMS Write request.
+func IsWriteRequest(h AMSHeader) bool {
+	return h.CmdID == CmdADSWrite && !HasState(h, StateResponse)
+}
+
 // WriteResponse is the packet for an AMS write response.
 type WriteResponse struct {
 	tcpHeader TCPHeader
@@ -65,6 +70,22 @@ type WriteResponse struct {
 	Result    uint32
 }
 
+func NewWriteResponse(target, sender Addr, result uint32) *WriteResponse {
+	return &WriteResponse{
+		tcpHeader: TCPHeader{
+			Length: amsHeaderLen + 4,
+		},
+		amsHeader: AMSHeader{
+			Target:     target,
+			Sender:     sender,
+			CmdID:      CmdADSWrite,
+			StateFlags: StateADSCommand | StateResponse,
+			Length:     4,
+		},
+		Result: result,
+	}
+}
+
 func (r *WriteResponse) Header() *AMSHeader {
 	return &r.amsHeader
 }
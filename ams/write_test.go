@@ -27,6 +27,24 @@ func TestNewWriteRequest(t *testing.T) {
 	verify.Values(t, "", got, want)
 }
 
+func TestNewWriteResponse(t *testing.T) {
+	got := NewWriteResponse(target, sender, 0x1)
+	want := &WriteResponse{
+		tcpHeader: TCPHeader{
+			Length: amsHeaderLen + 4,
+		},
+		amsHeader: AMSHeader{
+			Target:     target,
+			Sender:     sender,
+			CmdID:      CmdADSWrite,
+			StateFlags: StateADSCommand | StateResponse,
+			Length:     4,
+		},
+		Result: 0x1,
+	}
+	verify.Values(t, "", got, want)
+}
+
 func TestWrite(t *testing.T) {
 	tests := []struct {
 		name string
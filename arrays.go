@@ -0,0 +1,297 @@
+package goads
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/mrpasztoradam/goads/ams"
+)
+
+// ArrayDim is the index range of one array dimension
+type ArrayDim struct {
+	Lower int `json:"lower"`
+	Upper int `json:"upper"`
+}
+
+// Len returns the number of elements in the dimension
+func (d ArrayDim) Len() int {
+	return d.Upper - d.Lower + 1
+}
+
+// ArrayType describes a PLC array type like ARRAY [0..9] OF ST_Step
+type ArrayType struct {
+	Dims        []ArrayDim `json:"dims"`
+	ElementType string     `json:"elementType"`
+}
+
+// Len returns the total number of elements in the array
+func (a *ArrayType) Len() int {
+	n := 1
+	for _, d := range a.Dims {
+		n *= d.Len()
+	}
+	return n
+}
+
+// elementSize returns the size of one element of the array when the
+// whole array has size bytes. It returns an error if the array has
+// more elements than bytes.
+func (a *ArrayType) elementSize(size uint32) (uint32, error) {
+	n := a.Len()
+	if n <= 0 || uint64(n) > uint64(size) {
+		return 0, fmt.Errorf("invalid array length %d for %d bytes", n, size)
+	}
+	return size / uint32(n), nil
+}
+
+// maxArrayLen is the largest number of elements of an array. Larger
+// arrays cannot be addressed with the 32-bit sizes of ADS and Len
+// must not overflow an int.
+const maxArrayLen = uint64(math.MaxUint32 & uint64(^uint(0)>>1))
+
+// IsArrayType returns true if the data type is an array type
+func IsArrayType(dataType string) bool {
+	return strings.HasPrefix(strings.TrimSpace(dataType), "ARRAY")
+}
+
+// ParseArrayType parses a PLC array type name like
+// ARRAY [0..9] OF ST_Step or ARRAY [1..2,0..3] OF INT. The bounds are
+// DINT values and the array must not have more than math.MaxUint32
+// elements, or math.MaxInt32 on 32-bit platforms.
+func ParseArrayType(dataType string) (*ArrayType, error) {
+	s := strings.TrimSpace(dataType)
+	if !strings.HasPrefix(s, "ARRAY") {
+		return nil, fmt.Errorf("not an array type: %s", dataType)
+	}
+
+	start := strings.Index(s, "[")
+	end := strings.Index(s, "]")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("invalid array type: %s", dataType)
+	}
+
+	rest := strings.TrimSpace(s[end+1:])
+	if !strings.HasPrefix(rest, "OF ") {
+		return nil, fmt.Errorf("invalid array type: %s", dataType)
+	}
+
+	a := &ArrayType{
+		ElementType: strings.TrimSpace(rest[3:]),
+	}
+	if a.ElementType == "" {
+		return nil, fmt.Errorf("invalid array type: %s", dataType)
+	}
+
+	n := uint64(1)
+	for _, dim := range strings.Split(s[start+1:end], ",") {
+		bounds := strings.Split(dim, "..")
+		if len(bounds) != 2 {
+			return nil, fmt.Errorf("invalid array bounds %q in %s", dim, dataType)
+		}
+		lower, err := strconv.ParseInt(strings.TrimSpace(bounds[0]), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid array bounds %q in %s", dim, dataType)
+		}
+		upper, err := strconv.ParseInt(strings.TrimSpace(bounds[1]), 10, 32)
+		if err != nil || upper < lower {
+			return nil, fmt.Errorf("invalid array bounds %q in %s", dim, dataType)
+		}
+		// both lengths are at most 1<<32, so the product cannot overflow
+		n *= uint64(upper-lower) + 1
+		if n > maxArrayLen {
+			return nil, fmt.Errorf("too many array elements in %s", dataType)
+		}
+		a.Dims = append(a.Dims, ArrayDim{Lower: int(lower), Upper: int(upper)})
+	}
+
+	return a, nil
+}
+
+// EncodeBoolArray encodes BOOL values. TwinCAT stores an ARRAY OF BOOL
+// with one byte per element which is 0 or 1 and packed must be false
+// for it. With packed set the values are stored as bits, 8 per byte
+// starting at the least significant bit of the first byte, which is
+// the layout of a BYTE, WORD or DWORD used as a bit field and of BIT
+// members in structs.
+func EncodeBoolArray(values []bool, packed bool) []byte {
+	if !packed {
+		data := make([]byte, len(values))
+		for i, v := range values {
+			if v {
+				data[i] = 1
+			}
+		}
+		return data
+	}
+
+	data := make([]byte, (len(values)+7)/8)
+	for i, v := range values {
+		if v {
+			data[i/8] |= 1 << uint(i%8)
+		}
+	}
+	return data
+}
+
+// DecodeBoolArray decodes count BOOL values in the layout described at
+// EncodeBoolArray. It returns fewer values if data is too short.
+func DecodeBoolArray(data []byte, count int, packed bool) []bool {
+	if !packed {
+		if count > len(data) {
+			count = len(data)
+		}
+		values := make([]bool, count)
+		for i := range values {
+			values[i] = data[i] != 0
+		}
+		return values
+	}
+
+	if count > 8*len(data) {
+		count = 8 * len(data)
+	}
+	values := make([]bool, count)
+	for i := range values {
+		values[i] = data[i/8]&(1<<uint(i%8)) != 0
+	}
+	return values
+}
+
+// ReadArrayElement reads a single element of an array variable, e.g.
+// the indices 1, 2 read arr[1,2] of ARRAY [0..3,0..3] OF INT. The indices
+// are checked against the declared bounds and only the element is read
+// as with ReadPartial.
+func (s *Session) ReadArrayElement(ctx context.Context, name string, indices ...int) ([]byte, error) {
+	info, err := s.GetSymbol(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get symbol info: %w", err)
+	}
+
+	array := StructField{Name: name, DataType: info.DataType, Size: info.Size}
+	elem, err := arrayElement(&array, name, indices)
+	if err != nil {
+		return nil, err
+	}
+	return s.ReadPartial(ctx, name, elem.Offset, elem.Size)
+}
+
+// WriteArrayElement writes a single element of an array variable, e.g.
+// the indices 1, 2 write arr[1,2] of ARRAY [0..3,0..3] OF INT. The indices
+// are checked against the declared bounds and data must have the size of
+// an element. Only the element is written to the index group and offset
+// of the symbol at the offset of the element. If they are unknown the
+// whole array is read, the element replaced and the array written back.
+func (s *Session) WriteArrayElement(ctx context.Context, name string, data []byte, indices ...int) error {
+	info, err := s.GetSymbol(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to get symbol info: %w", err)
+	}
+
+	if err := s.checkWritable(name); err != nil {
+		return err
+	}
+
+	array := StructField{Name: name, DataType: info.DataType, Size: info.Size}
+	elem, err := arrayElement(&array, name, indices)
+	if err != nil {
+		return err
+	}
+	if len(data) != int(elem.Size) {
+		return fmt.Errorf("element data size mismatch: got %d want %d", len(data), elem.Size)
+	}
+
+	if info.IndexGroup == 0 {
+		all, _, err := s.Read(ctx, name)
+		if err != nil {
+			return err
+		}
+		if len(all) < int(info.Size) {
+			return fmt.Errorf("short read of %s: got %d bytes, want %d", name, len(all), info.Size)
+		}
+		copy(all[elem.Offset:], data)
+		return s.Write(ctx, name, all[:info.Size])
+	}
+
+	req := ams.NewWriteRequest(
+		s.targetAddr,
+		s.senderAddr,
+		info.IndexGroup,
+		info.IndexOffset+elem.Offset,
+		data,
+	)
+	if _, err := s.client.Write(ctx, req); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+// ReadStructArray reads an array of structs and decodes each element
+// into a map by field name like StructToMap. Multi-dimensional arrays
+// are flattened in index order. It is the counterpart of
+// WriteStructArray.
+func (s *Session) ReadStructArray(ctx context.Context, name string) ([]map[string]interface{}, error) {
+	data, info, err := s.Read(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	arr, err := ParseArrayType(info.DataType)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < int(info.Size) {
+		return nil, fmt.Errorf("short read of %s: got %d bytes, want %d", name, len(data), info.Size)
+	}
+	return decodeStructArray(ctx, arr, data[:info.Size], s.resolveType)
+}
+
+// WriteStructArray writes an array of structs from a slice of field maps
+// in a single request. Each element is encoded with EncodeStruct at its
+// offset in the array and the slice must contain an item for every
+// element of the array. Since the whole array is written, every item
+// must have all fields of the struct. Only nested structs may leave
+// fields out, which are written as zero.
+func (s *Session) WriteStructArray(ctx context.Context, name string, items []map[string]interface{}) error {
+	info, err := s.GetSymbol(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to get symbol info: %w", err)
+	}
+
+	arr, err := ParseArrayType(info.DataType)
+	if err != nil {
+		return err
+	}
+	if len(items) != arr.Len() {
+		return fmt.Errorf("%s has %d elements but got %d items", name, arr.Len(), len(items))
+	}
+
+	fields, err := s.resolveType(ctx, arr.ElementType)
+	if err != nil {
+		return fmt.Errorf("failed to get data type info: %w", err)
+	}
+	if len(fields) == 0 {
+		return fmt.Errorf("%s is not a struct", arr.ElementType)
+	}
+
+	elemSize, err := arr.elementSize(info.Size)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	data := make([]byte, info.Size)
+	for i, item := range items {
+		for _, f := range fields {
+			if _, ok := item[f.Name]; !ok {
+				return fmt.Errorf("element %d: missing field %q", i, f.Name)
+			}
+		}
+		elem, err := encodeStructFields(ctx, fields, item, elemSize, s.resolveType)
+		if err != nil {
+			return fmt.Errorf("element %d: %w", i, err)
+		}
+		copy(data[uint32(i)*elemSize:], elem)
+	}
+
+	return s.Write(ctx, name, data)
+}
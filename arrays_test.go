@@ -0,0 +1,324 @@
+package goads
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mrpasztoradam/goads/ams"
+	"github.com/mrpasztoradam/goads/goadstest"
+	"github.com/pascaldekloe/goe/verify"
+)
+
+func TestParseArrayType(t *testing.T) {
+	tests := []struct {
+		s    string
+		want *ArrayType
+		err  bool
+	}{
+		{
+			s: "ARRAY [0..9] OF ST_Step",
+			want: &ArrayType{
+				Dims:        []ArrayDim{{0, 9}},
+				ElementType: "ST_Step",
+			},
+		},
+		{
+			s: "ARRAY[1..2, -1..3] OF INT",
+			want: &ArrayType{
+				Dims:        []ArrayDim{{1, 2}, {-1, 3}},
+				ElementType: "INT",
+			},
+		},
+		{s: "INT", err: true},
+		{s: "ARRAY [0..9]", err: true},
+		{s: "ARRAY [9..0] OF INT", err: true},
+		{s: "ARRAY [a..b] OF INT", err: true},
+		{s: "ARRAY [0..2147483648] OF INT", err: true},
+		{s: "ARRAY [0..65535,0..65535] OF INT", err: true},
+		{s: "ARRAY [0..2147483647,0..2147483647,0..1] OF INT", err: true},
+		{s: "ARRAY [-2147483648..2147483647] OF BOOL", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			got, err := ParseArrayType(tt.s)
+			if tt.err {
+				if err == nil {
+					t.Fatal("want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			verify.Values(t, "", got, tt.want)
+		})
+	}
+}
+
+func TestArrayTypeLen(t *testing.T) {
+	a := &ArrayType{Dims: []ArrayDim{{1, 2}, {-1, 3}}}
+	verify.Values(t, "", a.Len(), 10)
+}
+
+func TestReadArrayElement(t *testing.T) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+
+	memory := make([]byte, 64)
+	for i := range memory {
+		memory[i] = byte(i)
+	}
+	var reads []uint32
+	srv.HandleRead(0x4040, func(req *ams.ReadRequest) ([]byte, uint32) {
+		reads = append(reads, req.IndexOffset, req.Length)
+		return memory[req.IndexOffset : req.IndexOffset+req.Length], ams.NoError
+	})
+
+	c := &Client{Addr: srv.Addr()}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	s := c.NewSession(srv.AMSAddr(), ams.MustParseAddr("10.0.0.1.1.1:32000"))
+	s.registry.Set("MAIN.aCounts", &SymbolInfo{Name: "MAIN.aCounts", DataType: "ARRAY [1..10] OF INT", Size: 20, IndexGroup: 0x4040, IndexOffset: 0x10})
+	s.registry.Set("MAIN.aGrid", &SymbolInfo{Name: "MAIN.aGrid", DataType: "ARRAY [0..1,1..3] OF DINT", Size: 24, IndexGroup: 0x4040, IndexOffset: 0x20})
+	s.registry.Set("MAIN.nCount", &SymbolInfo{Name: "MAIN.nCount", DataType: "INT", Size: 2, IndexGroup: 0x4040})
+
+	ctx := context.Background()
+	data, err := s.ReadArrayElement(ctx, "MAIN.aCounts", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "aCounts[5]", data, []byte{0x18, 0x19})
+
+	data, err = s.ReadArrayElement(ctx, "MAIN.aGrid", 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "aGrid[1,2]", data, []byte{0x30, 0x31, 0x32, 0x33})
+	verify.Values(t, "reads", reads, []uint32{0x18, 2, 0x30, 4})
+
+	for _, tt := range []struct {
+		name    string
+		indices []int
+	}{
+		{"MAIN.aCounts", []int{0}},
+		{"MAIN.aCounts", []int{11}},
+		{"MAIN.aCounts", []int{1, 1}},
+		{"MAIN.aGrid", []int{1, 0}},
+		{"MAIN.nCount", []int{0}},
+	} {
+		if _, err := s.ReadArrayElement(ctx, tt.name, tt.indices...); err == nil {
+			t.Errorf("%s%v: want error", tt.name, tt.indices)
+		}
+	}
+}
+
+func TestWriteArrayElement(t *testing.T) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+
+	memory := make([]byte, 64)
+	var writes []uint32
+	srv.HandleWrite(0x4040, func(req *ams.WriteRequest) uint32 {
+		writes = append(writes, req.IndexOffset, uint32(len(req.Data)))
+		copy(memory[req.IndexOffset:], req.Data)
+		return ams.NoError
+	})
+	// MAIN.aFlags has no index group and is only accessible by handle
+	flags := []byte{1, 2, 3, 4}
+	srv.HandleRead(0xF005, func(req *ams.ReadRequest) ([]byte, uint32) {
+		return append([]byte(nil), flags...), ams.NoError
+	})
+	srv.HandleWrite(0xF005, func(req *ams.WriteRequest) uint32 {
+		flags = append([]byte(nil), req.Data...)
+		return ams.NoError
+	})
+
+	c := &Client{Addr: srv.Addr()}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	s := c.NewSession(srv.AMSAddr(), ams.MustParseAddr("10.0.0.1.1.1:32000"))
+	s.registry.Set("MAIN.aCounts", &SymbolInfo{Name: "MAIN.aCounts", DataType: "ARRAY [1..10] OF INT", Size: 20, IndexGroup: 0x4040, IndexOffset: 0x10})
+	s.registry.Set("MAIN.aGrid", &SymbolInfo{Name: "MAIN.aGrid", DataType: "ARRAY [0..1,1..3] OF DINT", Size: 24, IndexGroup: 0x4040, IndexOffset: 0x20})
+	s.registry.Set("MAIN.aFlags", &SymbolInfo{Name: "MAIN.aFlags", DataType: "ARRAY [0..3] OF BYTE", Size: 4, Handle: 1})
+
+	ctx := context.Background()
+	if err := s.WriteArrayElement(ctx, "MAIN.aCounts", []byte{0xaa, 0xbb}, 5); err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "aCounts[5]", memory[0x18:0x1a], []byte{0xaa, 0xbb})
+
+	if err := s.WriteArrayElement(ctx, "MAIN.aGrid", []byte{1, 2, 3, 4}, 1, 2); err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "aGrid[1,2]", memory[0x30:0x34], []byte{1, 2, 3, 4})
+	verify.Values(t, "writes", writes, []uint32{0x18, 2, 0x30, 4})
+
+	if err := s.WriteArrayElement(ctx, "MAIN.aFlags", []byte{9}, 2); err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "aFlags", flags, []byte{1, 2, 9, 4})
+
+	for _, tt := range []struct {
+		name    string
+		data    []byte
+		indices []int
+	}{
+		{"MAIN.aCounts", []byte{1, 2}, []int{0}},
+		{"MAIN.aCounts", []byte{1, 2}, []int{11}},
+		{"MAIN.aCounts", []byte{1, 2}, []int{1, 1}},
+		{"MAIN.aCounts", []byte{1}, []int{1}},
+		{"MAIN.aCounts", []byte{1, 2, 3}, []int{1}},
+		{"MAIN.aGrid", []byte{1, 2, 3, 4}, []int{1, 0}},
+	} {
+		if err := s.WriteArrayElement(ctx, tt.name, tt.data, tt.indices...); err == nil {
+			t.Errorf("%s%v with %d bytes: want error", tt.name, tt.indices, len(tt.data))
+		}
+	}
+	verify.Values(t, "writes after errors", len(writes), 4)
+}
+
+func TestReadStructArray(t *testing.T) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+
+	// ST_Motor is a two-field struct with 2 bytes of padding after nId
+	motors := []byte{
+		1, 0, 0xff, 0xff, 10, 0, 0, 0,
+		2, 0, 0xff, 0xff, 20, 0, 0, 0,
+		3, 0, 0xff, 0xff, 30, 0, 0, 0,
+	}
+	srv.HandleRead(0xF005, func(req *ams.ReadRequest) ([]byte, uint32) {
+		if req.IndexOffset == 2 {
+			return append([]byte{7, 0}, motors[:16]...), ams.NoError
+		}
+		return motors, ams.NoError
+	})
+
+	c := &Client{Addr: srv.Addr()}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	s := c.NewSession(srv.AMSAddr(), ams.MustParseAddr("10.0.0.1.1.1:32000"))
+	s.registry.Set("MAIN.aMotors", &SymbolInfo{Name: "MAIN.aMotors", DataType: "ARRAY [1..3] OF ST_Motor", Size: 24, Handle: 1})
+	s.registry.Set("MAIN.stLine", &SymbolInfo{Name: "MAIN.stLine", DataType: "ST_Line", Size: 18, Handle: 2})
+	s.types.Set("ST_Motor", &DataTypeInfo{Name: "ST_Motor", Fields: []StructField{
+		{Name: "nId", DataType: "INT", Offset: 0, Size: 2},
+		{Name: "nSpeed", DataType: "DINT", Offset: 4, Size: 4},
+	}})
+	s.types.Set("ST_Line", &DataTypeInfo{Name: "ST_Line", Fields: []StructField{
+		{Name: "nCount", DataType: "INT", Offset: 0, Size: 2},
+		{Name: "aMotors", DataType: "ARRAY [0..1] OF ST_Motor", Offset: 2, Size: 16},
+	}})
+
+	ctx := context.Background()
+	items, err := s.ReadStructArray(ctx, "MAIN.aMotors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "array", items, []map[string]interface{}{
+		{"nId": int16(1), "nSpeed": int32(10)},
+		{"nId": int16(2), "nSpeed": int32(20)},
+		{"nId": int16(3), "nSpeed": int32(30)},
+	})
+
+	fields, err := s.ReadStruct(ctx, "MAIN.stLine")
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "struct", StructToMap(fields), map[string]interface{}{
+		"nCount": int16(7),
+		"aMotors": []map[string]interface{}{
+			{"nId": int16(1), "nSpeed": int32(10)},
+			{"nId": int16(2), "nSpeed": int32(20)},
+		},
+	})
+}
+
+func TestWriteStructArray(t *testing.T) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+
+	var written []byte
+	srv.HandleWrite(0xF005, func(req *ams.WriteRequest) uint32 {
+		written = req.Data
+		return ams.NoError
+	})
+
+	c := &Client{Addr: srv.Addr()}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	s := c.NewSession(srv.AMSAddr(), ams.MustParseAddr("10.0.0.1.1.1:32000"))
+	s.registry.Set("MAIN.aMotors", &SymbolInfo{Name: "MAIN.aMotors", DataType: "ARRAY [1..3] OF ST_Motor", Size: 24, Handle: 1})
+	s.types.Set("ST_Motor", &DataTypeInfo{Name: "ST_Motor", Fields: []StructField{
+		{Name: "nId", DataType: "INT", Offset: 0, Size: 2},
+		{Name: "nSpeed", DataType: "DINT", Offset: 4, Size: 4},
+	}})
+
+	ctx := context.Background()
+	err := s.WriteStructArray(ctx, "MAIN.aMotors", []map[string]interface{}{
+		{"nId": 1, "nSpeed": 10},
+		{"nId": 2, "nSpeed": 20},
+		{"nId": 3, "nSpeed": -1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the elements have a stride of 8 with padding after nId
+	verify.Values(t, "written", written, []byte{
+		1, 0, 0, 0, 10, 0, 0, 0,
+		2, 0, 0, 0, 20, 0, 0, 0,
+		3, 0, 0, 0, 0xff, 0xff, 0xff, 0xff,
+	})
+
+	written = nil
+	err = s.WriteStructArray(ctx, "MAIN.aMotors", []map[string]interface{}{
+		{"nId": 1, "nSpeed": 10},
+		{"nId": 2},
+		{"nId": 3, "nSpeed": 30},
+	})
+	if err == nil {
+		t.Fatal("got no error for a missing field")
+	}
+	verify.Values(t, "written with missing field", written, []byte(nil))
+
+	err = s.WriteStructArray(ctx, "MAIN.aMotors", []map[string]interface{}{
+		{"nId": 1, "nSpeed": 10},
+	})
+	if err == nil {
+		t.Fatal("got no error for a missing element")
+	}
+}
+
+func TestBoolArray(t *testing.T) {
+	values := []bool{true, false, true, true, false, false, false, false, true, true}
+
+	unpacked := EncodeBoolArray(values, false)
+	verify.Values(t, "unpacked", unpacked, []byte{1, 0, 1, 1, 0, 0, 0, 0, 1, 1})
+	verify.Values(t, "decode unpacked", DecodeBoolArray(unpacked, len(values), false), values)
+
+	packed := EncodeBoolArray(values, true)
+	verify.Values(t, "packed", packed, []byte{0x0d, 0x03})
+	verify.Values(t, "decode packed", DecodeBoolArray(packed, len(values), true), values)
+
+	verify.Values(t, "short unpacked", DecodeBoolArray([]byte{1, 2}, 3, false), []bool{true, true})
+	verify.Values(t, "short packed", DecodeBoolArray([]byte{0x80}, 10, true), []bool{false, false, false, false, false, false, false, true})
+
+	verify.Values(t, "DecodeFieldValue", DecodeFieldValue([]byte{0, 1, 1}, "ARRAY [1..3] OF BOOL"), []bool{false, true, true})
+	b, err := ValueToJSON([]bool{false, true}, "ARRAY [0..1] OF BOOL")
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "ValueToJSON", string(b), "[false,true]")
+}
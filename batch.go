@@ -0,0 +1,549 @@
+package goads
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/mrpasztoradam/goads/ams"
+)
+
+// BatchReadResult represents the result of a batch read operation
+type BatchReadResult struct {
+	Name  string
+	Data  []byte
+	Error error
+}
+
+// BatchWriteResult represents the result of a batch write operation
+type BatchWriteResult struct {
+	Name    string
+	Success bool
+	Error   error
+}
+
+// ReadBatch reads multiple variables in a single ADS request
+// This is much more efficient than reading variables one by one
+func (s *Session) ReadBatch(ctx context.Context, names []string) (map[string]*BatchReadResult, error) {
+	if len(names) == 0 {
+		return make(map[string]*BatchReadResult), nil
+	}
+
+	results := make(map[string]*BatchReadResult, len(names))
+
+	// Get handles and symbol info for all variables
+	type varInfo struct {
+		name   string
+		handle uint32
+		size   uint32
+	}
+	vars := make([]varInfo, 0, len(names))
+
+	for _, name := range names {
+		// Get symbol info (from cache or fetch)
+		info, err := s.GetSymbol(ctx, name)
+		if err != nil {
+			results[name] = &BatchReadResult{
+				Name:  name,
+				Error: fmt.Errorf("failed to get symbol info: %w", err),
+			}
+			continue
+		}
+
+		// Get handle (from cache or fetch)
+		handle, err := s.getOrCreateHandle(ctx, name)
+		if err != nil {
+			results[name] = &BatchReadResult{
+				Name:  name,
+				Error: fmt.Errorf("failed to get handle: %w", err),
+			}
+			continue
+		}
+
+		vars = append(vars, varInfo{
+			name:   name,
+			handle: handle,
+			size:   info.Size,
+		})
+	}
+
+	// Build sum-up read request
+	// Format: [indexGroup][indexOffset][readLength] * N
+	requestData := make([]byte, len(vars)*12)
+	offset := 0
+	readLength := uint32(0)
+
+	for _, v := range vars {
+		binary.LittleEndian.PutUint32(requestData[offset:], 0xF005)     // ADSIGRP_SYM_VALBYHND
+		binary.LittleEndian.PutUint32(requestData[offset+4:], v.handle) // handle as offset
+		binary.LittleEndian.PutUint32(requestData[offset+8:], v.size)   // read length
+		offset += 12
+		readLength += 4 + v.size
+	}
+
+	// Execute sum-up read (0xF080 = ADSIGRP_SUMUP_READ)
+	req := ams.NewReadWriteRequest(
+		s.targetAddr,
+		s.senderAddr,
+		0xF080, // ADSIGRP_SUMUP_READ
+		uint32(len(vars)),
+		readLength,
+		requestData,
+	)
+
+	resp, err := s.client.ReadWrite(ctx, req)
+	if err != nil {
+		// If batch read fails, fall back to individual reads
+		for _, v := range vars {
+			data, _, readErr := s.Read(ctx, v.name)
+			results[v.name] = &BatchReadResult{
+				Name:  v.name,
+				Data:  data,
+				Error: readErr,
+			}
+		}
+		return results, nil
+	}
+
+	// Parse sum-up response
+	// Format: [errorCode] * N followed by [data] * N
+	dataOffset := len(vars) * 4
+	for i, v := range vars {
+		if i*4+4 > len(resp.Data) {
+			results[v.name] = &BatchReadResult{
+				Name:  v.name,
+				Error: fmt.Errorf("response too short"),
+			}
+			continue
+		}
+
+		errorCode := binary.LittleEndian.Uint32(resp.Data[i*4:])
+		dataStart := dataOffset
+		dataOffset += int(v.size)
+
+		if errorCode != 0 {
+			results[v.name] = &BatchReadResult{
+				Name:  v.name,
+				Error: ams.ADSError(errorCode),
+			}
+			continue
+		}
+
+		if dataOffset > len(resp.Data) {
+			results[v.name] = &BatchReadResult{
+				Name:  v.name,
+				Error: fmt.Errorf("data length exceeds response"),
+			}
+			continue
+		}
+
+		data := make([]byte, v.size)
+		copy(data, resp.Data[dataStart:dataOffset])
+
+		results[v.name] = &BatchReadResult{
+			Name:  v.name,
+			Data:  data,
+			Error: nil,
+		}
+	}
+
+	return results, nil
+}
+
+// SumRead reads multiple variables with a single ADS sum-up read in which
+// every variable reports its own result and returned length, so that
+// variables which fail, e.g. because they were deleted from the PLC, do
+// not shift the data of the following ones. It returns the values of
+// the variables which were read and the errors of the others by name.
+// The returned error is only set if the whole request failed. PLCs
+// without ADSIGRP_SUMUP_READEX are read with ReadBatch.
+func (s *Session) SumRead(ctx context.Context, names []string) (map[string][]byte, map[string]error, error) {
+	values := make(map[string][]byte, len(names))
+	errs := make(map[string]error)
+
+	var read []string
+	var ops []SumOp
+	for _, name := range names {
+		info, err := s.GetSymbol(ctx, name)
+		if err != nil {
+			errs[name] = fmt.Errorf("failed to get symbol info: %w", err)
+			continue
+		}
+		handle, err := s.getOrCreateHandle(ctx, name)
+		if err != nil {
+			errs[name] = fmt.Errorf("failed to get handle: %w", err)
+			continue
+		}
+		read = append(read, name)
+		ops = append(ops, SumOp{
+			IndexGroup:  0xF005, // ADSIGRP_SYM_VALBYHND
+			IndexOffset: handle,
+			ReadLength:  info.Size,
+		})
+	}
+	if len(ops) == 0 {
+		return values, errs, nil
+	}
+
+	// Format: [indexGroup][indexOffset][readLength] * N
+	requestData := make([]byte, len(ops)*12)
+	readLength := uint32(0)
+	for i, op := range ops {
+		binary.LittleEndian.PutUint32(requestData[i*12:], op.IndexGroup)
+		binary.LittleEndian.PutUint32(requestData[i*12+4:], op.IndexOffset)
+		binary.LittleEndian.PutUint32(requestData[i*12+8:], op.ReadLength)
+		readLength += 8 + op.ReadLength
+	}
+
+	req := ams.NewReadWriteRequest(
+		s.targetAddr,
+		s.senderAddr,
+		0xF083, // ADSIGRP_SUMUP_READEX
+		uint32(len(ops)),
+		readLength,
+		requestData,
+	)
+	resp, err := s.client.ReadWrite(ctx, req)
+	if errors.Is(err, ams.ADSError(ams.DeviceServiceNotSupported)) {
+		results, err := s.ReadBatch(ctx, read)
+		if err != nil {
+			return nil, nil, err
+		}
+		for name, res := range results {
+			if res.Error != nil {
+				errs[name] = res.Error
+				continue
+			}
+			values[name] = res.Data
+		}
+		return values, errs, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to execute sum read: %w", err)
+	}
+
+	// the response has the same format as a sum-up read-write
+	results, err := decodeSumReadWrite(resp.Data, ops)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i, res := range results {
+		if res.Error != nil {
+			errs[read[i]] = res.Error
+			continue
+		}
+		values[read[i]] = res.Data
+	}
+	return values, errs, nil
+}
+
+// WriteBatch writes multiple variables in a single ADS request
+// This is much more efficient than writing variables one by one
+func (s *Session) WriteBatch(ctx context.Context, writes map[string][]byte) (map[string]*BatchWriteResult, error) {
+	if len(writes) == 0 {
+		return make(map[string]*BatchWriteResult), nil
+	}
+
+	results := make(map[string]*BatchWriteResult, len(writes))
+
+	// Get handles for all variables
+	type varInfo struct {
+		name   string
+		handle uint32
+		data   []byte
+	}
+	vars := make([]varInfo, 0, len(writes))
+
+	for name, data := range writes {
+		if err := s.checkWritable(name); err != nil {
+			results[name] = &BatchWriteResult{
+				Name:    name,
+				Success: false,
+				Error:   err,
+			}
+			continue
+		}
+
+		// Get handle (from cache or fetch)
+		handle, err := s.getOrCreateHandle(ctx, name)
+		if err != nil {
+			results[name] = &BatchWriteResult{
+				Name:    name,
+				Success: false,
+				Error:   fmt.Errorf("failed to get handle: %w", err),
+			}
+			continue
+		}
+
+		vars = append(vars, varInfo{
+			name:   name,
+			handle: handle,
+			data:   data,
+		})
+	}
+
+	// Build sum-up write request
+	// Format: [indexGroup][indexOffset][writeLength][data] * N
+	totalSize := 0
+	for _, v := range vars {
+		totalSize += 12 + len(v.data)
+	}
+
+	requestData := make([]byte, totalSize)
+	offset := 0
+
+	for _, v := range vars {
+		binary.LittleEndian.PutUint32(requestData[offset:], 0xF005)                // ADSIGRP_SYM_VALBYHND
+		binary.LittleEndian.PutUint32(requestData[offset+4:], v.handle)            // handle as offset
+		binary.LittleEndian.PutUint32(requestData[offset+8:], uint32(len(v.data))) // write length
+		offset += 12
+		copy(requestData[offset:], v.data)
+		offset += len(v.data)
+	}
+
+	// Execute sum-up write (0xF081 = ADSIGRP_SUMUP_WRITE)
+	req := ams.NewReadWriteRequest(
+		s.targetAddr,
+		s.senderAddr,
+		0xF081, // ADSIGRP_SUMUP_WRITE
+		uint32(len(vars)),
+		uint32(len(vars)*4), // response size (error codes)
+		requestData,
+	)
+
+	resp, err := s.client.ReadWrite(ctx, req)
+	if err != nil {
+		// If batch write fails, fall back to individual writes
+		for _, v := range vars {
+			writeErr := s.Write(ctx, v.name, v.data)
+			results[v.name] = &BatchWriteResult{
+				Name:    v.name,
+				Success: writeErr == nil,
+				Error:   writeErr,
+			}
+		}
+		return results, nil
+	}
+
+	// Parse sum-up response
+	// Format: [errorCode] * N
+	for i, v := range vars {
+		if i*4+4 > len(resp.Data) {
+			results[v.name] = &BatchWriteResult{
+				Name:    v.name,
+				Success: false,
+				Error:   fmt.Errorf("response too short"),
+			}
+			continue
+		}
+
+		errorCode := binary.LittleEndian.Uint32(resp.Data[i*4:])
+		if errorCode != 0 {
+			results[v.name] = &BatchWriteResult{
+				Name:    v.name,
+				Success: false,
+				Error:   ams.ADSError(errorCode),
+			}
+		} else {
+			results[v.name] = &BatchWriteResult{
+				Name:    v.name,
+				Success: true,
+				Error:   nil,
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// SumOp is a single operation of a SumReadWrite request
+type SumOp struct {
+	IndexGroup  uint32
+	IndexOffset uint32
+	ReadLength  uint32 // maximum number of bytes to read
+	WriteData   []byte
+}
+
+// SumResult is the result of a single SumReadWrite operation
+type SumResult struct {
+	Data  []byte // data read, at most ReadLength bytes
+	Error error
+}
+
+// SumReadWrite executes multiple ReadWrite operations with a single
+// ADS sum-up read-write request. The results are in the order of the
+// operations. The returned error is only set if the whole request
+// failed; errors of individual operations are in their result.
+func (s *Session) SumReadWrite(ctx context.Context, ops []SumOp) ([]SumResult, error) {
+	if len(ops) == 0 {
+		return nil, nil
+	}
+
+	requestData, readLength := encodeSumReadWrite(ops)
+
+	// Execute sum-up read-write (0xF082 = ADSIGRP_SUMUP_READWRITE)
+	req := ams.NewReadWriteRequest(
+		s.targetAddr,
+		s.senderAddr,
+		0xF082, // ADSIGRP_SUMUP_READWRITE
+		uint32(len(ops)),
+		readLength,
+		requestData,
+	)
+	resp, err := s.client.ReadWrite(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute sum read-write: %w", err)
+	}
+
+	return decodeSumReadWrite(resp.Data, ops)
+}
+
+// encodeSumReadWrite encodes the data of a sum-up read-write request
+// and returns it with the read length of the request.
+func encodeSumReadWrite(ops []SumOp) ([]byte, uint32) {
+	// Format: [indexGroup][indexOffset][readLength][writeLength] * N
+	// followed by [data] * N
+	headerSize := len(ops) * 16
+	totalSize := headerSize
+	readLength := uint32(0)
+	for _, op := range ops {
+		totalSize += len(op.WriteData)
+		readLength += 8 + op.ReadLength
+	}
+
+	requestData := make([]byte, totalSize)
+	dataOffset := headerSize
+	for i, op := range ops {
+		offset := i * 16
+		binary.LittleEndian.PutUint32(requestData[offset:], op.IndexGroup)
+		binary.LittleEndian.PutUint32(requestData[offset+4:], op.IndexOffset)
+		binary.LittleEndian.PutUint32(requestData[offset+8:], op.ReadLength)
+		binary.LittleEndian.PutUint32(requestData[offset+12:], uint32(len(op.WriteData)))
+		copy(requestData[dataOffset:], op.WriteData)
+		dataOffset += len(op.WriteData)
+	}
+
+	return requestData, readLength
+}
+
+// decodeSumReadWrite decodes the response data of a sum-up read-write
+// request with ops.
+func decodeSumReadWrite(data []byte, ops []SumOp) ([]SumResult, error) {
+	// Format: [errorCode][length] * N followed by [data] * N
+	// where the data blocks have the returned lengths
+	if len(data) < len(ops)*8 {
+		return nil, fmt.Errorf("sum read-write response too short (length: %d)", len(data))
+	}
+
+	results := make([]SumResult, len(ops))
+	dataOffset := len(ops) * 8
+	for i, op := range ops {
+		errorCode := binary.LittleEndian.Uint32(data[i*8:])
+		length := binary.LittleEndian.Uint32(data[i*8+4:])
+
+		dataStart := dataOffset
+		dataOffset += int(length)
+		switch {
+		case length > op.ReadLength:
+			results[i].Error = fmt.Errorf("returned length %d exceeds read length %d", length, op.ReadLength)
+		case dataOffset > len(data):
+			results[i].Error = fmt.Errorf("data length exceeds response")
+		case errorCode != 0:
+			results[i].Error = ams.ADSError(errorCode)
+		default:
+			results[i].Data = make([]byte, length)
+			copy(results[i].Data, data[dataStart:dataOffset])
+		}
+	}
+
+	return results, nil
+}
+
+// ReadAll reads the values of all cached symbols with a single sum-up read
+// and decodes them by their data type. Structs and arrays are skipped.
+//
+// Symbols which fail to read are left out of the result and the first
+// error is returned along with the values which could be read.
+func (s *Session) ReadAll(ctx context.Context) (map[string]interface{}, error) {
+	values, names, errs, err := s.readAll(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	return values, firstError(names, errs)
+}
+
+// ReadAllRecursive is like ReadAll but also decodes structs.
+// The value of a struct is its []StructField with populated values.
+func (s *Session) ReadAllRecursive(ctx context.Context) (map[string]interface{}, error) {
+	values, names, errs, err := s.readAll(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	return values, firstError(names, errs)
+}
+
+// firstError returns the error of the first name which has one.
+func firstError(names []string, errs map[string]error) error {
+	for _, name := range names {
+		if err := errs[name]; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readAll reads the cached symbols and returns the values, the sorted
+// names of the symbols read and the errors of the symbols which failed.
+func (s *Session) readAll(ctx context.Context, recursive bool) (values map[string]interface{}, names []string, errs map[string]error, err error) {
+	allSymbols := s.registry.GetAll()
+
+	names = make([]string, 0, len(allSymbols))
+	for name, info := range allSymbols {
+		if info.DataType == "" {
+			continue
+		}
+		if !recursive && !IsPrimitiveType(info.DataType) {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	results, err := s.ReadBatch(ctx, names)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	values = make(map[string]interface{}, len(results))
+	errs = make(map[string]error)
+	for _, name := range names {
+		res := results[name]
+		if res.Error != nil {
+			errs[name] = fmt.Errorf("failed to read %s: %w", name, res.Error)
+			continue
+		}
+
+		info := allSymbols[name]
+		if IsPrimitiveType(info.DataType) {
+			v, err := DecodeFieldValueStrict(res.Data, info.DataType)
+			if err != nil {
+				errs[name] = fmt.Errorf("failed to decode %s: %w", name, err)
+				continue
+			}
+			values[name] = v
+			continue
+		}
+
+		fields, err := s.decodeStruct(ctx, info.DataType, res.Data)
+		if err != nil {
+			// not a struct so decode it as a plain value
+			values[name] = DecodeFieldValue(res.Data, info.DataType)
+			continue
+		}
+		values[name] = fields
+	}
+
+	return values, names, errs, nil
+}
@@ -0,0 +1,172 @@
+package goads
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/mrpasztoradam/goads/ams"
+	"github.com/mrpasztoradam/goads/goadstest"
+	"github.com/pascaldekloe/goe/verify"
+)
+
+func TestSumReadWrite(t *testing.T) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+
+	// 0x4020 is writable memory which returns the data after the write
+	memory := make([]byte, 16)
+	srv.HandleReadWrite(0xF082, func(req *ams.ReadWriteRequest) ([]byte, uint32) {
+		n := int(req.IndexOffset)
+		results := make([]byte, 8*n)
+		var data []byte
+		writeOffset := 16 * n
+		for i := 0; i < n; i++ {
+			group := binary.LittleEndian.Uint32(req.Data[i*16:])
+			offset := binary.LittleEndian.Uint32(req.Data[i*16+4:])
+			readLen := binary.LittleEndian.Uint32(req.Data[i*16+8:])
+			writeLen := binary.LittleEndian.Uint32(req.Data[i*16+12:])
+			write := req.Data[writeOffset : writeOffset+int(writeLen)]
+			writeOffset += int(writeLen)
+
+			if group != 0x4020 {
+				binary.LittleEndian.PutUint32(results[i*8:], ams.DeviceServiceNotSupported)
+				continue
+			}
+			copy(memory[offset:], write)
+			binary.LittleEndian.PutUint32(results[i*8+4:], readLen)
+			data = append(data, memory[offset:offset+readLen]...)
+		}
+		return append(results, data...), ams.NoError
+	})
+
+	c := &Client{Addr: srv.Addr()}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	s := c.NewSession(srv.AMSAddr(), ams.MustParseAddr("10.0.0.1.1.1:32000"))
+
+	results, err := s.SumReadWrite(context.Background(), []SumOp{
+		{IndexGroup: 0x4020, IndexOffset: 0, ReadLength: 2, WriteData: []byte{1, 2}},
+		{IndexGroup: 0x4040, IndexOffset: 0, ReadLength: 4},
+		{IndexGroup: 0x4020, IndexOffset: 4, ReadLength: 4, WriteData: []byte{3}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "results", results, []SumResult{
+		{Data: []byte{1, 2}},
+		{Error: ams.ADSError(ams.DeviceServiceNotSupported)},
+		{Data: []byte{3, 0, 0, 0}},
+	})
+}
+
+func TestSumRead(t *testing.T) {
+	// values by handle, handle 2 was deleted from the PLC
+	values := map[uint32][]byte{1: {1, 0}, 3: {3, 0, 0, 0}}
+
+	srv := goadstest.NewServer()
+	defer srv.Close()
+	srv.HandleReadWrite(0xF083, func(req *ams.ReadWriteRequest) ([]byte, uint32) {
+		n := int(req.IndexOffset)
+		results := make([]byte, 8*n)
+		var data []byte
+		for i := 0; i < n; i++ {
+			handle := binary.LittleEndian.Uint32(req.Data[i*12+4:])
+			v, ok := values[handle]
+			if !ok {
+				binary.LittleEndian.PutUint32(results[i*8:], ams.DeviceInvalidHandle)
+				continue
+			}
+			binary.LittleEndian.PutUint32(results[i*8+4:], uint32(len(v)))
+			data = append(data, v...)
+		}
+		return append(results, data...), ams.NoError
+	})
+
+	c := &Client{Addr: srv.Addr()}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	s := c.NewSession(srv.AMSAddr(), ams.MustParseAddr("10.0.0.1.1.1:32000"))
+	s.registry.Set("MAIN.nFirst", &SymbolInfo{Name: "MAIN.nFirst", DataType: "INT", Size: 2, Handle: 1})
+	s.registry.Set("MAIN.nDeleted", &SymbolInfo{Name: "MAIN.nDeleted", DataType: "LREAL", Size: 8, Handle: 2})
+	s.registry.Set("MAIN.nLast", &SymbolInfo{Name: "MAIN.nLast", DataType: "DINT", Size: 4, Handle: 3})
+
+	got, errs, err := s.SumRead(context.Background(), []string{"MAIN.nFirst", "MAIN.nDeleted", "MAIN.nLast"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "values", got, map[string][]byte{
+		"MAIN.nFirst": {1, 0},
+		"MAIN.nLast":  {3, 0, 0, 0},
+	})
+	verify.Values(t, "errors", errs, map[string]error{
+		"MAIN.nDeleted": ams.ADSError(ams.DeviceInvalidHandle),
+	})
+}
+
+func TestSumReadFallback(t *testing.T) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+	// only ADSIGRP_SUMUP_READ, which returns the data at the requested lengths
+	srv.HandleReadWrite(0xF080, func(req *ams.ReadWriteRequest) ([]byte, uint32) {
+		return []byte{0, 0, 0, 0, 0x12, 0x34}, ams.NoError
+	})
+
+	c := &Client{Addr: srv.Addr()}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	s := c.NewSession(srv.AMSAddr(), ams.MustParseAddr("10.0.0.1.1.1:32000"))
+	s.registry.Set("MAIN.nCount", &SymbolInfo{Name: "MAIN.nCount", DataType: "INT", Size: 2, Handle: 1})
+
+	got, errs, err := s.SumRead(context.Background(), []string{"MAIN.nCount"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "values", got, map[string][]byte{"MAIN.nCount": {0x12, 0x34}})
+	verify.Values(t, "errors", errs, map[string]error{})
+}
+
+func TestReadAll(t *testing.T) {
+	// handle 3 was deleted from the PLC
+	srv := newValueServer(map[uint32][]byte{1: {5, 0}, 2: {7, 0}})
+	defer srv.Close()
+
+	c := &Client{Addr: srv.Addr()}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	s := c.NewSession(srv.AMSAddr(), ams.MustParseAddr("10.0.0.1.1.1:32000"))
+	s.registry.Set("MAIN.nCount", &SymbolInfo{Name: "MAIN.nCount", DataType: "INT", Size: 2, Handle: 1})
+	s.registry.Set("MAIN.stPos", &SymbolInfo{Name: "MAIN.stPos", DataType: "ST_Pos", Size: 2, Handle: 2})
+	s.registry.Set("MAIN.nGone", &SymbolInfo{Name: "MAIN.nGone", DataType: "INT", Size: 2, Handle: 3})
+	s.types.Set("ST_Pos", &DataTypeInfo{Name: "ST_Pos", Fields: []StructField{
+		{Name: "X", DataType: "INT", Offset: 0, Size: 2},
+	}})
+
+	values, err := s.ReadAll(context.Background())
+	if !errors.Is(err, ams.ADSError(ams.DeviceInvalidHandle)) {
+		t.Errorf("got error %v want %v", err, ams.ADSError(ams.DeviceInvalidHandle))
+	}
+	verify.Values(t, "values", values, map[string]interface{}{
+		"MAIN.nCount": int16(5),
+	})
+
+	values, err = s.ReadAllRecursive(context.Background())
+	if !errors.Is(err, ams.ADSError(ams.DeviceInvalidHandle)) {
+		t.Errorf("got recursive error %v want %v", err, ams.ADSError(ams.DeviceInvalidHandle))
+	}
+	verify.Values(t, "recursive values", values, map[string]interface{}{
+		"MAIN.nCount": int16(5),
+		"MAIN.stPos": []StructField{
+			{Name: "X", DataType: "INT", Offset: 0, Size: 2, Value: int16(7)},
+		},
+	})
+}
@@ -0,0 +1,89 @@
+package goads
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mrpasztoradam/goads/ams"
+)
+
+// bitGroups maps the I/O image byte index groups to their bit index groups
+var bitGroups = map[uint32]uint32{
+	0xF020: 0xF021, // ADSIGRP_IOIMAGE_RWIB -> ADSIGRP_IOIMAGE_RWIX
+	0xF030: 0xF031, // ADSIGRP_IOIMAGE_RWOB -> ADSIGRP_IOIMAGE_RWOX
+}
+
+// ReadBit reads a single bit of the byte at the index group and byte offset.
+// For the process image index groups 0xF020 and 0xF030 the bit is read
+// with the corresponding bit index group.
+func (s *Session) ReadBit(ctx context.Context, indexGroup, byteOffset, bitOffset uint32) (bool, error) {
+	if bitOffset > 7 {
+		return false, fmt.Errorf("invalid bit offset: %d", bitOffset)
+	}
+
+	if bitGroup, ok := bitGroups[indexGroup]; ok {
+		b, err := s.readByte(ctx, bitGroup, byteOffset*8+bitOffset)
+		if err != nil {
+			return false, err
+		}
+		return b != 0, nil
+	}
+
+	b, err := s.readByte(ctx, indexGroup, byteOffset)
+	if err != nil {
+		return false, err
+	}
+	return b&(1<<bitOffset) != 0, nil
+}
+
+// WriteBit writes a single bit of the byte at the index group and byte offset.
+// For the process image index groups 0xF020 and 0xF030 the bit is written
+// with the corresponding bit index group.
+//
+// For all other index groups WriteBit reads the byte, modifies the bit and
+// writes the byte back. This is not atomic and concurrent changes to the
+// other bits of the byte between the read and the write are lost.
+func (s *Session) WriteBit(ctx context.Context, indexGroup, byteOffset, bitOffset uint32, value bool) error {
+	if bitOffset > 7 {
+		return fmt.Errorf("invalid bit offset: %d", bitOffset)
+	}
+
+	if bitGroup, ok := bitGroups[indexGroup]; ok {
+		var b byte
+		if value {
+			b = 1
+		}
+		return s.writeByte(ctx, bitGroup, byteOffset*8+bitOffset, b)
+	}
+
+	b, err := s.readByte(ctx, indexGroup, byteOffset)
+	if err != nil {
+		return err
+	}
+	if value {
+		b |= 1 << bitOffset
+	} else {
+		b &^= 1 << bitOffset
+	}
+	return s.writeByte(ctx, indexGroup, byteOffset, b)
+}
+
+func (s *Session) readByte(ctx context.Context, indexGroup, indexOffset uint32) (byte, error) {
+	req := ams.NewReadRequest(s.targetAddr, s.senderAddr, indexGroup, indexOffset, 1)
+	resp, err := s.client.Read(ctx, req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read 0x%x:0x%x: %w", indexGroup, indexOffset, err)
+	}
+	if len(resp.Data) < 1 {
+		return 0, fmt.Errorf("failed to read 0x%x:0x%x: empty response", indexGroup, indexOffset)
+	}
+	return resp.Data[0], nil
+}
+
+func (s *Session) writeByte(ctx context.Context, indexGroup, indexOffset uint32, b byte) error {
+	req := ams.NewWriteRequest(s.targetAddr, s.senderAddr, indexGroup, indexOffset, []byte{b})
+	if _, err := s.client.Write(ctx, req); err != nil {
+		return fmt.Errorf("failed to write 0x%x:0x%x: %w", indexGroup, indexOffset, err)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package goads
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mrpasztoradam/goads/ams"
+	"github.com/mrpasztoradam/goads/goadstest"
+	"github.com/pascaldekloe/goe/verify"
+)
+
+func TestReadWriteBit(t *testing.T) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+
+	// byte addressed memory with 0x4020 and bit addressed outputs with 0xF031
+	memory := []byte{0x00, 0x0F}
+	outputs := make(map[uint32]byte)
+	srv.HandleRead(0x4020, func(req *ams.ReadRequest) ([]byte, uint32) {
+		return []byte{memory[req.IndexOffset]}, ams.NoError
+	})
+	srv.HandleWrite(0x4020, func(req *ams.WriteRequest) uint32 {
+		memory[req.IndexOffset] = req.Data[0]
+		return ams.NoError
+	})
+	srv.HandleRead(0xF031, func(req *ams.ReadRequest) ([]byte, uint32) {
+		return []byte{outputs[req.IndexOffset]}, ams.NoError
+	})
+	srv.HandleWrite(0xF031, func(req *ams.WriteRequest) uint32 {
+		outputs[req.IndexOffset] = req.Data[0]
+		return ams.NoError
+	})
+
+	c := &Client{Addr: srv.Addr()}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	s := c.NewSession(srv.AMSAddr(), ams.MustParseAddr("10.0.0.1.1.1:32000"))
+	ctx := context.Background()
+
+	v, err := s.ReadBit(ctx, 0x4020, 1, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "bit 1.3", v, true)
+
+	if err := s.WriteBit(ctx, 0x4020, 1, 3, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.WriteBit(ctx, 0x4020, 1, 7, true); err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "memory", memory, []byte{0x00, 0x87})
+
+	if err := s.WriteBit(ctx, 0xF030, 2, 5, true); err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "outputs", outputs, map[uint32]byte{21: 1})
+	v, err = s.ReadBit(ctx, 0xF030, 2, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "output 2.5", v, true)
+
+	if _, err := s.ReadBit(ctx, 0x4020, 0, 8); err == nil {
+		t.Fatal("want error for bit offset 8")
+	}
+	if _, err := s.ReadBit(ctx, 0x4040, 0, 0); err == nil {
+		t.Fatal("want error for unsupported index group")
+	}
+}
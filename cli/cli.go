@@ -0,0 +1,174 @@
+// Package cli implements a small command-line tool for reading and
+// writing PLC symbols. It is a library so that it can be embedded
+// in other programs or wrapped in a custom main:
+//
+//	func main() {
+//		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+//		defer stop()
+//		if err := cli.RunContext(ctx, os.Args[1:], os.Stdout); err != nil {
+//			fmt.Fprintln(os.Stderr, err)
+//			os.Exit(1)
+//		}
+//	}
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/mrpasztoradam/goads"
+	"github.com/mrpasztoradam/goads/ams"
+)
+
+const usage = `usage: goads [flags] <command> [args]
+
+commands:
+  read <symbol>           print the value of a symbol
+  write <symbol> <value>  write a value to a symbol
+  list [pattern]          print name, type and size of the symbols
+  watch <symbol>          print the value of a symbol when it changes
+
+flags:
+`
+
+// Run parses the flags and runs the command in args which must not
+// include the program name. Output is written to stdout. Run returns
+// when the command is done. Without -count watch runs until the
+// program is stopped, use RunContext to end it earlier.
+func Run(args []string, stdout io.Writer) error {
+	return RunContext(context.Background(), args, stdout)
+}
+
+// RunContext is like Run but watch also returns when ctx is cancelled.
+func RunContext(ctx context.Context, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("goads", flag.ContinueOnError)
+	fs.SetOutput(stdout)
+	fs.Usage = func() {
+		fmt.Fprint(stdout, usage)
+		fs.PrintDefaults()
+	}
+	addr := fs.String("addr", "127.0.0.1:48898", "TCP address of the ADS router")
+	target := fs.String("target", "127.0.0.1.1.1:851", "AMS address of the PLC")
+	sender := fs.String("sender", "127.0.0.1.1.1:32000", "AMS address of the client")
+	timeout := fs.Duration("timeout", 5*time.Second, "timeout of a request")
+	interval := fs.Duration("interval", time.Second, "poll interval of watch")
+	count := fs.Int("count", 0, "number of changes after which watch stops, 0 for no limit")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	args = fs.Args()
+	if len(args) == 0 {
+		fs.Usage()
+		return errors.New("missing command")
+	}
+	cmd, args := args[0], args[1:]
+
+	nargs := map[string][2]int{
+		"read":  {1, 1},
+		"write": {2, 2},
+		"list":  {0, 1},
+		"watch": {1, 1},
+	}
+	n, ok := nargs[cmd]
+	if !ok {
+		fs.Usage()
+		return fmt.Errorf("unknown command %q", cmd)
+	}
+	if len(args) < n[0] || len(args) > n[1] {
+		fs.Usage()
+		return fmt.Errorf("wrong number of arguments for %s", cmd)
+	}
+
+	targetAddr, err := ams.ParseAddr(*target)
+	if err != nil {
+		return fmt.Errorf("invalid target address: %w", err)
+	}
+	senderAddr, err := ams.ParseAddr(*sender)
+	if err != nil {
+		return fmt.Errorf("invalid sender address: %w", err)
+	}
+
+	c := &goads.Client{Addr: *addr, ReadTimeout: *timeout}
+	if err := c.Dial(ctx); err != nil {
+		return err
+	}
+	defer c.Close()
+	s := c.NewSession(targetAddr, senderAddr)
+
+	switch cmd {
+	case "read":
+		return read(ctx, s, stdout, args[0])
+	case "write":
+		return write(ctx, s, args[0], args[1])
+	case "list":
+		pattern := "*"
+		if len(args) > 0 {
+			pattern = args[0]
+		}
+		return list(ctx, s, stdout, pattern)
+	default:
+		return watch(ctx, s, stdout, args[0], *interval, *count)
+	}
+}
+
+func read(ctx context.Context, s *goads.Session, stdout io.Writer, name string) error {
+	data, info, err := s.Read(ctx, name)
+	if err != nil {
+		return err
+	}
+	v, err := goads.DecodeFieldValueStrict(data, info.DataType)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(stdout, v)
+	return err
+}
+
+func write(ctx context.Context, s *goads.Session, name, value string) error {
+	info, err := s.GetSymbol(ctx, name)
+	if err != nil {
+		return err
+	}
+	data, err := s.EncodeValue(ctx, value, info.DataType, info.Size)
+	if err != nil {
+		return err
+	}
+	return s.Write(ctx, name, data)
+}
+
+func list(ctx context.Context, s *goads.Session, stdout io.Writer, pattern string) error {
+	if err := s.LoadSymbolTable(ctx); err != nil {
+		return err
+	}
+	for _, info := range s.FindSymbols(pattern) {
+		if _, err := fmt.Fprintf(stdout, "%s\t%s\t%d\n", info.Name, info.DataType, info.Size); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func watch(ctx context.Context, s *goads.Session, stdout io.Writer, name string, interval time.Duration, count int) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	ch, err := s.Watch(ctx, name, interval)
+	if err != nil {
+		return err
+	}
+	for n := 0; count == 0 || n < count; n++ {
+		ev, ok := <-ch
+		if !ok {
+			return nil
+		}
+		if _, err := fmt.Fprintf(stdout, "%s %s: %v -> %v\n", ev.Time.Format(time.RFC3339), ev.Name, ev.Old, ev.New); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mrpasztoradam/goads/ams"
+	"github.com/mrpasztoradam/goads/goadstest"
+	"github.com/pascaldekloe/goe/verify"
+)
+
+// symbol is a PLC symbol of the test server
+type symbol struct {
+	name     string
+	dataType string
+	value    []byte
+}
+
+// entry returns the ADS symbol entry of the symbol
+func (s *symbol) entry() []byte {
+	b := make([]byte, 30)
+	binary.LittleEndian.PutUint32(b[12:16], uint32(len(s.value)))
+	binary.LittleEndian.PutUint16(b[24:26], uint16(len(s.name)))
+	binary.LittleEndian.PutUint16(b[26:28], uint16(len(s.dataType)))
+	b = append(b, s.name+"\x00"+s.dataType+"\x00\x00"...)
+	binary.LittleEndian.PutUint32(b[0:4], uint32(len(b)))
+	return b
+}
+
+// newServer returns a test server with the symbols. The handle
+// of a symbol is its index.
+func newServer(symbols []*symbol) (*goadstest.Server, *sync.Mutex) {
+	var mu sync.Mutex
+	srv := goadstest.NewServer()
+
+	srv.HandleReadWrite(0xF009, func(req *ams.ReadWriteRequest) ([]byte, uint32) {
+		name := strings.TrimSuffix(string(req.Data), "\x00")
+		for _, s := range symbols {
+			if s.name == name {
+				return s.entry(), ams.NoError
+			}
+		}
+		return nil, ams.DeviceSymbolNotFound
+	})
+	srv.HandleReadWrite(ams.IdxGetSymHandleByName, func(req *ams.ReadWriteRequest) ([]byte, uint32) {
+		for i, s := range symbols {
+			if s.name == string(req.Data) {
+				return []byte{byte(i), 0, 0, 0}, ams.NoError
+			}
+		}
+		return nil, ams.DeviceSymbolNotFound
+	})
+	srv.HandleRead(0xF005, func(req *ams.ReadRequest) ([]byte, uint32) {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]byte(nil), symbols[req.IndexOffset].value...), ams.NoError
+	})
+	srv.HandleWrite(0xF005, func(req *ams.WriteRequest) uint32 {
+		mu.Lock()
+		defer mu.Unlock()
+		symbols[req.IndexOffset].value = append([]byte(nil), req.Data...)
+		return ams.NoError
+	})
+
+	// symbol table upload
+	srv.HandleRead(0xF008, func(req *ams.ReadRequest) ([]byte, uint32) {
+		return []byte{1}, ams.NoError
+	})
+	var table []byte
+	for _, s := range symbols {
+		table = append(table, s.entry()...)
+	}
+	srv.HandleRead(0xF00C, func(req *ams.ReadRequest) ([]byte, uint32) {
+		info := make([]byte, 0x30)
+		binary.LittleEndian.PutUint32(info[0:4], uint32(len(symbols)))
+		binary.LittleEndian.PutUint32(info[4:8], uint32(len(table)))
+		return info, ams.NoError
+	})
+	srv.HandleRead(0xF00B, func(req *ams.ReadRequest) ([]byte, uint32) {
+		return table, ams.NoError
+	})
+
+	return srv, &mu
+}
+
+func TestRun(t *testing.T) {
+	symbols := []*symbol{
+		{"MAIN.nCount", "INT", []byte{42, 0}},
+		{"MAIN.bRunning", "BOOL", []byte{1}},
+		{"GVL.wMask", "WORD", []byte{0, 0}},
+	}
+	srv, _ := newServer(symbols)
+	defer srv.Close()
+
+	flags := []string{"-addr", srv.Addr(), "-target", srv.AMSAddr().String()}
+	run := func(args ...string) (string, error) {
+		var out bytes.Buffer
+		err := Run(append(flags, args...), &out)
+		return out.String(), err
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"read", []string{"read", "MAIN.nCount"}, "42\n"},
+		{"write", []string{"write", "GVL.wMask", "0xFF"}, ""},
+		{"read written", []string{"read", "GVL.wMask"}, "255\n"},
+		{"list", []string{"list"}, "GVL.wMask\tWORD\t2\nMAIN.bRunning\tBOOL\t1\nMAIN.nCount\tINT\t2\n"},
+		{"list pattern", []string{"list", "MAIN.b*"}, "MAIN.bRunning\tBOOL\t1\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := run(tt.args...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			verify.Values(t, "output", got, tt.want)
+		})
+	}
+
+	t.Run("errors", func(t *testing.T) {
+		for _, args := range [][]string{
+			nil,
+			{"delete", "MAIN.nCount"},
+			{"read"},
+			{"write", "MAIN.nCount"},
+			{"read", "MAIN.unknown"},
+			{"write", "MAIN.nCount", "abc"},
+		} {
+			if _, err := run(args...); err == nil {
+				t.Errorf("%q: want error", args)
+			}
+		}
+	})
+}
+
+func TestRunWatch(t *testing.T) {
+	symbols := []*symbol{
+		{"MAIN.nCount", "INT", []byte{1, 0}},
+	}
+	srv, mu := newServer(symbols)
+	defer srv.Close()
+
+	// increment the value on every read after the first
+	reads := 0
+	srv.HandleRead(0xF005, func(req *ams.ReadRequest) ([]byte, uint32) {
+		mu.Lock()
+		defer mu.Unlock()
+		reads++
+		return []byte{byte(reads), 0}, ams.NoError
+	})
+
+	var out bytes.Buffer
+	args := []string{"-addr", srv.Addr(), "-target", srv.AMSAddr().String(), "-interval", "1ms", "-count", "2", "watch", "MAIN.nCount"}
+	if err := RunContext(context.Background(), args, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	for i := range lines {
+		// strip the time
+		lines[i] = lines[i][strings.Index(lines[i], " ")+1:]
+	}
+	verify.Values(t, "output", lines, []string{"MAIN.nCount: 1 -> 2", "MAIN.nCount: 2 -> 3"})
+}
@@ -1,10 +1,13 @@
 package goads
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"sync"
@@ -22,17 +25,69 @@ var bufferPool = sync.Pool{
 	},
 }
 
+// Buffer pool for encoding requests and responses
+var encodePool = sync.Pool{
+	New: func() interface{} {
+		return new(ams.Buffer)
+	},
+}
+
 var ErrTimeout = errors.New("timeout")
 
+// ErrClosed is returned for requests on a closed client.
+var ErrClosed = errors.New("client closed")
+
+// ErrConnectionClosed is returned for pending requests when the
+// connection fails, e.g. because the server closed it.
+var ErrConnectionClosed = errors.New("connection closed")
+
+// DefaultReadTimeout is the time to wait for a response when
+// Client.ReadTimeout is not set and the context has no deadline.
+const DefaultReadTimeout = 5 * time.Second
+
 // Client implements a Twincat3 TCP client.
 type Client struct {
-	Addr        string
+	// first so that the atomic counters are 64-bit aligned
+	stats clientStats
+
+	Addr string
+
+	// Addrs is an optional list of addresses of redundant controllers.
+	// Dial tries them in order and connects to the first one which
+	// accepts the connection. The first address is the primary.
+	// If Addrs is empty then Addr is used.
+	Addrs []string
+
+	// ReadTimeout is the maximum time to wait for a response.
+	// If zero, the deadline of the request context is used and
+	// DefaultReadTimeout if the context has no deadline.
 	ReadTimeout time.Duration
 
-	conn         net.Conn
-	nextInvokeID uint32 // atomic
+	// AllowedSenders is an optional list of senders whose incoming
+	// requests, e.g. ReadState, are answered. Requests from other
+	// senders are dropped and logged. An address with port 0 allows
+	// all ports of the NetID. If empty all senders are allowed.
+	AllowedSenders []ams.Addr
+
+	// CacheHandles enables caching of the handles returned by
+	// GetSymHandleByName per target and symbol name. The cache is
+	// cleared by Close and when the client connects again. Use
+	// InvalidateHandle when the symbol table of the target changes.
+	// Sessions share the cached handles and release a handle on the
+	// PLC only when no other session holds it.
+	CacheHandles bool
+
+	handleMu    sync.Mutex
+	handleCache map[handleKey]*handleRef
+
+	connAddr     atomic.Value // string
+	nextInvokeID uint32       // atomic
+	connections  uint32       // atomic, number of established connections
 
 	mu      sync.Mutex
+	conn    net.Conn
+	done    chan struct{} // closed by Close
+	lost    chan struct{} // closed when receiving fails
 	handler map[uint32]chan ams.Response
 
 	adsState    atomic.Value // uint16
@@ -40,19 +95,107 @@ type Client struct {
 
 	// Notification callback handler
 	notificationCallback func(*ams.DeviceNotificationRequest)
+	notificationSinks    map[*NotificationManager]bool
 	notificationMu       sync.RWMutex
+
+	tracer   func(dir Direction, h ams.AMSHeader, data []byte)
+	tracerMu sync.RWMutex
+
+	// OnDisconnect is called when the keepalive detects
+	// that the server does not respond anymore.
+	OnDisconnect func(err error)
+
+	keepaliveStop chan struct{}
+
+	logger            *log.Logger // nil uses the standard logger
+	buffers           *sync.Pool  // nil uses bufferPool
+	reconnectInterval time.Duration
+}
+
+// Option configures a Client created by NewClient.
+type Option func(*Client)
+
+// WithTimeout sets the maximum time to wait for a response.
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) { c.ReadTimeout = d }
+}
+
+// WithLogger sets the logger for the errors of the receive loop.
+// By default the standard logger is used.
+func WithLogger(l *log.Logger) Option {
+	return func(c *Client) { c.logger = l }
+}
+
+// WithBufferSize sets the size of the receive buffers. Larger packets
+// are still received but need an extra allocation, so the size should
+// cover the typical response, e.g. of large reads. The default is 1500.
+func WithBufferSize(n int) Option {
+	return func(c *Client) {
+		c.buffers = &sync.Pool{New: func() interface{} {
+			buf := make([]byte, n)
+			return &buf
+		}}
+	}
+}
+
+// WithAutoReconnect dials again every interval after the connection
+// was lost until it succeeds or Close is called. Requests fail with
+// ErrConnectionClosed while the client is disconnected. Sessions drop
+// the handles of the old connection and request new ones as needed.
+// Notifications of the old connection are not restored.
+func WithAutoReconnect(interval time.Duration) Option {
+	return func(c *Client) { c.reconnectInterval = interval }
+}
+
+// NewClient returns a client for the ADS router at addr. Without
+// WithTimeout requests wait until the deadline of the request context
+// or DefaultReadTimeout if the context has no deadline.
+//
+// A Client can also be created as a struct literal. The logger, buffer
+// size and reconnect options are only available through NewClient.
+func NewClient(addr string, opts ...Option) *Client {
+	c := &Client{Addr: addr}
+	c.SetADSState(ams.ADSStateInvalid)
+	c.SetDeviceState(ams.ADSStateInvalid)
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// logf logs to the configured logger or the standard logger.
+func (c *Client) logf(format string, args ...interface{}) {
+	if c.logger != nil {
+		c.logger.Printf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
 }
 
+// receivePool returns the pool of the receive buffers.
+func (c *Client) receivePool() *sync.Pool {
+	if c.buffers != nil {
+		return c.buffers
+	}
+	return &bufferPool
+}
+
+// ADSState returns the ADS state which the client reports to the
+// server. It is ams.ADSStateInvalid before Dial.
 func (c *Client) ADSState() uint16 {
-	return c.adsState.Load().(uint16)
+	s, _ := c.adsState.Load().(uint16)
+	return s
 }
 
 func (c *Client) SetADSState(s uint16) {
 	c.adsState.Store(s)
 }
 
+// DeviceState returns the device state which the client reports to
+// the server. It is ams.ADSStateInvalid before Dial.
 func (c *Client) DeviceState() uint16 {
-	return c.deviceState.Load().(uint16)
+	s, _ := c.deviceState.Load().(uint16)
+	return s
 }
 
 func (c *Client) SetDeviceState(s uint16) {
@@ -60,27 +203,172 @@ func (c *Client) SetDeviceState(s uint16) {
 }
 
 // Dial connects to a Twincat server.
+//
+// If Addrs is set the addresses are tried in order so that
+// dialing again connects to the primary if it is available.
 func (c *Client) Dial(ctx context.Context) error {
-	atomic.AddUint32(&c.nextInvokeID, 1)
+	return c.dial(ctx, &net.Dialer{})
+}
+
+// DialTLS connects to a Twincat server or a TLS terminating proxy
+// over TLS. The config provides the client certificates and the
+// settings for verifying the server certificate.
+func (c *Client) DialTLS(ctx context.Context, config *tls.Config) error {
+	return c.dial(ctx, &tls.Dialer{Config: config})
+}
+
+// dialer is implemented by net.Dialer and tls.Dialer.
+type dialer interface {
+	DialContext(ctx context.Context, network, addr string) (net.Conn, error)
+}
 
+func (c *Client) dial(ctx context.Context, d dialer) error {
 	c.SetADSState(ams.ADSStateStart)
 	c.SetDeviceState(ams.ADSStateStart)
 
-	d := &net.Dialer{}
-	conn, err := d.DialContext(ctx, "tcp", c.Addr)
-	if err != nil {
-		return err
+	addrs := c.Addrs
+	if len(addrs) == 0 {
+		addrs = []string{c.Addr}
 	}
-	c.conn = conn
-	go c.receive(ctx)
+
+	var err error
+	for _, addr := range addrs {
+		var conn net.Conn
+		conn, err = d.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			c.logf("client: failed to connect to %s: %s", addr, err)
+			continue
+		}
+		c.start(ctx, conn, addr, d)
+		return nil
+	}
+	return err
+}
+
+// DialConn uses an established connection to a Twincat server, e.g. a
+// tunnel over a custom transport or one end of net.Pipe in tests. The
+// client closes the connection on Close. Since the client cannot dial
+// the connection again, WithAutoReconnect has no effect.
+func (c *Client) DialConn(ctx context.Context, conn net.Conn) error {
+	if conn == nil {
+		return errors.New("nil connection")
+	}
+	c.SetADSState(ams.ADSStateStart)
+	c.SetDeviceState(ams.ADSStateStart)
+	c.start(ctx, conn, conn.RemoteAddr().String(), nil)
 	return nil
 }
 
+// start starts receiving on the connection to addr. If the connection
+// is lost it is dialed again with d unless d is nil.
+func (c *Client) start(ctx context.Context, conn net.Conn, addr string, d dialer) {
+	done, lost := make(chan struct{}), make(chan struct{})
+	c.mu.Lock()
+	c.conn = conn
+	c.done = done
+	c.lost = lost
+	c.mu.Unlock()
+	c.connAddr.Store(addr)
+
+	// the handles of a previous connection are invalid
+	c.handleMu.Lock()
+	c.handleCache = nil
+	c.handleMu.Unlock()
+	atomic.AddUint32(&c.connections, 1)
+
+	go func() {
+		// fail the pending requests instead of letting them time out
+		if err := c.receive(ctx, conn, done); err != nil {
+			close(lost)
+			if d != nil && c.reconnectInterval > 0 {
+				c.reconnect(d, done)
+			}
+		}
+	}()
+}
+
+// reconnect dials until it succeeds or the lost connection with
+// the done channel is closed by Close.
+func (c *Client) reconnect(d dialer, done chan struct{}) {
+	for {
+		select {
+		case <-done:
+			return
+		case <-time.After(c.reconnectInterval):
+		}
+
+		// the context is also used by the receive loop
+		if err := c.dial(context.Background(), d); err != nil {
+			c.logf("client: failed to reconnect: %s", err)
+			continue
+		}
+
+		// Close was called while dialing
+		select {
+		case <-done:
+			c.Close()
+		default:
+		}
+		return
+	}
+}
+
+// ConnectedAddr returns the address of the server the client
+// is connected to or an empty string if it is not connected.
+func (c *Client) ConnectedAddr() string {
+	addr, _ := c.connAddr.Load().(string)
+	return addr
+}
+
+// LocalAddr returns the default AMS NetID of the local end of the
+// connection which is its IPv4 address followed by .1.1. The port is
+// zero and must be set by the caller. LocalAddr returns the zero Addr
+// if the client is not connected over IPv4.
+func (c *Client) LocalAddr() ams.Addr {
+	conn, _ := c.connection()
+	if conn == nil {
+		return ams.Addr{}
+	}
+	tcpAddr, ok := conn.LocalAddr().(*net.TCPAddr)
+	if !ok {
+		return ams.Addr{}
+	}
+	ip := tcpAddr.IP.To4()
+	if ip == nil {
+		return ams.Addr{}
+	}
+	return ams.Addr{NetID: []byte{ip[0], ip[1], ip[2], ip[3], 1, 1}}
+}
+
+// Close closes the connection. Pending and future requests
+// return ErrClosed. Close is safe to call multiple times and
+// from multiple goroutines.
 func (c *Client) Close() error {
-	if c.conn == nil {
+	c.DisableKeepalive()
+
+	c.mu.Lock()
+	conn, done := c.conn, c.done
+	c.conn, c.done, c.lost = nil, nil, nil
+	c.mu.Unlock()
+
+	c.handleMu.Lock()
+	c.handleCache = nil
+	c.handleMu.Unlock()
+
+	if conn == nil {
 		return nil
 	}
-	return c.conn.Close()
+	close(done)
+	c.connAddr.Store("")
+	return conn.Close()
+}
+
+// connection returns the current connection or nil if the
+// client is not connected.
+func (c *Client) connection() (net.Conn, chan struct{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn, c.done
 }
 
 // SetNotificationCallback sets the callback function for device notifications
@@ -90,36 +378,128 @@ func (c *Client) SetNotificationCallback(callback func(*ams.DeviceNotificationRe
 	c.notificationCallback = callback
 }
 
-func (c *Client) receive(ctx context.Context) error {
+// AddNotificationSink registers a notification manager which receives
+// the samples for its notification handles. Multiple managers can be
+// registered, e.g. for independent subsystems. NotificationManager.Start
+// registers the manager.
+func (c *Client) AddNotificationSink(nm *NotificationManager) {
+	c.notificationMu.Lock()
+	defer c.notificationMu.Unlock()
+	if c.notificationSinks == nil {
+		c.notificationSinks = make(map[*NotificationManager]bool)
+	}
+	c.notificationSinks[nm] = true
+}
+
+// RemoveNotificationSink unregisters a notification manager.
+func (c *Client) RemoveNotificationSink(nm *NotificationManager) {
+	c.notificationMu.Lock()
+	defer c.notificationMu.Unlock()
+	delete(c.notificationSinks, nm)
+}
+
+// notify calls the notification callback, but handles any panics gracefully
+func (c *Client) notify(callback func(*ams.DeviceNotificationRequest), req *ams.DeviceNotificationRequest) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.logf("client: panic in notification callback: %v", r)
+		}
+	}()
+	callback(req)
+}
+
+// notificationReceivers returns the callback and the registered
+// notification managers.
+func (c *Client) notificationReceivers() (func(*ams.DeviceNotificationRequest), []*NotificationManager) {
+	c.notificationMu.RLock()
+	defer c.notificationMu.RUnlock()
+	sinks := make([]*NotificationManager, 0, len(c.notificationSinks))
+	for nm := range c.notificationSinks {
+		sinks = append(sinks, nm)
+	}
+	return c.notificationCallback, sinks
+}
+
+// Direction is the direction of a traced packet.
+type Direction int
+
+const (
+	Outgoing Direction = iota // sent by the client
+	Incoming                  // received by the client
+)
+
+func (d Direction) String() string {
+	switch d {
+	case Outgoing:
+		return "out"
+	case Incoming:
+		return "in"
+	default:
+		return fmt.Sprintf("Direction(%d)", int(d))
+	}
+}
+
+// SetTracer sets a function which is called with the header and the
+// payload after the header of every packet the client sends and
+// receives. The payload is a copy and can be retained. The tracer is
+// called from the receive loop and must not block. A nil tracer
+// disables tracing.
+func (c *Client) SetTracer(tracer func(dir Direction, h ams.AMSHeader, data []byte)) {
+	c.tracerMu.Lock()
+	defer c.tracerMu.Unlock()
+	c.tracer = tracer
+}
+
+// trace calls the tracer with a packet including the TCP header.
+func (c *Client) trace(dir Direction, pkt []byte) {
+	c.tracerMu.RLock()
+	tracer := c.tracer
+	c.tracerMu.RUnlock()
+	if tracer == nil {
+		return
+	}
+
+	var hdr ams.Header
+	if err := hdr.Decode(ams.NewBuffer(pkt)); err != nil {
+		return
+	}
+	var data []byte
+	if len(pkt) > tcpHeaderLen+amsHeaderLen {
+		data = append(data, pkt[tcpHeaderLen+amsHeaderLen:]...)
+	}
+	tracer(dir, hdr.AMSHeader, data)
+}
+
+func (c *Client) receive(ctx context.Context, conn net.Conn, done chan struct{}) error {
 	c.SetADSState(ams.ADSStateRun)
 	c.SetDeviceState(ams.ADSStateRun)
 	defer c.SetADSState(ams.ADSStateStop)
 	defer c.SetDeviceState(ams.ADSStateStop)
 
-	// We assume that a packet fits into a single packet.
-	// This is probably wrong but I haven't found anything on length
-	// would probably have to read the header first, alloc and then read
-	// the rest to fix this probably. This works for now.
-	const packetSize = 1500
-
+	pool := c.receivePool()
 	for {
 		// Get buffer from pool
-		bufPtr := bufferPool.Get().(*[]byte)
-		data := *bufPtr
+		bufPtr := pool.Get().(*[]byte)
 
-		n, err := c.conn.Read(data)
+		data, err := readPacket(conn, *bufPtr)
 		if err != nil {
-			bufferPool.Put(bufPtr) // Return buffer to pool
-			return err
+			pool.Put(bufPtr) // Return buffer to pool
+			select {
+			case <-done:
+				// closed by Close
+				return nil
+			default:
+				return err
+			}
 		}
-
-		// truncate the buffer to the correct length
-		data = data[:n]
-		// log.Printf("read %d bytes", n)
+		atomic.AddUint64(&c.stats.bytesReceived, uint64(len(data)))
+		c.trace(Incoming, data)
 
 		// decode just the header
 		var hdr ams.Header
 		if err := hdr.Decode(ams.NewBuffer(data)); err != nil {
+			atomic.AddUint64(&c.stats.decodeErrors, 1)
+			pool.Put(bufPtr)
 			return err
 		}
 
@@ -134,6 +514,8 @@ func (c *Client) receive(ctx context.Context) error {
 			pkt = &ams.WriteResponse{}
 		case ams.IsReadWriteResponse(hdr.AMSHeader):
 			pkt = &ams.ReadWriteResponse{}
+		case ams.IsReadStateResponse(hdr.AMSHeader):
+			pkt = &ams.ReadStateResponse{}
 		case ams.IsReadStateRequest(hdr.AMSHeader):
 			pkt = &ams.ReadStateRequest{}
 		case ams.IsDeviceNotificationRequest(hdr.AMSHeader):
@@ -143,57 +525,55 @@ func (c *Client) receive(ctx context.Context) error {
 		case ams.IsDeleteDeviceNotificationResponse(hdr.AMSHeader):
 			pkt = &ams.DeleteDeviceNotificationResponse{}
 		default:
-			log.Printf("client: unknown packet: %#v", hdr)
+			c.logf("client: unknown packet: %#v", hdr)
+			pool.Put(bufPtr)
 			continue
 		}
 
 		// decode the full packet with the header
 		if err := pkt.Decode(ams.NewBuffer(data)); err != nil {
+			atomic.AddUint64(&c.stats.decodeErrors, 1)
 			// For device notifications, just log and continue - don't fail the entire receive loop
 			if _, isNotification := pkt.(*ams.DeviceNotificationRequest); isNotification {
 				// Only log if we have a callback registered
-				c.notificationMu.RLock()
-				hasCallback := c.notificationCallback != nil
-				c.notificationMu.RUnlock()
-				if hasCallback {
-					log.Printf("client: failed to decode notification: %s", err)
+				callback, sinks := c.notificationReceivers()
+				if callback != nil || len(sinks) > 0 {
+					c.logf("client: failed to decode notification: %s", err)
 				}
-				bufferPool.Put(bufPtr)
+				pool.Put(bufPtr)
 				continue
 			}
-			log.Printf("client: failed to decode: %s", err)
-			bufferPool.Put(bufPtr)
+			c.logf("client: failed to decode: %s", err)
+			pool.Put(bufPtr)
 			return err
 		}
 
 		switch req := pkt.(type) {
 		// handle incoming requests
 		case *ams.ReadStateRequest:
-			if err := c.handleReadStateRequest(ctx, req); err != nil {
+			err := c.handleReadStateRequest(ctx, req)
+			pool.Put(bufPtr)
+			if err != nil {
 				return err
 			}
 
 		// handle incoming device notifications
 		case *ams.DeviceNotificationRequest:
-			c.notificationMu.RLock()
-			callback := c.notificationCallback
-			c.notificationMu.RUnlock()
+			callback, sinks := c.notificationReceivers()
 			if callback != nil {
-				// Call callback, but handle any panics gracefully
-				func() {
-					defer func() {
-						if r := recover(); r != nil {
-							log.Printf("client: panic in notification callback: %v", r)
-						}
-					}()
-					callback(req)
-				}()
+				c.notify(callback, req)
+			}
+			for _, nm := range sinks {
+				// the manager ignores samples of handles it does not own
+				c.notify(nm.dispatch, req)
 			}
-			bufferPool.Put(bufPtr)
+			pool.Put(bufPtr)
 			continue
 
 		// forward responses to handlers
 		default:
+			atomic.AddUint64(&c.stats.responsesReceived, 1)
+
 			// find the handler channel for packet
 			invokeID := hdr.AMSHeader.InvokeID
 			c.mu.Lock()
@@ -206,8 +586,8 @@ func (c *Client) receive(ctx context.Context) error {
 
 			// if there is no handler then drop the packet
 			if h == nil {
-				log.Printf("client: no handler for %d", invokeID)
-				bufferPool.Put(bufPtr) // Return buffer to pool
+				c.logf("client: no handler for %d", invokeID)
+				pool.Put(bufPtr) // Return buffer to pool
 				continue
 			}
 
@@ -216,21 +596,83 @@ func (c *Client) receive(ctx context.Context) error {
 			// one response. So this call should never block.
 			select {
 			case <-ctx.Done():
-				bufferPool.Put(bufPtr) // Return buffer to pool
+				pool.Put(bufPtr) // Return buffer to pool
 			case h <- pkt:
-				bufferPool.Put(bufPtr) // Return buffer to pool after sending
+				pool.Put(bufPtr) // Return buffer to pool after sending
 				close(h)
 			}
 		}
 	}
 }
 
+// maxPacketLength is the largest length of the AMS header and data of
+// a packet, i.e. a response with the largest read length of a request
+// and its result and length fields.
+const maxPacketLength = amsHeaderLen + 8 + maxReadLength
+
+// ErrPacketTooLarge is returned when a received packet exceeds the
+// largest packet of the protocol. The connection is closed since the
+// following packets cannot be found.
+var ErrPacketTooLarge = errors.New("packet too large")
+
+// readPacket reads a single AMS/TCP packet including the TCP header.
+// The packet is read into buf if it fits and into a new buffer otherwise.
+// It reads exactly the length from the TCP header, so packets may be
+// split across reads of r and the following packets are left in r.
+func readPacket(r io.Reader, buf []byte) ([]byte, error) {
+	if len(buf) < tcpHeaderLen {
+		buf = make([]byte, tcpHeaderLen)
+	}
+	if _, err := io.ReadFull(r, buf[:tcpHeaderLen]); err != nil {
+		return nil, err
+	}
+
+	// the length in the TCP header excludes the header itself
+	length := binary.LittleEndian.Uint32(buf[2:tcpHeaderLen])
+	if length > maxPacketLength {
+		return nil, fmt.Errorf("%w: %d bytes", ErrPacketTooLarge, length)
+	}
+	n := tcpHeaderLen + int(length)
+	if n > len(buf) {
+		data := make([]byte, n)
+		copy(data, buf[:tcpHeaderLen])
+		buf = data
+	}
+	if _, err := io.ReadFull(r, buf[tcpHeaderLen:n]); err != nil {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+// tcpHeaderLen is the length of the AMS/TCP header
+const tcpHeaderLen = 6
+
+// amsHeaderLen is the length of the AMS header
+const amsHeaderLen = 32
+
 func (c *Client) handleReadStateRequest(ctx context.Context, req *ams.ReadStateRequest) error {
 	hdr := req.Header()
+	if !c.allowedSender(hdr.Sender) {
+		c.logf("client: dropped ReadState request from %s", hdr.Sender)
+		return nil
+	}
 	resp := ams.NewReadStateResponse(hdr.Sender, hdr.Target, ams.NoError, c.ADSState(), c.DeviceState())
 	return c.sendResponse(ctx, req, resp)
 }
 
+// allowedSender returns true if requests from the sender are answered.
+func (c *Client) allowedSender(sender ams.Addr) bool {
+	if len(c.AllowedSenders) == 0 {
+		return true
+	}
+	for _, a := range c.AllowedSenders {
+		if bytes.Equal(a.NetID, sender.NetID) && (a.Port == 0 || a.Port == sender.Port) {
+			return true
+		}
+	}
+	return false
+}
+
 type packet interface {
 	Header() *ams.AMSHeader
 	Decode(b *ams.Buffer) error
@@ -241,64 +683,121 @@ func (c *Client) sendResponse(ctx context.Context, req ams.Request, pkt packet)
 	// set the invoke id from the request
 	pkt.Header().InvokeID = req.Header().InvokeID
 
-	// encode the response
-	var b ams.Buffer
-	if err := pkt.Encode(&b); err != nil {
-		return err
+	// send the response
+	conn, _ := c.connection()
+	if conn == nil {
+		return ErrClosed
 	}
+	return c.write(conn, pkt)
+}
 
-	// send the response
-	_, err := c.conn.Write(b.Bytes())
+// write encodes a packet into a pooled buffer and writes it to conn.
+func (c *Client) write(conn net.Conn, pkt packet) error {
+	b := encodePool.Get().(*ams.Buffer)
+	defer encodePool.Put(b)
+	b.Reset()
+
+	if err := pkt.Encode(b); err != nil {
+		return err
+	}
+	c.trace(Outgoing, b.Bytes())
+	n, err := conn.Write(b.Bytes())
+	atomic.AddUint64(&c.stats.bytesSent, uint64(n))
 	return err
 }
 
 // send sends a request to the server and sets up a handler channel
 // for the callback.
 func (c *Client) send(ctx context.Context, pkt packet, cb func(ams.Response) error) error {
-	// set a unique invoke id for the request
-	pkt.Header().InvokeID = atomic.AddUint32(&c.nextInvokeID, 1)
-
-	// encode the request
-	var b ams.Buffer
-	if err := pkt.Encode(&b); err != nil {
-		return err
-	}
-
 	// create a handler channel for the response
 	// make sure that the channel is buffered
 	// so that we don't need a separate go routine for
 	// sending the resposne.
 	h := make(chan ams.Response, 1)
 
-	// register the handler.
+	// register the handler with a unique invoke id.
 	c.mu.Lock()
+	conn, done, lost := c.conn, c.done, c.lost
+	if conn == nil {
+		c.mu.Unlock()
+		return ErrClosed
+	}
 	if c.handler == nil {
 		c.handler = make(map[uint32]chan ams.Response)
 	}
-	c.handler[pkt.Header().InvokeID] = h
+	invokeID := c.newInvokeID()
+	c.handler[invokeID] = h
 	c.mu.Unlock()
 
-	// send the request
-	_, err := c.conn.Write(b.Bytes())
-	if err != nil {
+	// the handler must not outlive the request
+	defer func() {
 		c.mu.Lock()
-		delete(c.handler, pkt.Header().InvokeID)
+		delete(c.handler, invokeID)
 		c.mu.Unlock()
+	}()
+
+	// encode and send the request
+	pkt.Header().InvokeID = invokeID
+	if err := c.write(conn, pkt); err != nil {
 		return err
 	}
+	atomic.AddUint64(&c.stats.requestsSent, 1)
 
 	// wait for the response or timeout.
+	var timeout <-chan time.Time
+	if d := c.readTimeout(ctx); d > 0 {
+		t := time.NewTimer(d)
+		defer t.Stop()
+		timeout = t.C
+	}
+
 	select {
+	case <-done:
+		return ErrClosed
+	case <-lost:
+		return ErrConnectionClosed
 	case <-ctx.Done():
+		if ctx.Err() == context.DeadlineExceeded {
+			atomic.AddUint64(&c.stats.timeouts, 1)
+		}
 		return ctx.Err()
-	case <-time.After(c.ReadTimeout):
+	case <-timeout:
+		atomic.AddUint64(&c.stats.timeouts, 1)
 		return ErrTimeout
 	case r := <-h:
+		if code := r.Header().ErrorCode; code != ams.NoError {
+			return ams.ADSError(code)
+		}
 		return cb(r)
 	}
 }
 
+// newInvokeID returns the next invoke id which is not zero and not
+// used by a pending request. It must be called with c.mu held.
+func (c *Client) newInvokeID() uint32 {
+	for {
+		id := atomic.AddUint32(&c.nextInvokeID, 1)
+		if id != 0 && c.handler[id] == nil {
+			return id
+		}
+	}
+}
+
+// readTimeout returns the time to wait for a response. It returns
+// zero if only the context deadline should be used.
+func (c *Client) readTimeout(ctx context.Context) time.Duration {
+	if c.ReadTimeout > 0 {
+		return c.ReadTimeout
+	}
+	if _, ok := ctx.Deadline(); ok {
+		return 0
+	}
+	return DefaultReadTimeout
+}
+
 // Read sends a Read request to the server.
+// If the command failed, i.e. the result is not NoError, the response
+// is returned with the result as ams.ADSError.
 func (c *Client) Read(ctx context.Context, r *ams.ReadRequest) (*ams.ReadResponse, error) {
 	var resp *ams.ReadResponse
 	err := c.send(ctx, r, func(r ams.Response) error {
@@ -308,23 +807,37 @@ func (c *Client) Read(ctx context.Context, r *ams.ReadRequest) (*ams.ReadRespons
 		}
 		return fmt.Errorf("got %T want %T", r, resp)
 	})
+	if err == nil && resp.Result != ams.NoError {
+		err = ams.ADSError(resp.Result)
+	}
 	return resp, err
 }
 
 // ReadWrite sends a ReadWrite request to the server.
+// If the command failed, i.e. the result is not NoError, the response
+// is returned with the result as ams.ADSError.
 func (c *Client) ReadWrite(ctx context.Context, r *ams.ReadWriteRequest) (*ams.ReadWriteResponse, error) {
 	var resp *ams.ReadWriteResponse
+	readLength := r.ReadLength
 	err := c.send(ctx, r, func(r ams.Response) error {
 		if x, ok := r.(*ams.ReadWriteResponse); ok {
+			if x.Length > readLength {
+				return fmt.Errorf("response length %d exceeds read length %d", x.Length, readLength)
+			}
 			resp = x
 			return nil
 		}
 		return fmt.Errorf("got %T want %T", r, resp)
 	})
+	if err == nil && resp.Result != ams.NoError {
+		err = ams.ADSError(resp.Result)
+	}
 	return resp, err
 }
 
 // Write sends a Write request to the server.
+// If the command failed, i.e. the result is not NoError, the response
+// is returned with the result as ams.ADSError.
 func (c *Client) Write(ctx context.Context, r *ams.WriteRequest) (*ams.WriteResponse, error) {
 	var resp *ams.WriteResponse
 	err := c.send(ctx, r, func(r ams.Response) error {
@@ -334,6 +847,22 @@ func (c *Client) Write(ctx context.Context, r *ams.WriteRequest) (*ams.WriteResp
 		}
 		return fmt.Errorf("got %T want %T", r, resp)
 	})
+	if err == nil && resp.Result != ams.NoError {
+		err = ams.ADSError(resp.Result)
+	}
+	return resp, err
+}
+
+// ReadState sends a ReadState request to the server.
+func (c *Client) ReadState(ctx context.Context, r *ams.ReadStateRequest) (*ams.ReadStateResponse, error) {
+	var resp *ams.ReadStateResponse
+	err := c.send(ctx, r, func(r ams.Response) error {
+		if x, ok := r.(*ams.ReadStateResponse); ok {
+			resp = x
+			return nil
+		}
+		return fmt.Errorf("got %T want %T", r, resp)
+	})
 	return resp, err
 }
 
@@ -363,22 +892,249 @@ func (c *Client) DeleteDeviceNotification(ctx context.Context, r *ams.DeleteDevi
 	return resp, err
 }
 
-// GetSymHandleByName returns the offset of a variable.
+// handleKey identifies a cached symbol handle
+type handleKey struct {
+	target string
+	name   string
+}
+
+// handleRef is a cached symbol handle and the number of times it was
+// returned and not released yet.
+type handleRef struct {
+	handle uint32
+	refs   int
+}
+
+// GetSymHandleByName returns the offset of a variable. If CacheHandles
+// is set the handle is only requested once per target and name.
 func (c *Client) GetSymHandleByName(ctx context.Context, targetID, senderID ams.Addr, name string) (uint32, error) {
+	if !c.CacheHandles {
+		return c.getSymHandleByName(ctx, targetID, senderID, name)
+	}
+
+	if handle, ok := c.acquireCachedHandle(targetID, name); ok {
+		return handle, nil
+	}
+
+	handle, err := c.getSymHandleByName(ctx, targetID, senderID, name)
+	if err != nil {
+		return 0, err
+	}
+	c.cacheHandle(targetID, name, handle)
+	return handle, nil
+}
+
+// acquireCachedHandle returns the cached handle of a symbol of the
+// target and counts the reference.
+func (c *Client) acquireCachedHandle(targetID ams.Addr, name string) (uint32, bool) {
+	c.handleMu.Lock()
+	defer c.handleMu.Unlock()
+	ref, ok := c.handleCache[handleKey{targetID.String(), name}]
+	if !ok {
+		return 0, false
+	}
+	ref.refs++
+	return ref.handle, true
+}
+
+// cacheHandle caches the handle of a symbol of the target with one
+// reference.
+func (c *Client) cacheHandle(targetID ams.Addr, name string, handle uint32) {
+	c.handleMu.Lock()
+	defer c.handleMu.Unlock()
+	if c.handleCache == nil {
+		c.handleCache = make(map[handleKey]*handleRef)
+	}
+	c.handleCache[handleKey{targetID.String(), name}] = &handleRef{handle: handle, refs: 1}
+}
+
+// releaseCachedHandle drops a reference of a handle of the target. It
+// returns true if the handle has to be released on the PLC, i.e. it is
+// not cached or this was the last reference, which removes it from the
+// cache.
+func (c *Client) releaseCachedHandle(targetID ams.Addr, handle uint32) bool {
+	target := targetID.String()
+	c.handleMu.Lock()
+	defer c.handleMu.Unlock()
+	for k, ref := range c.handleCache {
+		if k.target != target || ref.handle != handle {
+			continue
+		}
+		ref.refs--
+		if ref.refs > 0 {
+			return false
+		}
+		delete(c.handleCache, k)
+	}
+	return true
+}
+
+// InvalidateHandle removes the cached handle of a symbol of the target
+// so that the next GetSymHandleByName requests a new one. It does not
+// release the handle on the PLC.
+func (c *Client) InvalidateHandle(targetID ams.Addr, name string) {
+	c.handleMu.Lock()
+	defer c.handleMu.Unlock()
+	delete(c.handleCache, handleKey{targetID.String(), name})
+}
+
+// invalidateTarget removes all cached handles of the target.
+func (c *Client) invalidateTarget(targetID ams.Addr) {
+	target := targetID.String()
+	c.handleMu.Lock()
+	defer c.handleMu.Unlock()
+	for k := range c.handleCache {
+		if k.target == target {
+			delete(c.handleCache, k)
+		}
+	}
+}
+
+func (c *Client) getSymHandleByName(ctx context.Context, targetID, senderID ams.Addr, name string) (uint32, error) {
 	req := ams.NewReadWriteRequest(targetID, senderID, ams.IdxGetSymHandleByName, 0, 4, []byte(name))
 	res, err := c.ReadWrite(ctx, req)
 	if err != nil {
 		return 0, fmt.Errorf("failed GetSymHandleByName %s: %s", name, err)
 	}
-	if res.Header().ErrorCode != ams.NoError {
-		return 0, fmt.Errorf("failed ReadWrite: %d", res.Header().ErrorCode)
-	}
 	if len(res.Data) < 4 {
 		return 0, fmt.Errorf("not enough data: %d", len(res.Data))
 	}
 	return binary.LittleEndian.Uint32(res.Data[:4]), nil
 }
 
+// GetSymHandleAndInfo returns the handle of a variable together with
+// its size and data type. Both are requested with a single sum-up
+// request, or with two requests if the PLC does not support sum-up
+// requests. If CacheHandles is set and the handle is cached only the
+// symbol info is requested.
+func (c *Client) GetSymHandleAndInfo(ctx context.Context, targetID, senderID ams.Addr, name string) (handle, size uint32, dataType string, err error) {
+	if c.CacheHandles {
+		if handle, ok := c.acquireCachedHandle(targetID, name); ok {
+			info, err := readSymbolInfo(ctx, c, targetID, senderID, name)
+			if err != nil {
+				c.releaseCachedHandle(targetID, handle)
+				return 0, 0, "", err
+			}
+			return handle, info.Size, info.DataType, nil
+		}
+	}
+
+	ops := []SumOp{
+		{IndexGroup: ams.IdxGetSymHandleByName, ReadLength: 4, WriteData: []byte(name)},
+		{IndexGroup: 0xF009, ReadLength: 0xFFFF, WriteData: append([]byte(name), 0)}, // ADSIGRP_SYM_INFOBYNAMEEX
+	}
+	data, readLength := encodeSumReadWrite(ops)
+	req := ams.NewReadWriteRequest(
+		targetID,
+		senderID,
+		0xF082, // ADSIGRP_SUMUP_READWRITE
+		uint32(len(ops)),
+		readLength,
+		data,
+	)
+	resp, err := c.ReadWrite(ctx, req)
+	if errors.Is(err, ams.ADSError(ams.DeviceServiceNotSupported)) {
+		handle, err := c.GetSymHandleByName(ctx, targetID, senderID, name)
+		if err != nil {
+			return 0, 0, "", err
+		}
+		info, err := readSymbolInfo(ctx, c, targetID, senderID, name)
+		if err != nil {
+			c.releaseSymHandle(ctx, targetID, senderID, handle)
+			return 0, 0, "", err
+		}
+		return handle, info.Size, info.DataType, nil
+	}
+	if err != nil {
+		return 0, 0, "", fmt.Errorf("failed to get handle and info of %s: %w", name, err)
+	}
+
+	results, err := decodeSumReadWrite(resp.Data, ops)
+	if err != nil {
+		return 0, 0, "", fmt.Errorf("failed to get handle and info of %s: %w", name, err)
+	}
+	if err := results[0].Error; err != nil {
+		if errors.Is(err, ams.ADSError(ams.DeviceSymbolNotFound)) {
+			return 0, 0, "", fmt.Errorf("%w: %s", ErrSymbolNotFound, name)
+		}
+		return 0, 0, "", fmt.Errorf("failed to get handle and info of %s: %w", name, err)
+	}
+	if len(results[0].Data) < 4 {
+		return 0, 0, "", fmt.Errorf("not enough data: %d", len(results[0].Data))
+	}
+	handle = binary.LittleEndian.Uint32(results[0].Data)
+
+	// the handle is only returned together with the info
+	var info *SymbolInfo
+	err = results[1].Error
+	if err == nil {
+		info, err = parseSymbolInfo(results[1].Data)
+	}
+	if err != nil {
+		c.releaseSymHandle(ctx, targetID, senderID, handle)
+		if errors.Is(err, ams.ADSError(ams.DeviceSymbolNotFound)) {
+			return 0, 0, "", fmt.Errorf("%w: %s", ErrSymbolNotFound, name)
+		}
+		return 0, 0, "", fmt.Errorf("failed to get symbol info of %s: %w", name, err)
+	}
+	if c.CacheHandles {
+		c.cacheHandle(targetID, name, handle)
+	}
+	return handle, info.Size, info.DataType, nil
+}
+
+// releaseSymHandle releases a handle which GetSymHandleAndInfo got but
+// does not return. Errors are ignored since the caller fails anyway.
+func (c *Client) releaseSymHandle(ctx context.Context, targetID, senderID ams.Addr, handle uint32) {
+	if !c.releaseCachedHandle(targetID, handle) {
+		return
+	}
+	data := make([]byte, 4)
+	binary.LittleEndian.PutUint32(data, handle)
+	req := ams.NewWriteRequest(
+		targetID,
+		senderID,
+		0xF006, // ADSIGRP_SYM_RELEASEHND
+		0,
+		data,
+	)
+	c.Write(ctx, req)
+}
+
+// readSymbolInfo requests the symbol info of a variable including its
+// comment and attributes.
+func readSymbolInfo(ctx context.Context, c ADSClient, targetID, senderID ams.Addr, name string) (*SymbolInfo, error) {
+	req := ams.NewReadWriteRequest(
+		targetID,
+		senderID,
+		0xF009, // ADSIGRP_SYM_INFOBYNAMEEX
+		0,
+		0xFFFF,
+		append([]byte(name), 0),
+	)
+	resp, err := c.ReadWrite(ctx, req)
+	if errors.Is(err, ams.ADSError(ams.DeviceSymbolNotFound)) {
+		return nil, fmt.Errorf("%w: %s", ErrSymbolNotFound, name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get symbol info of %s: %w", name, err)
+	}
+	info, err := parseSymbolInfo(resp.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get symbol info of %s: %w", name, err)
+	}
+	return info, nil
+}
+
+// parseSymbolInfo parses a symbol entry returned by
+// ADSIGRP_SYM_INFOBYNAMEEX.
+func parseSymbolInfo(data []byte) (*SymbolInfo, error) {
+	if len(data) < 30 {
+		return nil, fmt.Errorf("invalid symbol info response (length: %d)", len(data))
+	}
+	return parseSymbolEntry(data)
+}
+
 // DeviceInfo holds device information
 type DeviceInfo struct {
 	MajorVersion uint8
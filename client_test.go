@@ -0,0 +1,798 @@
+package goads
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/mrpasztoradam/goads/ams"
+	"github.com/mrpasztoradam/goads/goadstest"
+	"github.com/pascaldekloe/goe/verify"
+)
+
+func TestReadTimeout(t *testing.T) {
+	deadline, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		ctx     context.Context
+		want    time.Duration
+	}{
+		{"default", 0, context.Background(), DefaultReadTimeout},
+		{"explicit", time.Second, context.Background(), time.Second},
+		{"context deadline", 0, deadline, 0},
+		{"explicit with context deadline", time.Second, deadline, time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Client{ReadTimeout: tt.timeout}
+			verify.Values(t, "", c.readTimeout(tt.ctx), tt.want)
+		})
+	}
+}
+
+func TestClientStateBeforeDial(t *testing.T) {
+	for name, c := range map[string]*Client{
+		"literal":   {},
+		"NewClient": NewClient("127.0.0.1:48898", WithTimeout(time.Second)),
+	} {
+		t.Run(name, func(t *testing.T) {
+			verify.Values(t, "ADS state", c.ADSState(), uint16(ams.ADSStateInvalid))
+			verify.Values(t, "device state", c.DeviceState(), uint16(ams.ADSStateInvalid))
+		})
+	}
+}
+
+func TestNewClientOptions(t *testing.T) {
+	var logs bytes.Buffer
+	logger := log.New(&logs, "", 0)
+
+	c := NewClient("127.0.0.1:48898")
+	verify.Values(t, "default timeout", c.readTimeout(context.Background()), DefaultReadTimeout)
+	deadline, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	verify.Values(t, "timeout with context deadline", c.readTimeout(deadline), time.Duration(0))
+
+	c = NewClient("127.0.0.1:48898", WithTimeout(time.Second), WithLogger(logger), WithBufferSize(64*1024))
+	verify.Values(t, "timeout", c.ReadTimeout, time.Second)
+	verify.Values(t, "buffer size", len(*c.receivePool().Get().(*[]byte)), 64*1024)
+	c.logf("client: %s", "hello")
+	verify.Values(t, "log", logs.String(), "client: hello\n")
+}
+
+func TestAutoReconnect(t *testing.T) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+
+	srv.HandleRead(0x4020, func(req *ams.ReadRequest) ([]byte, uint32) {
+		return []byte{1}, ams.NoError
+	})
+
+	c := NewClient(srv.Addr(), WithAutoReconnect(10*time.Millisecond))
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// drop the connection from the client side
+	conn, _ := c.connection()
+	conn.Close()
+
+	req := ams.NewReadRequest(srv.AMSAddr(), ams.MustParseAddr("10.0.0.1.1.1:32000"), 0x4020, 0, 1)
+	deadline := time.Now().Add(time.Second)
+	for {
+		_, err := c.Read(context.Background(), req)
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("not reconnected: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDialFailover(t *testing.T) {
+	// the primary is down
+	primary := goadstest.NewServer()
+	primary.Close()
+
+	secondary := goadstest.NewServer()
+	defer secondary.Close()
+	secondary.HandleRead(0x4020, func(req *ams.ReadRequest) ([]byte, uint32) {
+		return []byte{2}, ams.NoError
+	})
+
+	c := &Client{Addrs: []string{primary.Addr(), secondary.Addr()}}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	verify.Values(t, "addr", c.ConnectedAddr(), secondary.Addr())
+
+	resp, err := c.Read(context.Background(), ams.NewReadRequest(secondary.AMSAddr(), ams.MustParseAddr("10.0.0.1.1.1:32000"), 0x4020, 0, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "data", resp.Data, []byte{2})
+
+	down := &Client{Addrs: []string{primary.Addr()}}
+	if err := down.Dial(context.Background()); err == nil {
+		down.Close()
+		t.Error("got no error without a server")
+	}
+	verify.Values(t, "addr without a server", down.ConnectedAddr(), "")
+}
+
+func TestDialConn(t *testing.T) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+
+	srv.HandleRead(0x4020, func(req *ams.ReadRequest) ([]byte, uint32) {
+		return []byte{1, 2}, ams.NoError
+	})
+
+	client, server := net.Pipe()
+	srv.ServeConn(server)
+
+	c := &Client{}
+	if err := c.DialConn(context.Background(), client); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	verify.Values(t, "addr", c.ConnectedAddr(), "pipe")
+
+	resp, err := c.Read(context.Background(), ams.NewReadRequest(srv.AMSAddr(), ams.MustParseAddr("10.0.0.1.1.1:32000"), 0x4020, 0, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "data", resp.Data, []byte{1, 2})
+}
+
+func TestClientStats(t *testing.T) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+
+	srv.HandleRead(0x4020, func(req *ams.ReadRequest) ([]byte, uint32) {
+		return []byte{1, 2}, ams.NoError
+	})
+	// the server never answers
+	srv.Handle(ams.CmdADSReadState, func(req goadstest.Packet) goadstest.Packet {
+		return nil
+	})
+
+	c := &Client{Addr: srv.Addr(), ReadTimeout: 10 * time.Millisecond}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	sender := ams.MustParseAddr("10.0.0.1.1.1:32000")
+	if _, err := c.Read(context.Background(), ams.NewReadRequest(srv.AMSAddr(), sender, 0x4020, 0, 2)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ReadState(context.Background(), ams.NewReadStateRequest(srv.AMSAddr(), sender)); err != ErrTimeout {
+		t.Fatalf("got error %v want %v", err, ErrTimeout)
+	}
+
+	// read request 12 bytes, response 8 + 2 bytes, read state request 0 bytes
+	header := uint64(tcpHeaderLen + amsHeaderLen)
+	verify.Values(t, "stats", c.Stats(), Stats{
+		RequestsSent:      2,
+		ResponsesReceived: 1,
+		Timeouts:          1,
+		BytesSent:         2*header + 12,
+		BytesReceived:     header + 10,
+	})
+}
+
+func TestCloseCancelsRequests(t *testing.T) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+
+	// the server never answers
+	srv.Handle(ams.CmdADSReadState, func(req goadstest.Packet) goadstest.Packet {
+		return nil
+	})
+
+	c := &Client{Addr: srv.Addr(), ReadTimeout: time.Minute}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	sender := ams.MustParseAddr("10.0.0.1.1.1:32000")
+	errc := make(chan error, 1)
+	go func() {
+		_, err := c.ReadState(context.Background(), ams.NewReadStateRequest(srv.AMSAddr(), sender))
+		errc <- err
+	}()
+
+	// give the request time to be sent
+	time.Sleep(20 * time.Millisecond)
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-errc:
+		if !errors.Is(err, ErrClosed) {
+			t.Fatalf("got error %v want %v", err, ErrClosed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("request not cancelled")
+	}
+
+	// Close is idempotent and later requests fail
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	_, err := c.ReadState(context.Background(), ams.NewReadStateRequest(srv.AMSAddr(), sender))
+	if !errors.Is(err, ErrClosed) {
+		t.Fatalf("got error %v want %v", err, ErrClosed)
+	}
+}
+
+func TestConnectionLost(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	// the server closes the connection after the first request
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		conn.Read(make([]byte, 64))
+		conn.Close()
+	}()
+
+	c := &Client{Addr: l.Addr().String(), ReadTimeout: time.Minute}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	errc := make(chan error, 1)
+	go func() {
+		req := ams.NewReadRequest(ams.MustParseAddr("127.0.0.1.1.1:851"), ams.MustParseAddr("10.0.0.1.1.1:32000"), 0x4020, 0, 1)
+		_, err := c.Read(context.Background(), req)
+		errc <- err
+	}()
+
+	select {
+	case err := <-errc:
+		if !errors.Is(err, ErrConnectionClosed) {
+			t.Fatalf("got error %v want %v", err, ErrConnectionClosed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("request not failed")
+	}
+}
+
+func TestReadPacketFragmented(t *testing.T) {
+	target, sender := ams.MustParseAddr("10.0.0.1.1.1:32000"), ams.MustParseAddr("127.0.0.1.1.1:851")
+	var frames [][]byte
+	var stream []byte
+	for _, data := range [][]byte{{1}, bytes.Repeat([]byte{2}, 2000)} {
+		var b ams.Buffer
+		if err := ams.NewReadResponse(target, sender, ams.NoError, data).Encode(&b); err != nil {
+			t.Fatal(err)
+		}
+		frames = append(frames, b.Bytes())
+		stream = append(stream, b.Bytes()...)
+	}
+
+	tests := []struct {
+		name   string
+		r      io.Reader
+		bufLen int
+	}{
+		// a single read returns all frames
+		{"one read", bytes.NewReader(stream), 1500},
+		// every read returns a single byte and splits the length field
+		{"byte by byte", iotest.OneByteReader(bytes.NewReader(stream)), 1500},
+		{"byte by byte small buffer", iotest.OneByteReader(bytes.NewReader(stream)), 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i, want := range frames {
+				got, err := readPacket(tt.r, make([]byte, tt.bufLen))
+				if err != nil {
+					t.Fatalf("frame %d: %v", i, err)
+				}
+				verify.Values(t, fmt.Sprintf("frame %d", i), got, want)
+			}
+			if _, err := readPacket(tt.r, make([]byte, tt.bufLen)); err != io.EOF {
+				t.Fatalf("got error %v after the last frame want %v", err, io.EOF)
+			}
+		})
+	}
+
+	// a corrupt length is rejected before the packet is allocated
+	tooLarge := []byte{0, 0, 0xff, 0xff, 0xff, 0xff}
+	if _, err := readPacket(bytes.NewReader(tooLarge), make([]byte, 1500)); !errors.Is(err, ErrPacketTooLarge) {
+		t.Fatalf("got error %v for length 0xffffffff want %v", err, ErrPacketTooLarge)
+	}
+
+	// the stream ends within the length field and within the payload
+	for _, n := range []int{3, len(frames[0]) - 1} {
+		r := iotest.OneByteReader(bytes.NewReader(frames[0][:n]))
+		if _, err := readPacket(r, make([]byte, 1500)); err != io.ErrUnexpectedEOF {
+			t.Fatalf("got error %v for %d bytes want %v", err, n, io.ErrUnexpectedEOF)
+		}
+	}
+}
+
+func TestInvokeIDWrap(t *testing.T) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+
+	// answer with the index offset
+	srv.HandleRead(0x4020, func(req *ams.ReadRequest) ([]byte, uint32) {
+		return []byte{byte(req.IndexOffset)}, ams.NoError
+	})
+
+	c := &Client{Addr: srv.Addr()}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// a request with invoke id 1 is still pending
+	pending := make(chan ams.Response, 1)
+	c.mu.Lock()
+	c.handler = map[uint32]chan ams.Response{1: pending}
+	c.nextInvokeID = math.MaxUint32 - 1
+	c.mu.Unlock()
+
+	sender := ams.MustParseAddr("10.0.0.1.1.1:32000")
+	var ids []uint32
+	for i := uint32(1); i <= 3; i++ {
+		req := ams.NewReadRequest(srv.AMSAddr(), sender, 0x4020, i, 1)
+		resp, err := c.Read(context.Background(), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		verify.Values(t, "data", resp.Data, []byte{byte(i)})
+		ids = append(ids, req.Header().InvokeID)
+	}
+
+	// 0 and the pending 1 are skipped
+	verify.Values(t, "invoke ids", ids, []uint32{math.MaxUint32, 2, 3})
+	verify.Values(t, "pending responses", len(pending), 0)
+}
+
+func TestTracer(t *testing.T) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+	srv.HandleRead(0x4020, func(req *ams.ReadRequest) ([]byte, uint32) {
+		return []byte{byte(req.IndexOffset), 2}, ams.NoError
+	})
+
+	c := &Client{Addr: srv.Addr()}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	type record struct {
+		Dir   Direction
+		CmdID uint16
+		Data  []byte
+	}
+	var mu sync.Mutex
+	var records []record
+	c.SetTracer(func(dir Direction, h ams.AMSHeader, data []byte) {
+		mu.Lock()
+		defer mu.Unlock()
+		records = append(records, record{dir, h.CmdID, data})
+	})
+
+	sender := ams.MustParseAddr("10.0.0.1.1.1:32000")
+	for _, offset := range []uint32{1, 3} {
+		req := ams.NewReadRequest(srv.AMSAddr(), sender, 0x4020, offset, 2)
+		if _, err := c.Read(context.Background(), req); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	readReq := func(offset byte) []byte {
+		return []byte{0x20, 0x40, 0, 0, offset, 0, 0, 0, 2, 0, 0, 0}
+	}
+	verify.Values(t, "records", records, []record{
+		{Outgoing, ams.CmdADSRead, readReq(1)},
+		{Incoming, ams.CmdADSRead, []byte{0, 0, 0, 0, 2, 0, 0, 0, 1, 2}},
+		{Outgoing, ams.CmdADSRead, readReq(3)},
+		{Incoming, ams.CmdADSRead, []byte{0, 0, 0, 0, 2, 0, 0, 0, 3, 2}},
+	})
+}
+
+func TestLocalAddr(t *testing.T) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+
+	c := &Client{Addr: srv.Addr()}
+	verify.Values(t, "not connected", c.LocalAddr(), ams.Addr{})
+
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	verify.Values(t, "connected", c.LocalAddr(), ams.Addr{NetID: []byte{127, 0, 0, 1, 1, 1}})
+}
+
+func BenchmarkRead(b *testing.B) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+	srv.HandleRead(0x4020, func(req *ams.ReadRequest) ([]byte, uint32) {
+		return make([]byte, req.Length), ams.NoError
+	})
+
+	c := &Client{Addr: srv.Addr()}
+	if err := c.Dial(context.Background()); err != nil {
+		b.Fatal(err)
+	}
+	defer c.Close()
+
+	ctx := context.Background()
+	sender := ams.MustParseAddr("10.0.0.1.1.1:32000")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		req := ams.NewReadRequest(srv.AMSAddr(), sender, 0x4020, 0, 8)
+		if _, err := c.Read(ctx, req); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestClientHandleCache(t *testing.T) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+
+	var mu sync.Mutex
+	requests := 0
+	srv.HandleReadWrite(ams.IdxGetSymHandleByName, func(req *ams.ReadWriteRequest) ([]byte, uint32) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		return []byte{byte(requests), 0, 0, 0}, ams.NoError
+	})
+	getRequests := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return requests
+	}
+
+	c := &Client{Addr: srv.Addr()}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ctx := context.Background()
+	target, sender := srv.AMSAddr(), ams.MustParseAddr("10.0.0.1.1.1:32000")
+	handle := func(name string) uint32 {
+		h, err := c.GetSymHandleByName(ctx, target, sender, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+
+	// not cached by default
+	handle("MAIN.a")
+	verify.Values(t, "uncached", handle("MAIN.a"), uint32(2))
+
+	c.CacheHandles = true
+	verify.Values(t, "first", handle("MAIN.a"), uint32(3))
+	verify.Values(t, "cached", handle("MAIN.a"), uint32(3))
+	verify.Values(t, "other name", handle("MAIN.b"), uint32(4))
+	verify.Values(t, "requests", getRequests(), 4)
+
+	c.InvalidateHandle(target, "MAIN.a")
+	verify.Values(t, "invalidated", handle("MAIN.a"), uint32(5))
+	verify.Values(t, "still cached", handle("MAIN.b"), uint32(4))
+}
+
+func TestGetSymHandleAndInfo(t *testing.T) {
+	for _, sum := range []bool{true, false} {
+		t.Run(fmt.Sprintf("sum=%t", sum), func(t *testing.T) {
+			srv := goadstest.NewServer()
+			defer srv.Close()
+
+			var mu sync.Mutex
+			requests := 0
+			symbolInfo := func(name string) ([]byte, uint32) {
+				if name != "MAIN.nValue" {
+					return nil, ams.DeviceSymbolNotFound
+				}
+				return symbolEntry(name, "DINT", "", 0x4040, 8, 4), ams.NoError
+			}
+			if sum {
+				srv.HandleReadWrite(0xF082, func(req *ams.ReadWriteRequest) ([]byte, uint32) {
+					mu.Lock()
+					requests++
+					mu.Unlock()
+					n := int(req.IndexOffset)
+					results := make([]byte, 8*n)
+					var data []byte
+					writeOffset := 16 * n
+					for i := 0; i < n; i++ {
+						group := binary.LittleEndian.Uint32(req.Data[i*16:])
+						writeLen := binary.LittleEndian.Uint32(req.Data[i*16+12:])
+						name := strings.TrimRight(string(req.Data[writeOffset:writeOffset+int(writeLen)]), "\x00")
+						writeOffset += int(writeLen)
+
+						var b []byte
+						result := uint32(ams.NoError)
+						if group == ams.IdxGetSymHandleByName {
+							b = []byte{42, 0, 0, 0}
+						} else {
+							b, result = symbolInfo(name)
+						}
+						binary.LittleEndian.PutUint32(results[i*8:], result)
+						binary.LittleEndian.PutUint32(results[i*8+4:], uint32(len(b)))
+						data = append(data, b...)
+					}
+					return append(results, data...), ams.NoError
+				})
+			}
+			srv.HandleReadWrite(ams.IdxGetSymHandleByName, func(req *ams.ReadWriteRequest) ([]byte, uint32) {
+				mu.Lock()
+				requests++
+				mu.Unlock()
+				return []byte{42, 0, 0, 0}, ams.NoError
+			})
+			srv.HandleReadWrite(0xF009, func(req *ams.ReadWriteRequest) ([]byte, uint32) {
+				mu.Lock()
+				requests++
+				mu.Unlock()
+				return symbolInfo(strings.TrimRight(string(req.Data), "\x00"))
+			})
+			var released []uint32
+			srv.HandleWrite(0xF006, func(req *ams.WriteRequest) uint32 {
+				mu.Lock()
+				released = append(released, binary.LittleEndian.Uint32(req.Data))
+				mu.Unlock()
+				return ams.NoError
+			})
+
+			c := &Client{Addr: srv.Addr()}
+			if err := c.Dial(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+
+			ctx := context.Background()
+			target, sender := srv.AMSAddr(), ams.MustParseAddr("10.0.0.1.1.1:32000")
+			handle, size, dataType, err := c.GetSymHandleAndInfo(ctx, target, sender, "MAIN.nValue")
+			if err != nil {
+				t.Fatal(err)
+			}
+			verify.Values(t, "handle", handle, uint32(42))
+			verify.Values(t, "size", size, uint32(4))
+			verify.Values(t, "type", dataType, "DINT")
+
+			mu.Lock()
+			wantRequests := 2
+			if sum {
+				wantRequests = 1
+			}
+			verify.Values(t, "requests", requests, wantRequests)
+			mu.Unlock()
+
+			if _, _, _, err := c.GetSymHandleAndInfo(ctx, target, sender, "MAIN.missing"); !errors.Is(err, ErrSymbolNotFound) {
+				t.Errorf("got error %v want %v", err, ErrSymbolNotFound)
+			}
+			// the handle of the missing info is not leaked
+			mu.Lock()
+			verify.Values(t, "released", released, []uint32{42})
+			mu.Unlock()
+		})
+	}
+}
+
+func TestReceiveAmbiguousFlags(t *testing.T) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+
+	// the first read is answered with a device notification which has
+	// the response flag set and the second with a regular response
+	var mu sync.Mutex
+	reads := 0
+	srv.Handle(ams.CmdADSRead, func(req goadstest.Packet) goadstest.Packet {
+		mu.Lock()
+		defer mu.Unlock()
+		reads++
+		h := req.Header()
+		if reads == 1 {
+			p := ams.NewDeviceNotificationRequest(h.Sender, h.Target, nil)
+			p.Header().StateFlags |= ams.StateResponse
+			return p
+		}
+		return ams.NewReadResponse(h.Sender, h.Target, ams.NoError, []byte{1})
+	})
+
+	var logBuf bytes.Buffer
+	log.SetOutput(&logBuf)
+	defer log.SetOutput(os.Stderr)
+
+	c := &Client{Addr: srv.Addr(), ReadTimeout: 100 * time.Millisecond}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	notified := make(chan bool, 1)
+	c.SetNotificationCallback(func(*ams.DeviceNotificationRequest) { notified <- true })
+
+	sender := ams.MustParseAddr("10.0.0.1.1.1:32000")
+	req := ams.NewReadRequest(srv.AMSAddr(), sender, 0x4020, 0, 1)
+	if _, err := c.Read(context.Background(), req); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("got %v want ErrTimeout", err)
+	}
+	select {
+	case <-notified:
+		t.Fatal("ambiguous packet decoded as notification")
+	default:
+	}
+
+	// the client still receives packets
+	req = ams.NewReadRequest(srv.AMSAddr(), sender, 0x4020, 0, 1)
+	resp, err := c.Read(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "data", resp.Data, []byte{1})
+
+	if !strings.Contains(logBuf.String(), "client: unknown packet") {
+		t.Errorf("ambiguous packet not logged: %q", logBuf.String())
+	}
+}
+
+func TestAllowedSenders(t *testing.T) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+
+	// reads are answered with a ReadState request to the client from peer
+	var mu sync.Mutex
+	var peer ams.Addr
+	srv.Handle(ams.CmdADSRead, func(req goadstest.Packet) goadstest.Packet {
+		mu.Lock()
+		defer mu.Unlock()
+		return ams.NewReadStateRequest(req.Header().Sender, peer)
+	})
+	allowed, err := ams.ParseNetID("10.0.0.2.1.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &Client{Addr: srv.Addr(), ReadTimeout: 10 * time.Millisecond, AllowedSenders: []ams.Addr{allowed}}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	answered := make(chan ams.Addr, 1)
+	c.SetTracer(func(dir Direction, h ams.AMSHeader, data []byte) {
+		if dir == Outgoing && h.CmdID == ams.CmdADSReadState {
+			answered <- h.Target
+		}
+	})
+
+	tests := []struct {
+		peer   string
+		answer bool
+	}{
+		{"10.0.0.2.1.1:851", true},
+		{"10.0.0.2.1.1:852", true},
+		{"10.0.0.3.1.1:851", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.peer, func(t *testing.T) {
+			mu.Lock()
+			peer = ams.MustParseAddr(tt.peer)
+			mu.Unlock()
+
+			req := ams.NewReadRequest(srv.AMSAddr(), ams.MustParseAddr("10.0.0.1.1.1:32000"), 0x4020, 0, 1)
+			c.Read(context.Background(), req)
+
+			select {
+			case to := <-answered:
+				if !tt.answer {
+					t.Fatalf("answered request from %s", to)
+				}
+				verify.Values(t, "target", to.String(), tt.peer)
+			case <-time.After(100 * time.Millisecond):
+				if tt.answer {
+					t.Fatal("request not answered")
+				}
+			}
+		})
+	}
+}
+
+func TestReadWriteZeroReadLength(t *testing.T) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+
+	var written []byte
+	srv.HandleReadWrite(0x4020, func(req *ams.ReadWriteRequest) ([]byte, uint32) {
+		written = req.Data
+		return nil, ams.NoError
+	})
+
+	c := &Client{Addr: srv.Addr()}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	req := ams.NewReadWriteRequest(srv.AMSAddr(), ams.MustParseAddr("10.0.0.1.1.1:32000"), 0x4020, 0, 0, []byte{1, 2, 3})
+	resp, err := c.ReadWrite(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "result", resp.Result, uint32(ams.NoError))
+	verify.Values(t, "data", len(resp.Data), 0)
+	verify.Values(t, "written", written, []byte{1, 2, 3})
+}
+
+func TestResultError(t *testing.T) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+
+	srv.HandleRead(0x4020, func(req *ams.ReadRequest) ([]byte, uint32) {
+		return nil, ams.DeviceSymbolNotFound
+	})
+	srv.HandleWrite(0x4020, func(req *ams.WriteRequest) uint32 {
+		return ams.DeviceInvalidHandle
+	})
+
+	c := &Client{Addr: srv.Addr()}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ctx := context.Background()
+	sender := ams.MustParseAddr("10.0.0.1.1.1:32000")
+
+	readResp, err := c.Read(ctx, ams.NewReadRequest(srv.AMSAddr(), sender, 0x4020, 0, 4))
+	if !errors.Is(err, ams.ADSError(ams.DeviceSymbolNotFound)) {
+		t.Fatalf("got error %v want %v", err, ams.ADSError(ams.DeviceSymbolNotFound))
+	}
+	verify.Values(t, "read result", readResp.Result, uint32(ams.DeviceSymbolNotFound))
+
+	writeResp, err := c.Write(ctx, ams.NewWriteRequest(srv.AMSAddr(), sender, 0x4020, 0, []byte{1}))
+	if !errors.Is(err, ams.ADSError(ams.DeviceInvalidHandle)) {
+		t.Fatalf("got error %v want %v", err, ams.ADSError(ams.DeviceInvalidHandle))
+	}
+	verify.Values(t, "write result", writeResp.Result, uint32(ams.DeviceInvalidHandle))
+
+	rwResp, err := c.ReadWrite(ctx, ams.NewReadWriteRequest(srv.AMSAddr(), sender, 0x4021, 0, 4, nil))
+	if !errors.Is(err, ams.ADSError(ams.DeviceServiceNotSupported)) {
+		t.Fatalf("got error %v want %v", err, ams.ADSError(ams.DeviceServiceNotSupported))
+	}
+	verify.Values(t, "read-write result", rwResp.Result, uint32(ams.DeviceServiceNotSupported))
+}
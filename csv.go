@@ -0,0 +1,102 @@
+package goads
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+)
+
+// csvHeader is the header row of a symbol value CSV file
+var csvHeader = []string{"name", "type", "value", "error"}
+
+// ExportValuesToCSV reads all cached symbols and writes their decoded
+// values as name,type,value,error rows to a CSV file. Structs and arrays
+// are skipped like in ReadAll. A symbol which fails to read gets a row
+// with an empty value and the error.
+func (s *Session) ExportValuesToCSV(ctx context.Context, filename string) error {
+	values, names, errs, err := s.readAll(ctx, false)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	if err := w.Write(csvHeader); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	for _, name := range names {
+		info, _ := s.registry.Get(name)
+		row := []string{name, info.DataType, fmt.Sprint(values[name]), ""}
+		if err := errs[name]; err != nil {
+			row[2], row[3] = "", err.Error()
+		}
+		if err := w.Write(row); err != nil {
+			return fmt.Errorf("failed to write file: %w", err)
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	return f.Close()
+}
+
+// ImportValuesFromCSV reads name,type,value rows from a CSV file
+// and writes each value to the PLC encoded with EncodeValue
+// using the declared type. Rows of ExportValuesToCSV with an error
+// are skipped. It stops at the first error.
+func (s *Session) ImportValuesFromCSV(ctx context.Context, filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	// the error column is optional
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+
+	for line := 1; ; line++ {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read file: %w", err)
+		}
+		if len(rec) != 3 && len(rec) != 4 {
+			return fmt.Errorf("line %d: got %d fields want 3 or 4", line, len(rec))
+		}
+		if line == 1 && rec[0] == csvHeader[0] && rec[1] == csvHeader[1] && rec[2] == csvHeader[2] {
+			continue
+		}
+		if len(rec) == 4 && rec[3] != "" {
+			continue
+		}
+
+		name, dataType, value := rec[0], rec[1], rec[2]
+		info, err := s.GetSymbol(ctx, name)
+		if err != nil {
+			return fmt.Errorf("line %d: failed to get symbol info: %w", line, err)
+		}
+		if info.DataType != "" && info.DataType != dataType {
+			return fmt.Errorf("line %d: %s has type %s but file declares %s", line, name, info.DataType, dataType)
+		}
+
+		data, err := s.EncodeValue(ctx, value, dataType, info.Size)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		if err := s.Write(ctx, name, data); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+}
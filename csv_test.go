@@ -0,0 +1,147 @@
+package goads
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/mrpasztoradam/goads/ams"
+	"github.com/mrpasztoradam/goads/goadstest"
+	"github.com/pascaldekloe/goe/verify"
+)
+
+// valueServer is a test server with symbol values by handle. It answers
+// sum-up reads and writes by handle. Handles without a value fail with
+// DeviceInvalidHandle.
+type valueServer struct {
+	*goadstest.Server
+
+	mu     sync.Mutex
+	values map[uint32][]byte
+}
+
+func newValueServer(values map[uint32][]byte) *valueServer {
+	srv := &valueServer{Server: goadstest.NewServer(), values: values}
+	srv.HandleReadWrite(0xF080, func(req *ams.ReadWriteRequest) ([]byte, uint32) {
+		srv.mu.Lock()
+		defer srv.mu.Unlock()
+		n := int(req.IndexOffset)
+		results := make([]byte, 4*n)
+		var data []byte
+		for i := 0; i < n; i++ {
+			handle := binary.LittleEndian.Uint32(req.Data[i*12+4:])
+			size := binary.LittleEndian.Uint32(req.Data[i*12+8:])
+			v, ok := srv.values[handle]
+			if !ok {
+				binary.LittleEndian.PutUint32(results[i*4:], ams.DeviceInvalidHandle)
+			}
+			data = append(data, make([]byte, size)...)
+			copy(data[len(data)-int(size):], v)
+		}
+		return append(results, data...), ams.NoError
+	})
+	srv.HandleWrite(0xF005, func(req *ams.WriteRequest) uint32 {
+		srv.mu.Lock()
+		defer srv.mu.Unlock()
+		if _, ok := srv.values[req.IndexOffset]; !ok {
+			return ams.DeviceInvalidHandle
+		}
+		srv.values[req.IndexOffset] = append([]byte(nil), req.Data...)
+		return ams.NoError
+	})
+	return srv
+}
+
+// value returns the value of the handle.
+func (srv *valueServer) value(handle uint32) []byte {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return srv.values[handle]
+}
+
+func TestValuesCSV(t *testing.T) {
+	temp := make([]byte, 8)
+	binary.LittleEndian.PutUint64(temp, math.Float64bits(21.5))
+	srv := newValueServer(map[uint32][]byte{
+		1: {1},
+		2: temp,
+		3: {5, 0},
+	})
+	defer srv.Close()
+
+	c := &Client{Addr: srv.Addr()}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	s := c.NewSession(srv.AMSAddr(), ams.MustParseAddr("10.0.0.1.1.1:32000"))
+	s.registry.Set("MAIN.bOn", &SymbolInfo{Name: "MAIN.bOn", DataType: "BOOL", Size: 1, Handle: 1})
+	s.registry.Set("MAIN.fTemp", &SymbolInfo{Name: "MAIN.fTemp", DataType: "LREAL", Size: 8, Handle: 2})
+	s.registry.Set("MAIN.nCount", &SymbolInfo{Name: "MAIN.nCount", DataType: "INT", Size: 2, Handle: 3})
+	s.registry.Set("MAIN.nGone", &SymbolInfo{Name: "MAIN.nGone", DataType: "INT", Size: 2, Handle: 4})
+
+	filename := filepath.Join(t.TempDir(), "values.csv")
+	if err := s.ExportValuesToCSV(context.Background(), filename); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "file", string(data), `name,type,value,error
+MAIN.bOn,BOOL,true,
+MAIN.fTemp,LREAL,21.5,
+MAIN.nCount,INT,5,
+MAIN.nGone,INT,,failed to read MAIN.nGone: ADS error 0x711: invalid symbol handle
+`)
+
+	// the import restores the exported values
+	srv.mu.Lock()
+	for _, h := range []uint32{1, 2, 3} {
+		srv.values[h] = make([]byte, len(srv.values[h]))
+	}
+	srv.mu.Unlock()
+	if err := s.ImportValuesFromCSV(context.Background(), filename); err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "bOn", srv.value(1), []byte{1})
+	verify.Values(t, "fTemp", srv.value(2), temp)
+	verify.Values(t, "nCount", srv.value(3), []byte{5, 0})
+}
+
+func TestImportValuesFromCSVErrors(t *testing.T) {
+	srv := newValueServer(map[uint32][]byte{1: {5, 0}})
+	defer srv.Close()
+
+	c := &Client{Addr: srv.Addr()}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	s := c.NewSession(srv.AMSAddr(), ams.MustParseAddr("10.0.0.1.1.1:32000"))
+	s.registry.Set("MAIN.nCount", &SymbolInfo{Name: "MAIN.nCount", DataType: "INT", Size: 2, Handle: 1})
+
+	tests := map[string]string{
+		"too few fields":   "MAIN.nCount,INT\n",
+		"too many fields":  "MAIN.nCount,INT,7,,x\n",
+		"other type":       "MAIN.nCount,DINT,7\n",
+		"invalid value":    "MAIN.nCount,INT,seven\n",
+		"unmatched quote":  "\"MAIN.nCount,INT,7\n",
+		"after valid line": "name,type,value\nMAIN.nCount,INT,7\nMAIN.nCount,INT,\n",
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "values.csv")
+			if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.ImportValuesFromCSV(context.Background(), filename); err == nil {
+				t.Error("got no error")
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package goads
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// maxDescribeDepth limits the nesting of a type description
+const maxDescribeDepth = 32
+
+// TypeDescription describes a data type. Structs have members and
+// arrays have the dimensions and the description of their element.
+type TypeDescription struct {
+	Name     string            `json:"name,omitempty"` // of a member or element
+	DataType string            `json:"type"`
+	Offset   uint32            `json:"offset"` // relative to the parent
+	Size     uint32            `json:"size"`
+	Array    *ArrayType        `json:"array,omitempty"`
+	Element  *TypeDescription  `json:"element,omitempty"`
+	Members  []TypeDescription `json:"members,omitempty"`
+}
+
+// SymbolDescription describes a symbol and the full tree of its type.
+type SymbolDescription struct {
+	Name        string            `json:"name"`
+	IndexGroup  uint32            `json:"indexGroup"`
+	IndexOffset uint32            `json:"indexOffset"`
+	Comment     string            `json:"comment,omitempty"`
+	Attributes  map[string]string `json:"attributes,omitempty"`
+	Type        TypeDescription   `json:"type"`
+}
+
+// DescribeSymbol returns the symbol with its type resolved recursively,
+// e.g. for tools which render an editor for a variable. Struct types are
+// taken from the type registry or loaded from the PLC. Pointers and
+// references are not followed.
+func (s *Session) DescribeSymbol(ctx context.Context, name string) (*SymbolDescription, error) {
+	info, err := s.GetSymbol(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get symbol info: %w", err)
+	}
+
+	t, err := s.describeType(ctx, info.DataType, info.Size, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe %s: %w", name, err)
+	}
+	return &SymbolDescription{
+		Name:        info.Name,
+		IndexGroup:  info.IndexGroup,
+		IndexOffset: info.IndexOffset,
+		Comment:     info.Comment,
+		Attributes:  info.Attributes,
+		Type:        *t,
+	}, nil
+}
+
+// describeType describes a data type of size bytes
+func (s *Session) describeType(ctx context.Context, dataType string, size uint32, depth int) (*TypeDescription, error) {
+	if depth > maxDescribeDepth {
+		return nil, fmt.Errorf("type %s nested too deep", dataType)
+	}
+	t := &TypeDescription{DataType: dataType, Size: size}
+
+	if IsArrayType(dataType) {
+		arr, err := ParseArrayType(dataType)
+		if err != nil {
+			return nil, err
+		}
+		t.Array = arr
+		elemSize, err := arr.elementSize(size)
+		if err != nil {
+			return nil, err
+		}
+		elem, err := s.describeType(ctx, arr.ElementType, elemSize, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		t.Element = elem
+		return t, nil
+	}
+
+	if IsPrimitiveType(dataType) || strings.HasPrefix(dataType, "POINTER TO") || strings.HasPrefix(dataType, "REFERENCE TO") {
+		return t, nil
+	}
+
+	fields, err := s.resolveType(ctx, dataType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", dataType, err)
+	}
+	for _, f := range fields {
+		m, err := s.describeType(ctx, f.DataType, f.Size, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		m.Name = f.Name
+		m.Offset = f.Offset
+		t.Members = append(t.Members, *m)
+	}
+	return t, nil
+}
@@ -0,0 +1,59 @@
+package goads
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mrpasztoradam/goads/ams"
+	"github.com/pascaldekloe/goe/verify"
+)
+
+func TestDescribeSymbol(t *testing.T) {
+	s := (&Client{}).NewSession(ams.MustParseAddr("127.0.0.1.1.1:851"), ams.MustParseAddr("10.0.0.1.1.1:32000"))
+	s.registry.Set("MAIN.stOrder", &SymbolInfo{Name: "MAIN.stOrder", DataType: "ST_Order", Size: 18, IndexGroup: 0x4040, IndexOffset: 0x10, Comment: "current order"})
+	s.types.Set("ST_Item", &DataTypeInfo{Name: "ST_Item", Fields: []StructField{
+		{Name: "id", DataType: "INT", Offset: 0, Size: 2},
+		{Name: "value", DataType: "INT", Offset: 2, Size: 2},
+	}})
+	s.types.Set("ST_Order", &DataTypeInfo{Name: "ST_Order", Fields: []StructField{
+		{Name: "nCount", DataType: "INT", Offset: 0, Size: 2},
+		{Name: "aItems", DataType: "ARRAY [0..2] OF ST_Item", Offset: 2, Size: 12},
+		{Name: "pNext", DataType: "POINTER TO ST_Order", Offset: 14, Size: 4},
+	}})
+
+	got, err := s.DescribeSymbol(context.Background(), "MAIN.stOrder")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	item := &TypeDescription{DataType: "ST_Item", Size: 4, Members: []TypeDescription{
+		{Name: "id", DataType: "INT", Offset: 0, Size: 2},
+		{Name: "value", DataType: "INT", Offset: 2, Size: 2},
+	}}
+	want := &SymbolDescription{
+		Name:        "MAIN.stOrder",
+		IndexGroup:  0x4040,
+		IndexOffset: 0x10,
+		Comment:     "current order",
+		Type: TypeDescription{DataType: "ST_Order", Size: 18, Members: []TypeDescription{
+			{Name: "nCount", DataType: "INT", Offset: 0, Size: 2},
+			{Name: "aItems", DataType: "ARRAY [0..2] OF ST_Item", Offset: 2, Size: 12,
+				Array:   &ArrayType{Dims: []ArrayDim{{0, 2}}, ElementType: "ST_Item"},
+				Element: item,
+			},
+			{Name: "pNext", DataType: "POINTER TO ST_Order", Offset: 14, Size: 4},
+		}},
+	}
+	verify.Values(t, "", got, want)
+
+	if _, err := s.DescribeSymbol(context.Background(), "MAIN.unknown"); err == nil {
+		t.Error("want error for unknown symbol")
+	}
+
+	for _, dataType := range []string{"ARRAY [0..65535,0..65535] OF INT", "ARRAY [0..9] OF INT"} {
+		s.registry.Set("MAIN.aData", &SymbolInfo{Name: "MAIN.aData", DataType: dataType, Size: 4})
+		if _, err := s.DescribeSymbol(context.Background(), "MAIN.aData"); err == nil {
+			t.Errorf("%s: want error for more elements than bytes", dataType)
+		}
+	}
+}
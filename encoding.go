@@ -1,92 +1,111 @@
 package goads
 
 import (
+	"bytes"
+	"context"
 	"encoding/binary"
 	"fmt"
 	"math"
+	"sort"
+	"strconv"
+	"strings"
 )
 
-// EncodeValue encodes a string value into bytes based on the data type
+// ByteOrder is the byte order of PLC values used by EncodeValue,
+// DecodeFieldValue and the typed encoders and decoders. TwinCAT runtimes
+// on x86 and ARM are little-endian. The ADS protocol itself is always
+// little-endian and not affected.
+var ByteOrder binary.ByteOrder = binary.LittleEndian
+
+// parseBool returns whether a BOOL input is true. It accepts
+// true/false, on/off and 1/0 in any case.
+func parseBool(value string) bool {
+	switch strings.ToUpper(strings.TrimSpace(value)) {
+	case "TRUE", "ON", "1":
+		return true
+	}
+	return false
+}
+
+// EncodeValue encodes a string value into bytes based on the data type.
+// Integers can be given as decimal or with a 0x, 0b or 0o prefix and
+// BOOL values as true/false, on/off or 1/0.
 func EncodeValue(value string, dataType string, size uint32) ([]byte, error) {
 	// Handle basic types
 	switch dataType {
 	case "BOOL":
-		var boolVal bool
-		if value == "true" || value == "1" {
-			boolVal = true
-		}
 		data := make([]byte, 1)
-		if boolVal {
+		if parseBool(value) {
 			data[0] = 1
 		}
 		return data, nil
 
 	case "SINT":
-		var val int8
-		if _, err := fmt.Sscanf(value, "%d", &val); err != nil {
+		n, err := strconv.ParseInt(strings.TrimSpace(value), 0, 8)
+		if err != nil {
 			return nil, fmt.Errorf("invalid SINT value: %w", err)
 		}
-		return []byte{byte(val)}, nil
+		return []byte{byte(n)}, nil
 
 	case "USINT", "BYTE":
-		var val uint8
-		if _, err := fmt.Sscanf(value, "%d", &val); err != nil {
+		n, err := strconv.ParseUint(strings.TrimSpace(value), 0, 8)
+		if err != nil {
 			return nil, fmt.Errorf("invalid USINT/BYTE value: %w", err)
 		}
-		return []byte{val}, nil
+		return []byte{byte(n)}, nil
 
 	case "INT":
-		var val int16
-		if _, err := fmt.Sscanf(value, "%d", &val); err != nil {
+		n, err := strconv.ParseInt(strings.TrimSpace(value), 0, 16)
+		if err != nil {
 			return nil, fmt.Errorf("invalid INT value: %w", err)
 		}
 		data := make([]byte, 2)
-		binary.LittleEndian.PutUint16(data, uint16(val))
+		ByteOrder.PutUint16(data, uint16(n))
 		return data, nil
 
 	case "UINT", "WORD":
-		var val uint16
-		if _, err := fmt.Sscanf(value, "%d", &val); err != nil {
+		n, err := strconv.ParseUint(strings.TrimSpace(value), 0, 16)
+		if err != nil {
 			return nil, fmt.Errorf("invalid UINT/WORD value: %w", err)
 		}
 		data := make([]byte, 2)
-		binary.LittleEndian.PutUint16(data, val)
+		ByteOrder.PutUint16(data, uint16(n))
 		return data, nil
 
 	case "DINT":
-		var val int32
-		if _, err := fmt.Sscanf(value, "%d", &val); err != nil {
+		n, err := strconv.ParseInt(strings.TrimSpace(value), 0, 32)
+		if err != nil {
 			return nil, fmt.Errorf("invalid DINT value: %w", err)
 		}
 		data := make([]byte, 4)
-		binary.LittleEndian.PutUint32(data, uint32(val))
+		ByteOrder.PutUint32(data, uint32(n))
 		return data, nil
 
 	case "UDINT", "DWORD":
-		var val uint32
-		if _, err := fmt.Sscanf(value, "%d", &val); err != nil {
+		n, err := strconv.ParseUint(strings.TrimSpace(value), 0, 32)
+		if err != nil {
 			return nil, fmt.Errorf("invalid UDINT/DWORD value: %w", err)
 		}
 		data := make([]byte, 4)
-		binary.LittleEndian.PutUint32(data, val)
+		ByteOrder.PutUint32(data, uint32(n))
 		return data, nil
 
 	case "LINT":
-		var val int64
-		if _, err := fmt.Sscanf(value, "%d", &val); err != nil {
+		n, err := strconv.ParseInt(strings.TrimSpace(value), 0, 64)
+		if err != nil {
 			return nil, fmt.Errorf("invalid LINT value: %w", err)
 		}
 		data := make([]byte, 8)
-		binary.LittleEndian.PutUint64(data, uint64(val))
+		ByteOrder.PutUint64(data, uint64(n))
 		return data, nil
 
 	case "ULINT", "LWORD":
-		var val uint64
-		if _, err := fmt.Sscanf(value, "%d", &val); err != nil {
+		n, err := strconv.ParseUint(strings.TrimSpace(value), 0, 64)
+		if err != nil {
 			return nil, fmt.Errorf("invalid ULINT/LWORD value: %w", err)
 		}
 		data := make([]byte, 8)
-		binary.LittleEndian.PutUint64(data, val)
+		ByteOrder.PutUint64(data, n)
 		return data, nil
 
 	case "REAL":
@@ -95,7 +114,7 @@ func EncodeValue(value string, dataType string, size uint32) ([]byte, error) {
 			return nil, fmt.Errorf("invalid REAL value: %w", err)
 		}
 		data := make([]byte, 4)
-		binary.LittleEndian.PutUint32(data, math.Float32bits(val))
+		ByteOrder.PutUint32(data, math.Float32bits(val))
 		return data, nil
 
 	case "LREAL":
@@ -104,9 +123,12 @@ func EncodeValue(value string, dataType string, size uint32) ([]byte, error) {
 			return nil, fmt.Errorf("invalid LREAL value: %w", err)
 		}
 		data := make([]byte, 8)
-		binary.LittleEndian.PutUint64(data, math.Float64bits(val))
+		ByteOrder.PutUint64(data, math.Float64bits(val))
 		return data, nil
 
+	case "LTIME", "LDT", "LDATE", "LTOD":
+		return encodeLTime(value, dataType)
+
 	default:
 		// Check for STRING type
 		if len(dataType) >= 6 && dataType[:6] == "STRING" {
@@ -123,7 +145,139 @@ func EncodeValue(value string, dataType string, size uint32) ([]byte, error) {
 	return nil, fmt.Errorf("unsupported data type: %s", dataType)
 }
 
-// DecodeFieldValue decodes a field value from raw bytes based on its data type
+// StructSize returns the end of the last field, i.e. the maximum of
+// offset plus size of the fields. Nested fields which extend beyond the
+// size of their field are included. Padding after the last field is not
+// known from the fields, so the size of the symbol can be larger.
+func StructSize(fields []StructField) uint32 {
+	var size uint32
+	for _, f := range fields {
+		n := f.Size
+		if nested := StructSize(f.Fields); nested > n {
+			n = nested
+		}
+		if end := f.Offset + n; end > size {
+			size = end
+		}
+	}
+	return size
+}
+
+// EncodeStruct encodes a map of field values into a struct of the given size.
+// A size of zero means StructSize(fields). Values are encoded with
+// EncodeValue and nested structs are encoded from nested maps which
+// requires their Fields to be loaded. Fields which are missing from
+// values are left zero.
+func EncodeStruct(fields []StructField, values map[string]interface{}, size uint32) ([]byte, error) {
+	if size == 0 {
+		size = StructSize(fields)
+	}
+	noResolve := func(ctx context.Context, typeName string) ([]StructField, error) {
+		return nil, nil
+	}
+	return encodeStructFields(context.Background(), fields, values, size, noResolve)
+}
+
+// encodeStructFields encodes a struct and uses resolve to load the
+// fields of nested structs.
+func encodeStructFields(ctx context.Context, fields []StructField, values map[string]interface{}, size uint32, resolve typeResolver) ([]byte, error) {
+	known := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		known[f.Name] = true
+	}
+	var unknown []string
+	for name := range values {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("unknown field %q", unknown[0])
+	}
+
+	data := make([]byte, size)
+	for _, f := range fields {
+		v, ok := values[f.Name]
+		if !ok {
+			continue
+		}
+
+		fieldEnd := f.Offset + f.Size
+		if fieldEnd > size {
+			return nil, fmt.Errorf("field %s out of range", f.Name)
+		}
+
+		var b []byte
+		var err error
+		if nested, ok := v.(map[string]interface{}); ok {
+			nestedFields := f.Fields
+			if len(nestedFields) == 0 {
+				nestedFields, err = resolve(ctx, f.DataType)
+				if err != nil {
+					return nil, fmt.Errorf("field %s: %w", f.Name, err)
+				}
+			}
+			if len(nestedFields) == 0 {
+				return nil, fmt.Errorf("field %s is not a struct", f.Name)
+			}
+			b, err = encodeStructFields(ctx, nestedFields, nested, f.Size, resolve)
+		} else {
+			b, err = EncodeValue(fmt.Sprint(v), f.DataType, f.Size)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", f.Name, err)
+		}
+		copy(data[f.Offset:fieldEnd], b)
+	}
+	return data, nil
+}
+
+// primitiveSizes are the sizes in bytes of the fixed size types
+var primitiveSizes = map[string]int{
+	"BOOL":  1,
+	"SINT":  1,
+	"USINT": 1,
+	"BYTE":  1,
+	"INT":   2,
+	"UINT":  2,
+	"WORD":  2,
+	"DINT":  4,
+	"UDINT": 4,
+	"DWORD": 4,
+	"REAL":  4,
+	"LINT":  8,
+	"ULINT": 8,
+	"LWORD": 8,
+	"LREAL": 8,
+	"LTIME": 8,
+	"LDT":   8,
+	"LDATE": 8,
+	"LTOD":  8,
+}
+
+// DecodeFieldValueStrict decodes a field value like DecodeFieldValue but
+// returns an error if data is empty or shorter than the data type instead
+// of falling back to a hex string. Other types are still decoded as hex.
+func DecodeFieldValueStrict(data []byte, dataType string) (interface{}, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("no data for %s", dataType)
+	}
+	if n, ok := primitiveSizes[dataType]; ok && len(data) < n {
+		return nil, fmt.Errorf("insufficient data for %s: got %d bytes, want %d", dataType, len(data), n)
+	}
+	switch dataType {
+	case "LTIME", "LDT", "LDATE", "LTOD":
+		return decodeLTime(data, dataType, false)
+	}
+	return DecodeFieldValue(data, dataType), nil
+}
+
+// DecodeFieldValue decodes a field value from raw bytes based on its data type.
+// ARRAY OF BOOL is decoded as []bool and other unknown types as hex string.
+// LTIME is decoded as time.Duration and LDT, LDATE and LTOD as time.Time;
+// values beyond their range are capped, use DecodeFieldValueStrict to get
+// an error instead.
 func DecodeFieldValue(data []byte, dataType string) interface{} {
 	if len(data) == 0 {
 		return nil
@@ -144,38 +298,43 @@ func DecodeFieldValue(data []byte, dataType string) interface{} {
 		}
 	case "INT":
 		if len(data) >= 2 {
-			return int16(binary.LittleEndian.Uint16(data[0:2]))
+			return int16(ByteOrder.Uint16(data[0:2]))
 		}
 	case "UINT", "WORD":
 		if len(data) >= 2 {
-			return binary.LittleEndian.Uint16(data[0:2])
+			return ByteOrder.Uint16(data[0:2])
 		}
 	case "DINT":
 		if len(data) >= 4 {
-			return int32(binary.LittleEndian.Uint32(data[0:4]))
+			return int32(ByteOrder.Uint32(data[0:4]))
 		}
 	case "UDINT", "DWORD":
 		if len(data) >= 4 {
-			return binary.LittleEndian.Uint32(data[0:4])
+			return ByteOrder.Uint32(data[0:4])
 		}
 	case "LINT":
 		if len(data) >= 8 {
-			return int64(binary.LittleEndian.Uint64(data[0:8]))
+			return int64(ByteOrder.Uint64(data[0:8]))
 		}
 	case "ULINT", "LWORD":
 		if len(data) >= 8 {
-			return binary.LittleEndian.Uint64(data[0:8])
+			return ByteOrder.Uint64(data[0:8])
 		}
 	case "REAL":
 		if len(data) >= 4 {
-			bits := binary.LittleEndian.Uint32(data[0:4])
+			bits := ByteOrder.Uint32(data[0:4])
 			return math.Float32frombits(bits)
 		}
 	case "LREAL":
 		if len(data) >= 8 {
-			bits := binary.LittleEndian.Uint64(data[0:8])
+			bits := ByteOrder.Uint64(data[0:8])
 			return math.Float64frombits(bits)
 		}
+	case "LTIME", "LDT", "LDATE", "LTOD":
+		// values beyond the year 2262 are capped
+		if v, err := decodeLTime(data, dataType, true); err == nil {
+			return v
+		}
 	default:
 		// Check for STRING type
 		if len(dataType) >= 6 && dataType[:6] == "STRING" {
@@ -189,6 +348,54 @@ func DecodeFieldValue(data []byte, dataType string) interface{} {
 		}
 	}
 
+	// ARRAY OF BOOL has one byte per element
+	if arr, err := ParseArrayType(dataType); err == nil && arr.ElementType == "BOOL" && arr.Len() > 0 && len(data) >= arr.Len() {
+		return DecodeBoolArray(data, arr.Len(), false)
+	}
+
 	// For unknown types, return hex string
 	return fmt.Sprintf("%X", data)
 }
+
+// FieldChange describes a struct field whose value differs
+type FieldChange struct {
+	Path string      // Dot separated field path, e.g. "stPos.x"
+	Old  interface{} // Decoded old value
+	New  interface{} // Decoded new value
+}
+
+// DiffStruct compares two raw values of a struct and returns the fields
+// whose bytes differ in field order. Nested fields are compared
+// individually. Fields outside of old or new are decoded as nil.
+func DiffStruct(old, new []byte, fields []StructField) []FieldChange {
+	return diffStruct(old, new, fields, "", nil)
+}
+
+func diffStruct(old, new []byte, fields []StructField, prefix string, changes []FieldChange) []FieldChange {
+	for _, f := range fields {
+		path := prefix + f.Name
+		oldData, newData := fieldData(old, f), fieldData(new, f)
+		if bytes.Equal(oldData, newData) {
+			continue
+		}
+		if len(f.Fields) > 0 {
+			changes = diffStruct(oldData, newData, f.Fields, path+".", changes)
+			continue
+		}
+		changes = append(changes, FieldChange{
+			Path: path,
+			Old:  DecodeFieldValue(oldData, f.DataType),
+			New:  DecodeFieldValue(newData, f.DataType),
+		})
+	}
+	return changes
+}
+
+// fieldData returns the bytes of the field or nil if data is too short
+func fieldData(data []byte, f StructField) []byte {
+	end := int(f.Offset) + int(f.Size)
+	if end > len(data) {
+		return nil
+	}
+	return data[f.Offset:end]
+}
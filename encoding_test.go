@@ -0,0 +1,228 @@
+package goads
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/pascaldekloe/goe/verify"
+)
+
+func TestEncodeStruct(t *testing.T) {
+	fields := []StructField{
+		{Name: "bEnable", DataType: "BOOL", Offset: 0, Size: 1},
+		{Name: "nCount", DataType: "INT", Offset: 2, Size: 2},
+		{Name: "stPos", DataType: "ST_Pos", Offset: 4, Size: 2, Fields: []StructField{
+			{Name: "x", DataType: "BYTE", Offset: 0, Size: 1},
+			{Name: "y", DataType: "BYTE", Offset: 1, Size: 1},
+		}},
+	}
+
+	t.Run("values", func(t *testing.T) {
+		got, err := EncodeStruct(fields, map[string]interface{}{
+			"bEnable": true,
+			"nCount":  -2,
+			"stPos":   map[string]interface{}{"y": 5},
+		}, 6)
+		if err != nil {
+			t.Fatal(err)
+		}
+		verify.Values(t, "", got, []byte{1, 0, 0xfe, 0xff, 0, 5})
+	})
+
+	t.Run("size of fields", func(t *testing.T) {
+		got, err := EncodeStruct(fields, map[string]interface{}{"nCount": 1}, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		verify.Values(t, "", got, []byte{0, 0, 1, 0, 0, 0})
+	})
+
+	t.Run("unknown field", func(t *testing.T) {
+		if _, err := EncodeStruct(fields, map[string]interface{}{"nCnt": 1}, 6); err == nil {
+			t.Fatal("want error")
+		}
+	})
+}
+
+func TestStructSize(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields []StructField
+		want   uint32
+	}{
+		{"empty", nil, 0},
+		{
+			"padded",
+			[]StructField{
+				{Name: "bEnable", DataType: "BOOL", Offset: 0, Size: 1},
+				{Name: "fValue", DataType: "LREAL", Offset: 8, Size: 8},
+				{Name: "nCount", DataType: "INT", Offset: 16, Size: 2},
+			},
+			18,
+		},
+		{
+			"unordered",
+			[]StructField{
+				{Name: "nCount", DataType: "DINT", Offset: 4, Size: 4},
+				{Name: "nFirst", DataType: "DINT", Offset: 0, Size: 4},
+			},
+			8,
+		},
+		{
+			"nested",
+			[]StructField{
+				{Name: "bEnable", DataType: "BOOL", Offset: 0, Size: 1},
+				{Name: "stPos", DataType: "ST_Pos", Offset: 4, Size: 8, Fields: []StructField{
+					{Name: "x", DataType: "DINT", Offset: 0, Size: 4},
+					{Name: "y", DataType: "DINT", Offset: 4, Size: 4},
+				}},
+			},
+			12,
+		},
+		{
+			"nested beyond field",
+			[]StructField{
+				{Name: "stPos", DataType: "ST_Pos", Offset: 2, Size: 2, Fields: []StructField{
+					{Name: "x", DataType: "INT", Offset: 0, Size: 2},
+					{Name: "y", DataType: "INT", Offset: 2, Size: 2},
+				}},
+			},
+			6,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verify.Values(t, "", StructSize(tt.fields), tt.want)
+		})
+	}
+}
+
+func TestDiffStruct(t *testing.T) {
+	fields := []StructField{
+		{Name: "bEnable", DataType: "BOOL", Offset: 0, Size: 1},
+		{Name: "nCount", DataType: "INT", Offset: 2, Size: 2},
+		{Name: "stPos", DataType: "ST_Pos", Offset: 4, Size: 2, Fields: []StructField{
+			{Name: "x", DataType: "BYTE", Offset: 0, Size: 1},
+			{Name: "y", DataType: "BYTE", Offset: 1, Size: 1},
+		}},
+	}
+
+	tests := []struct {
+		name     string
+		old, new []byte
+		want     []FieldChange
+	}{
+		{"equal", []byte{1, 0, 2, 0, 3, 4}, []byte{1, 0, 2, 0, 3, 4}, nil},
+		{"padding only", []byte{1, 0, 2, 0, 3, 4}, []byte{1, 9, 2, 0, 3, 4}, nil},
+		{
+			"flat and nested",
+			[]byte{1, 0, 2, 0, 3, 4},
+			[]byte{0, 0, 2, 0, 3, 5},
+			[]FieldChange{
+				{Path: "bEnable", Old: true, New: false},
+				{Path: "stPos.y", Old: uint8(4), New: uint8(5)},
+			},
+		},
+		{
+			"no old value",
+			nil,
+			[]byte{1, 0, 0xfe, 0xff, 3, 4},
+			[]FieldChange{
+				{Path: "bEnable", Old: nil, New: true},
+				{Path: "nCount", Old: nil, New: int16(-2)},
+				{Path: "stPos.x", Old: nil, New: uint8(3)},
+				{Path: "stPos.y", Old: nil, New: uint8(4)},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verify.Values(t, "", DiffStruct(tt.old, tt.new, fields), tt.want)
+		})
+	}
+}
+
+func TestByteOrder(t *testing.T) {
+	defer func(order binary.ByteOrder) { ByteOrder = order }(ByteOrder)
+	ByteOrder = binary.BigEndian
+
+	data, err := EncodeValue("-2", "INT", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "EncodeValue", data, []byte{0xff, 0xfe})
+	verify.Values(t, "DecodeFieldValue", DecodeFieldValue([]byte{0, 0, 1, 0}, "UDINT"), uint32(256))
+
+	// the typed encoders follow the package byte order unless overridden
+	verify.Values(t, "TypedEncoder", NewTypedEncoder().EncodeUInt16(1), []byte{0, 1})
+	le := &TypedEncoder{ByteOrder: binary.LittleEndian}
+	verify.Values(t, "TypedEncoder little-endian", le.EncodeUInt16(1), []byte{1, 0})
+
+	v, err := (&TypedDecoder{ByteOrder: binary.LittleEndian}).DecodeUInt32([]byte{0, 0, 1, 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "TypedDecoder little-endian", v, uint32(0x10000))
+}
+
+func TestEncodeValueLiterals(t *testing.T) {
+	tests := []struct {
+		value, dataType string
+		want            []byte
+	}{
+		{"0xFF", "WORD", []byte{0xff, 0}},
+		{"0b1010", "BYTE", []byte{10}},
+		{"0o17", "UINT", []byte{15, 0}},
+		{"-0x10", "INT", []byte{0xf0, 0xff}},
+		{"0xDEADBEEF", "DWORD", []byte{0xef, 0xbe, 0xad, 0xde}},
+		{"42", "DINT", []byte{42, 0, 0, 0}},
+		{"TRUE", "BOOL", []byte{1}},
+		{"on", "BOOL", []byte{1}},
+		{"OFF", "BOOL", []byte{0}},
+		{"FALSE", "BOOL", []byte{0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dataType+" "+tt.value, func(t *testing.T) {
+			got, err := EncodeValue(tt.value, tt.dataType, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			verify.Values(t, "", got, tt.want)
+		})
+	}
+
+	for _, v := range []string{"0x10000", "0b2", "abc"} {
+		if _, err := EncodeValue(v, "WORD", 2); err == nil {
+			t.Errorf("EncodeValue(%q, WORD): want error", v)
+		}
+	}
+}
+
+func TestDecodeFieldValueStrict(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		dataType string
+		want     interface{}
+		wantErr  bool
+	}{
+		{"INT", []byte{0xfe, 0xff}, "INT", int16(-2), false},
+		{"truncated REAL", []byte{0, 0, 0x80}, "REAL", nil, true},
+		{"truncated LWORD", []byte{1, 2, 3, 4}, "LWORD", nil, true},
+		{"empty", nil, "BOOL", nil, true},
+		{"STRING", []byte("ab\x00c"), "STRING(3)", "ab", false},
+		{"unknown type", []byte{0xab, 0xcd}, "ST_Foo", "ABCD", false},
+		{"BOOL array", []byte{1, 0, 1}, "ARRAY [0..2] OF BOOL", []bool{true, false, true}, false},
+		{"overflowing BOOL array", []byte{1, 0}, "ARRAY [0..2147483647,0..4294967295] OF BOOL", "0100", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeFieldValueStrict(tt.data, tt.dataType)
+			if got, want := err != nil, tt.wantErr; got != want {
+				t.Fatalf("got error %v want error %v", err, want)
+			}
+			verify.Values(t, "", got, tt.want)
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package goads
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SetEnumType sets the members of an enum type by name. The base type
+// is the integer type of the enum, INT if empty like in TwinCAT. The
+// data type upload does not provide the members yet.
+func (s *Session) SetEnumType(typeName, baseType string, members map[string]int64) {
+	if baseType == "" {
+		baseType = "INT"
+	}
+	s.enums.Set(typeName, &DataTypeInfo{
+		Name:     typeName,
+		BaseType: baseType,
+		Enum:     members,
+	})
+}
+
+// EncodeValue is like the package function EncodeValue but also encodes
+// enums by member name if their members are set with SetEnumType.
+func (s *Session) EncodeValue(ctx context.Context, value string, dataType string, size uint32) ([]byte, error) {
+	if info, ok := s.enums.Get(dataType); ok {
+		return EncodeEnumValue(value, info.BaseType, size, info.Enum)
+	}
+	return EncodeValue(value, dataType, size)
+}
+
+// EncodeEnumValue encodes an enum value with its base type. The value is
+// the name of a member, which may be qualified like E_State#eRunning, or
+// a number. Member names are matched case-insensitively.
+func EncodeEnumValue(value string, baseType string, size uint32, members map[string]int64) ([]byte, error) {
+	name := strings.TrimSpace(value)
+	if i := strings.LastIndex(name, "#"); i >= 0 {
+		name = name[i+1:]
+	}
+	if n, ok := enumMember(members, name); ok {
+		return EncodeValue(strconv.FormatInt(n, 10), baseType, size)
+	}
+
+	if _, err := strconv.ParseInt(name, 0, 64); err != nil {
+		if _, err := strconv.ParseUint(name, 0, 64); err != nil {
+			return nil, fmt.Errorf("invalid enum value: %q is not a member", value)
+		}
+	}
+	return EncodeValue(name, baseType, size)
+}
+
+// enumMember returns the value of the member with name
+func enumMember(members map[string]int64, name string) (int64, bool) {
+	if n, ok := members[name]; ok {
+		return n, true
+	}
+	for member, n := range members {
+		if strings.EqualFold(member, name) {
+			return n, true
+		}
+	}
+	return 0, false
+}
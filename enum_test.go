@@ -0,0 +1,45 @@
+package goads
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mrpasztoradam/goads/ams"
+	"github.com/pascaldekloe/goe/verify"
+)
+
+func TestEncodeEnumValue(t *testing.T) {
+	s := (&Client{}).NewSession(ams.MustParseAddr("127.0.0.1.1.1:851"), ams.MustParseAddr("10.0.0.1.1.1:32000"))
+	s.SetEnumType("E_State", "", map[string]int64{
+		"eStateIdle":    0,
+		"eStateRunning": 1,
+		"eStateError":   -1,
+	})
+	s.SetEnumType("E_Mode", "UDINT", map[string]int64{"eModeAuto": 0x10000})
+
+	tests := []struct {
+		value    string
+		dataType string
+		want     []byte
+		wantErr  bool
+	}{
+		{"eStateRunning", "E_State", []byte{1, 0}, false},
+		{"estaterunning", "E_State", []byte{1, 0}, false},
+		{"E_State#eStateError", "E_State", []byte{0xFF, 0xFF}, false},
+		{"2", "E_State", []byte{2, 0}, false},
+		{"eStateStopped", "E_State", nil, true},
+		{"eModeAuto", "E_Mode", []byte{0, 0, 1, 0}, false},
+		{"-1", "E_Mode", nil, true},
+		{"7", "INT", []byte{7, 0}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := s.EncodeValue(context.Background(), tt.value, tt.dataType, 0)
+			if got, want := err != nil, tt.wantErr; got != want {
+				t.Fatalf("got error %v want error %v", err, want)
+			}
+			verify.Values(t, "data", got, tt.want)
+		})
+	}
+}
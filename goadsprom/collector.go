@@ -0,0 +1,143 @@
+// Package goadsprom exports PLC symbols as Prometheus metrics.
+package goadsprom
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mrpasztoradam/goads"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ErrMetricNameCollision is returned by NewCollector when two symbols
+// map to the same metric name.
+var ErrMetricNameCollision = errors.New("metric name collision")
+
+// Collector is a prometheus.Collector which reads a list of symbols
+// on every scrape and exports the numeric ones as gauges. BOOL symbols
+// are exported as 0 and 1. Symbols of other types are skipped.
+type Collector struct {
+	session *goads.Session
+	symbols []string
+	descs   map[string]*prometheus.Desc
+}
+
+// NewCollector returns a collector for the symbols of the session.
+// The metrics are named after the symbol with all characters which
+// are not valid in a metric name replaced by '_', e.g. MAIN.fTemp
+// becomes MAIN_fTemp. It returns an error if two symbols get the same
+// metric name, e.g. GVL.a and GVL_a.
+func NewCollector(session *goads.Session, symbols []string) (*Collector, error) {
+	c := &Collector{
+		session: session,
+		symbols: symbols,
+		descs:   make(map[string]*prometheus.Desc, len(symbols)),
+	}
+	names := make(map[string]string, len(symbols))
+	for _, name := range symbols {
+		metric := MetricName(name)
+		if other, ok := names[metric]; ok && other != name {
+			return nil, fmt.Errorf("%w: %s of %s and %s", ErrMetricNameCollision, metric, other, name)
+		}
+		names[metric] = name
+		c.descs[name] = prometheus.NewDesc(
+			metric,
+			"Value of the PLC symbol "+name,
+			nil, nil,
+		)
+	}
+	return c, nil
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, name := range c.symbols {
+		ch <- c.descs[name]
+	}
+}
+
+// Collect implements prometheus.Collector. All symbols are read with
+// a single sum-read request.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	ctx := context.Background()
+	results, err := c.session.ReadBatch(ctx, c.symbols)
+	if err != nil {
+		for _, name := range c.symbols {
+			ch <- prometheus.NewInvalidMetric(c.descs[name], err)
+		}
+		return
+	}
+
+	for _, name := range c.symbols {
+		desc := c.descs[name]
+		r := results[name]
+		if r == nil {
+			continue
+		}
+		if r.Error != nil {
+			ch <- prometheus.NewInvalidMetric(desc, r.Error)
+			continue
+		}
+
+		info, err := c.session.GetSymbol(ctx, name)
+		if err != nil {
+			ch <- prometheus.NewInvalidMetric(desc, err)
+			continue
+		}
+		v, ok := toFloat(goads.DecodeFieldValue(r.Data, info.DataType))
+		if !ok {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v)
+	}
+}
+
+// MetricName returns the metric name for a symbol. Different symbols
+// can have the same metric name, which NewCollector rejects.
+func MetricName(symbol string) string {
+	b := []byte(symbol)
+	for i, c := range b {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '_', c == ':':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			b[i] = '_'
+		}
+	}
+	return strings.Trim(string(b), "_")
+}
+
+// toFloat converts a decoded numeric value to float64.
+func toFloat(v interface{}) (float64, bool) {
+	switch x := v.(type) {
+	case bool:
+		if x {
+			return 1, true
+		}
+		return 0, true
+	case int8:
+		return float64(x), true
+	case uint8:
+		return float64(x), true
+	case int16:
+		return float64(x), true
+	case uint16:
+		return float64(x), true
+	case int32:
+		return float64(x), true
+	case uint32:
+		return float64(x), true
+	case int64:
+		return float64(x), true
+	case uint64:
+		return float64(x), true
+	case float32:
+		return float64(x), true
+	case float64:
+		return x, true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,130 @@
+package goadsprom
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/mrpasztoradam/goads"
+	"github.com/mrpasztoradam/goads/ams"
+	"github.com/mrpasztoradam/goads/goadstest"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// symbol is a PLC symbol of the test server
+type symbol struct {
+	dataType string
+	value    []byte
+}
+
+func TestCollector(t *testing.T) {
+	temp := make([]byte, 8)
+	binary.LittleEndian.PutUint64(temp, math.Float64bits(21.5))
+	symbols := []struct {
+		name string
+		symbol
+	}{
+		{"MAIN.fTemp", symbol{"LREAL", temp}},
+		{"MAIN.bRunning", symbol{"BOOL", []byte{1}}},
+		{"MAIN.sName", symbol{"STRING(3)", []byte("abc\x00")}},
+		{"GVL.aCount[1]", symbol{"INT", []byte{0xfe, 0xff}}},
+	}
+
+	srv := goadstest.NewServer()
+	defer srv.Close()
+
+	// symbol info by name
+	srv.HandleReadWrite(0xF009, func(req *ams.ReadWriteRequest) ([]byte, uint32) {
+		name := strings.TrimSuffix(string(req.Data), "\x00")
+		for _, s := range symbols {
+			if s.name != name {
+				continue
+			}
+			b := make([]byte, 30)
+			binary.LittleEndian.PutUint32(b[12:16], uint32(len(s.value)))
+			binary.LittleEndian.PutUint16(b[24:26], uint16(len(s.name)))
+			binary.LittleEndian.PutUint16(b[26:28], uint16(len(s.dataType)))
+			b = append(b, s.name+"\x00"+s.dataType+"\x00\x00"...)
+			binary.LittleEndian.PutUint32(b[0:4], uint32(len(b)))
+			return b, ams.NoError
+		}
+		return nil, ams.DeviceSymbolNotFound
+	})
+
+	// the handle is the index of the symbol
+	srv.HandleReadWrite(ams.IdxGetSymHandleByName, func(req *ams.ReadWriteRequest) ([]byte, uint32) {
+		for i, s := range symbols {
+			if s.name == string(req.Data) {
+				return []byte{byte(i), 0, 0, 0}, ams.NoError
+			}
+		}
+		return nil, ams.DeviceSymbolNotFound
+	})
+
+	// sum-read of values by handle
+	srv.HandleReadWrite(0xF080, func(req *ams.ReadWriteRequest) ([]byte, uint32) {
+		n := int(req.IndexOffset)
+		results := make([]byte, 4*n)
+		var data []byte
+		for i := 0; i < n; i++ {
+			handle := binary.LittleEndian.Uint32(req.Data[i*12+4:])
+			data = append(data, symbols[handle].value...)
+		}
+		return append(results, data...), ams.NoError
+	})
+
+	c := &goads.Client{Addr: srv.Addr()}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	s := c.NewSession(srv.AMSAddr(), ams.MustParseAddr("10.0.0.1.1.1:32000"))
+
+	var names []string
+	for _, s := range symbols {
+		names = append(names, s.name)
+	}
+	collector, err := NewCollector(s, names)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `
+# HELP GVL_aCount_1 Value of the PLC symbol GVL.aCount[1]
+# TYPE GVL_aCount_1 gauge
+GVL_aCount_1 -2
+# HELP MAIN_bRunning Value of the PLC symbol MAIN.bRunning
+# TYPE MAIN_bRunning gauge
+MAIN_bRunning 1
+# HELP MAIN_fTemp Value of the PLC symbol MAIN.fTemp
+# TYPE MAIN_fTemp gauge
+MAIN_fTemp 21.5
+`
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(want)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMetricName(t *testing.T) {
+	tests := map[string]string{
+		"MAIN.fTemp":          "MAIN_fTemp",
+		"GVL.aAxes[1].fPos":   "GVL_aAxes_1__fPos",
+		"1st":                 "st",
+		"MAIN.stTest.n_Value": "MAIN_stTest_n_Value",
+	}
+	for symbol, want := range tests {
+		if got := MetricName(symbol); got != want {
+			t.Errorf("MetricName(%q) got %q want %q", symbol, got, want)
+		}
+	}
+}
+
+func TestNewCollectorCollision(t *testing.T) {
+	_, err := NewCollector(nil, []string{"GVL.a", "MAIN.b", "GVL_a"})
+	if !errors.Is(err, ErrMetricNameCollision) {
+		t.Fatalf("got error %v want %v", err, ErrMetricNameCollision)
+	}
+}
@@ -0,0 +1,368 @@
+// Package goadstest provides an in-process ADS server for testing
+// ADS clients without a PLC.
+package goadstest
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+
+	"github.com/mrpasztoradam/goads/ams"
+)
+
+// Packet is the interface for the ams packet types.
+type Packet interface {
+	Header() *ams.AMSHeader
+	Encode(b *ams.Buffer) error
+	Decode(b *ams.Buffer) error
+}
+
+// HandlerFunc returns the response for a request.
+// If it returns nil no response is sent.
+type HandlerFunc func(req Packet) Packet
+
+// ReadFunc answers a Read request with data or an ADS error code.
+type ReadFunc func(req *ams.ReadRequest) (data []byte, result uint32)
+
+// WriteFunc answers a Write request with an ADS error code.
+type WriteFunc func(req *ams.WriteRequest) (result uint32)
+
+// ReadWriteFunc answers a ReadWrite request with data or an ADS error code.
+type ReadWriteFunc func(req *ams.ReadWriteRequest) (data []byte, result uint32)
+
+// Server is an ADS server listening on a local TCP port.
+//
+// Read, Write and ReadWrite requests are answered by the functions
+// registered for their index group and with DeviceServiceNotSupported
+// otherwise. ReadState, ReadDeviceInfo and the notification commands
+// have default handlers which can be replaced with Handle.
+type Server struct {
+	l       net.Listener
+	amsAddr ams.Addr
+
+	mu         sync.Mutex
+	handlers   map[uint16]HandlerFunc
+	reads      map[uint32]ReadFunc
+	writes     map[uint32]WriteFunc
+	readWrites map[uint32]ReadWriteFunc
+	conns      map[*conn]bool
+	nextHandle uint32
+
+	wg sync.WaitGroup
+}
+
+// conn is a client connection to the server.
+type conn struct {
+	net.Conn
+	mu   sync.Mutex // serializes writes
+	peer ams.Addr   // sender of the last request
+}
+
+// NewServer starts a server on a random local port which answers
+// as 127.0.0.1.1.1:851. It panics if the server cannot listen.
+func NewServer() *Server {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic("goadstest: failed to listen: " + err.Error())
+	}
+
+	s := &Server{
+		l:          l,
+		amsAddr:    ams.MustParseAddr("127.0.0.1.1.1:851"),
+		handlers:   make(map[uint16]HandlerFunc),
+		reads:      make(map[uint32]ReadFunc),
+		writes:     make(map[uint32]WriteFunc),
+		readWrites: make(map[uint32]ReadWriteFunc),
+		conns:      make(map[*conn]bool),
+	}
+
+	s.wg.Add(1)
+	go s.serve()
+	return s
+}
+
+// Addr returns the TCP address of the server for Client.Addr.
+func (s *Server) Addr() string {
+	return s.l.Addr().String()
+}
+
+// AMSAddr returns the AMS address the server answers as.
+func (s *Server) AMSAddr() ams.Addr {
+	return s.amsAddr
+}
+
+// Handle registers a handler for all requests with the command id.
+// It takes precedence over the index group handlers.
+func (s *Server) Handle(cmdID uint16, fn HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[cmdID] = fn
+}
+
+// HandleRead registers a handler for Read requests for the index group.
+func (s *Server) HandleRead(indexGroup uint32, fn ReadFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reads[indexGroup] = fn
+}
+
+// HandleWrite registers a handler for Write requests for the index group.
+func (s *Server) HandleWrite(indexGroup uint32, fn WriteFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.writes[indexGroup] = fn
+}
+
+// HandleReadWrite registers a handler for ReadWrite requests for the index group.
+func (s *Server) HandleReadWrite(indexGroup uint32, fn ReadWriteFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.readWrites[indexGroup] = fn
+}
+
+// Notify sends a device notification with the stamps to all
+// connected clients.
+func (s *Server) Notify(stamps ...ams.NotificationStamp) error {
+	return s.NotifyFrom(s.amsAddr, stamps...)
+}
+
+// NotifyFrom is like Notify but sends the notification as sender,
+// e.g. as a second PLC runtime on another port.
+func (s *Server) NotifyFrom(sender ams.Addr, stamps ...ams.NotificationStamp) error {
+	s.mu.Lock()
+	conns := make([]*conn, 0, len(s.conns))
+	for c := range s.conns {
+		conns = append(conns, c)
+	}
+	s.mu.Unlock()
+
+	var firstErr error
+	for _, c := range conns {
+		c.mu.Lock()
+		peer := c.peer
+		c.mu.Unlock()
+
+		req := ams.NewDeviceNotificationRequest(peer, sender, stamps)
+		if err := c.send(req); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// CloseConnections closes all client connections but keeps
+// the server running.
+func (s *Server) CloseConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.conns {
+		c.Close()
+	}
+}
+
+// Close stops the server and closes all client connections.
+func (s *Server) Close() error {
+	err := s.l.Close()
+	s.CloseConnections()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		nc, err := s.l.Accept()
+		if err != nil {
+			return
+		}
+		s.ServeConn(nc)
+	}
+}
+
+// ServeConn serves the requests on a connection, e.g. one end of
+// net.Pipe for Client.DialConn, until it or the server is closed.
+func (s *Server) ServeConn(nc net.Conn) {
+	c := &conn{Conn: nc}
+	s.mu.Lock()
+	s.conns[c] = true
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go s.handleConn(c)
+}
+
+func (s *Server) handleConn(c *conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+		c.Close()
+	}()
+
+	for {
+		data, err := readPacket(c)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) && !errors.Is(err, io.ErrClosedPipe) {
+				log.Printf("goadstest: read failed: %s", err)
+			}
+			return
+		}
+
+		req, err := decodeRequest(data)
+		if err != nil {
+			log.Printf("goadstest: %s", err)
+			continue
+		}
+		if req == nil {
+			continue
+		}
+
+		c.mu.Lock()
+		c.peer = req.Header().Sender
+		c.mu.Unlock()
+
+		resp := s.handle(req)
+		if resp == nil {
+			continue
+		}
+		resp.Header().InvokeID = req.Header().InvokeID
+		if err := c.send(resp); err != nil {
+			return
+		}
+	}
+}
+
+// readPacket reads a single AMS/TCP packet including the TCP header.
+func readPacket(r io.Reader) ([]byte, error) {
+	hdr := make([]byte, 6)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return nil, err
+	}
+	n := binary.LittleEndian.Uint32(hdr[2:])
+	data := make([]byte, 6+int(n))
+	copy(data, hdr)
+	if _, err := io.ReadFull(r, data[6:]); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// decodeRequest decodes a request packet. It returns nil
+// for responses and unknown commands.
+func decodeRequest(data []byte) (Packet, error) {
+	var hdr ams.Header
+	if err := hdr.Decode(ams.NewBuffer(data)); err != nil {
+		return nil, err
+	}
+	if ams.HasState(hdr.AMSHeader, ams.StateResponse) {
+		return nil, nil
+	}
+
+	var req Packet
+	switch hdr.CmdID {
+	case ams.CmdADSReadDeviceInfo:
+		req = &ams.ReadDeviceInfoRequest{}
+	case ams.CmdADSRead:
+		req = &ams.ReadRequest{}
+	case ams.CmdADSWrite:
+		req = &ams.WriteRequest{}
+	case ams.CmdADSReadState:
+		req = &ams.ReadStateRequest{}
+	case ams.CmdADSAddDeviceNotification:
+		req = &ams.AddDeviceNotificationRequest{}
+	case ams.CmdADSDeleteDeviceNotification:
+		req = &ams.DeleteDeviceNotificationRequest{}
+	case ams.CmdADSReadWrite:
+		req = &ams.ReadWriteRequest{}
+	default:
+		log.Printf("goadstest: unknown command %d", hdr.CmdID)
+		return nil, nil
+	}
+
+	if err := req.Decode(ams.NewBuffer(data)); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// handle returns the response for a request.
+func (s *Server) handle(req Packet) Packet {
+	h := req.Header()
+	s.mu.Lock()
+	fn := s.handlers[h.CmdID]
+	s.mu.Unlock()
+	if fn != nil {
+		return fn(req)
+	}
+
+	switch r := req.(type) {
+	case *ams.ReadRequest:
+		s.mu.Lock()
+		fn := s.reads[r.IndexGroup]
+		s.mu.Unlock()
+		if fn == nil {
+			return ams.NewReadResponse(h.Sender, h.Target, ams.DeviceServiceNotSupported, nil)
+		}
+		data, result := fn(r)
+		return ams.NewReadResponse(h.Sender, h.Target, result, data)
+
+	case *ams.WriteRequest:
+		s.mu.Lock()
+		fn := s.writes[r.IndexGroup]
+		s.mu.Unlock()
+		if fn == nil {
+			return ams.NewWriteResponse(h.Sender, h.Target, ams.DeviceServiceNotSupported)
+		}
+		return ams.NewWriteResponse(h.Sender, h.Target, fn(r))
+
+	case *ams.ReadWriteRequest:
+		s.mu.Lock()
+		fn := s.readWrites[r.IndexGroup]
+		s.mu.Unlock()
+		if fn == nil {
+			return ams.NewReadWriteResponse(h.Sender, h.Target, ams.DeviceServiceNotSupported, nil)
+		}
+		data, result := fn(r)
+		return ams.NewReadWriteResponse(h.Sender, h.Target, result, data)
+
+	case *ams.ReadStateRequest:
+		return ams.NewReadStateResponse(h.Sender, h.Target, ams.NoError, ams.ADSStateRun, 0)
+
+	case *ams.ReadDeviceInfoRequest:
+		return ams.NewReadDeviceInfoResponse(h.Sender, h.Target, ams.NoError, 3, 1, 4024, "goadstest")
+
+	case *ams.AddDeviceNotificationRequest:
+		s.mu.Lock()
+		s.nextHandle++
+		handle := s.nextHandle
+		s.mu.Unlock()
+		return ams.NewAddDeviceNotificationResponse(h.Sender, h.Target, ams.NoError, handle)
+
+	case *ams.DeleteDeviceNotificationRequest:
+		return ams.NewDeleteDeviceNotificationResponse(h.Sender, h.Target, ams.NoError)
+
+	default:
+		return nil
+	}
+}
+
+// send encodes and writes a packet to the connection.
+func (c *conn) send(p Packet) error {
+	var b ams.Buffer
+	if err := p.Encode(&b); err != nil {
+		return err
+	}
+	if err := ams.ValidatePacket(b.Bytes()); err != nil {
+		return fmt.Errorf("goadstest: invalid %T: %w", p, err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err := c.Write(b.Bytes())
+	return err
+}
@@ -0,0 +1,92 @@
+package goadstest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mrpasztoradam/goads"
+	"github.com/mrpasztoradam/goads/ams"
+	"github.com/mrpasztoradam/goads/goadstest"
+	"github.com/pascaldekloe/goe/verify"
+)
+
+var sender = ams.MustParseAddr("10.0.0.1.1.1:32000")
+
+func dial(t *testing.T, srv *goadstest.Server) *goads.Client {
+	t.Helper()
+	c := &goads.Client{Addr: srv.Addr(), ReadTimeout: time.Second}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestServerRead(t *testing.T) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+
+	srv.HandleRead(0x4020, func(req *ams.ReadRequest) ([]byte, uint32) {
+		if req.IndexOffset != 8 {
+			return nil, ams.DeviceSymbolNotFound
+		}
+		return []byte{0x01, 0x02}, ams.NoError
+	})
+
+	c := dial(t, srv)
+	ctx := context.Background()
+
+	resp, err := c.Read(ctx, ams.NewReadRequest(srv.AMSAddr(), sender, 0x4020, 8, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "result", resp.Result, uint32(ams.NoError))
+	verify.Values(t, "data", resp.Data, []byte{0x01, 0x02})
+
+	resp, err = c.Read(ctx, ams.NewReadRequest(srv.AMSAddr(), sender, 0x4020, 4, 2))
+	if !errors.Is(err, ams.ADSError(ams.DeviceSymbolNotFound)) {
+		t.Fatalf("got error %v want %v", err, ams.ADSError(ams.DeviceSymbolNotFound))
+	}
+	verify.Values(t, "result", resp.Result, uint32(ams.DeviceSymbolNotFound))
+
+	resp, err = c.Read(ctx, ams.NewReadRequest(srv.AMSAddr(), sender, 0x4021, 0, 2))
+	if !errors.Is(err, ams.ADSError(ams.DeviceServiceNotSupported)) {
+		t.Fatalf("got error %v want %v", err, ams.ADSError(ams.DeviceServiceNotSupported))
+	}
+	verify.Values(t, "result", resp.Result, uint32(ams.DeviceServiceNotSupported))
+}
+
+func TestServerNotify(t *testing.T) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+
+	c := dial(t, srv)
+	got := make(chan *ams.DeviceNotificationRequest, 1)
+	c.SetNotificationCallback(func(req *ams.DeviceNotificationRequest) {
+		got <- req
+	})
+
+	// make a request so that the server knows the client address
+	if _, err := c.ReadDeviceInfo(context.Background(), ams.NewReadDeviceInfoRequest(srv.AMSAddr(), sender)); err != nil {
+		t.Fatal(err)
+	}
+
+	stamp := ams.NotificationStamp{
+		Timestamp: 116444736000000000,
+		Samples:   []ams.NotificationSample{{Handle: 7, Data: []byte{0x2a}}},
+	}
+	if err := srv.Notify(stamp); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case req := <-got:
+		verify.Values(t, "target", req.Header().Target, sender)
+		verify.Values(t, "handle", req.Stamps[0].Samples[0].Handle, uint32(7))
+		verify.Values(t, "data", req.Stamps[0].Samples[0].Data, []byte{0x2a})
+	case <-time.After(time.Second):
+		t.Fatal("no notification")
+	}
+}
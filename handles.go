@@ -0,0 +1,231 @@
+package goads
+
+import (
+	"container/list"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mrpasztoradam/goads/ams"
+)
+
+// handleCache tracks the names of the symbols with an open handle
+// in least recently used order.
+type handleCache struct {
+	mu    sync.Mutex
+	max   int                      // 0 means unlimited
+	order *list.List               // names, most recently used first
+	elems map[string]*list.Element // by name
+}
+
+// setMax sets the maximum number of handles.
+func (c *handleCache) setMax(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if n < 0 {
+		n = 0
+	}
+	c.max = n
+}
+
+// touch marks the handle of the symbol as used.
+func (c *handleCache) touch(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.elems[name]; ok {
+		c.order.MoveToFront(e)
+	}
+}
+
+// add adds the handle of the symbol and returns the names of
+// the least recently used symbols whose handles exceed the maximum.
+func (c *handleCache) add(name string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.order == nil {
+		c.order = list.New()
+		c.elems = make(map[string]*list.Element)
+	}
+
+	if e, ok := c.elems[name]; ok {
+		c.order.MoveToFront(e)
+	} else {
+		c.elems[name] = c.order.PushFront(name)
+	}
+
+	var evict []string
+	for c.max > 0 && c.order.Len() > c.max {
+		e := c.order.Back()
+		c.order.Remove(e)
+		n := e.Value.(string)
+		delete(c.elems, n)
+		evict = append(evict, n)
+	}
+	return evict
+}
+
+// remove removes the handle of the symbol.
+func (c *handleCache) remove(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.elems[name]; ok {
+		c.order.Remove(e)
+		delete(c.elems, name)
+	}
+}
+
+// clear removes all handles.
+func (c *handleCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.order = nil
+	c.elems = nil
+}
+
+// len returns the number of tracked handles.
+func (c *handleCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.elems)
+}
+
+// handleCall is an in-flight handle request.
+type handleCall struct {
+	done   chan struct{}
+	handle uint32
+	err    error
+}
+
+// handleGroup coalesces concurrent handle requests for the same symbol
+// into a single request to the PLC.
+type handleGroup struct {
+	mu    sync.Mutex
+	calls map[string]*handleCall
+}
+
+// do calls fn for the symbol unless a call for the symbol is already
+// in flight. In that case it waits for that call and returns its result.
+func (g *handleGroup) do(name string, fn func() (uint32, error)) (uint32, error) {
+	g.mu.Lock()
+	if c, ok := g.calls[name]; ok {
+		g.mu.Unlock()
+		<-c.done
+		return c.handle, c.err
+	}
+	if g.calls == nil {
+		g.calls = make(map[string]*handleCall)
+	}
+	c := &handleCall{done: make(chan struct{})}
+	g.calls[name] = c
+	g.mu.Unlock()
+
+	c.handle, c.err = fn()
+	close(c.done)
+
+	g.mu.Lock()
+	delete(g.calls, name)
+	g.mu.Unlock()
+	return c.handle, c.err
+}
+
+// AcquireHandles gets and caches the handles of all symbols which do not
+// have one yet with a single sum-up request. If the PLC does not support
+// sum-up requests the handles are acquired one by one. It returns the
+// first error but caches all handles which could be acquired.
+func (s *Session) AcquireHandles(ctx context.Context, names []string) error {
+	s.dropStaleHandles()
+
+	var missing []string
+	for _, name := range names {
+		if info, ok := s.registry.Get(name); ok && info.Handle != 0 {
+			continue
+		}
+		missing = append(missing, name)
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	ops := make([]SumOp, len(missing))
+	for i, name := range missing {
+		ops[i] = SumOp{
+			IndexGroup: ams.IdxGetSymHandleByName,
+			ReadLength: 4,
+			WriteData:  []byte(name),
+		}
+	}
+
+	results, err := s.SumReadWrite(ctx, ops)
+	if err != nil {
+		// fall back to individual requests
+		var firstErr error
+		for _, name := range missing {
+			if _, err := s.getOrCreateHandle(ctx, name); err != nil && firstErr == nil {
+				firstErr = fmt.Errorf("failed to get handle for %s: %w", name, err)
+			}
+		}
+		return firstErr
+	}
+
+	var firstErr error
+	for i, r := range results {
+		name := missing[i]
+		switch {
+		case r.Error != nil:
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to get handle for %s: %w", name, r.Error)
+			}
+		case len(r.Data) < 4:
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to get handle for %s: not enough data: %d", name, len(r.Data))
+			}
+		default:
+			s.storeHandle(ctx, name, binary.LittleEndian.Uint32(r.Data))
+		}
+	}
+	return firstErr
+}
+
+// scopedReleaseTimeout limits the time to release the handles of a
+// session from WithContext after its context is done.
+const scopedReleaseTimeout = 5 * time.Second
+
+// WithContext returns a session for the lifetime of ctx, e.g. for the
+// symbols of a single HTTP request. It sends its requests like s and
+// uses the symbols and data types cached by s, but the handles it
+// creates are its own. They are released with Close when ctx is done.
+// Handles which s also holds because the client caches handles are
+// only released by the last session holding them. The returned session must not be used after ctx
+// is done, and ctx must be done eventually or the handles are kept.
+func (s *Session) WithContext(ctx context.Context) *Session {
+	s.mu.RLock()
+	scoped := &Session{
+		client:         s.client,
+		targetAddr:     s.targetAddr,
+		senderAddr:     s.senderAddr,
+		registry:       NewSymbolRegistry(),
+		symbolVersion:  s.symbolVersion,
+		readChunkSize:  s.readChunkSize,
+		verifyEpsilon:  s.verifyEpsilon,
+		noValueByName:  s.noValueByName,
+		maxSymbolTable: s.maxSymbolTable,
+		connection:     clientConnections(s.client),
+		types:          s.types,
+		layouts:        s.layouts,
+		enums:          s.enums,
+		parent:         s,
+	}
+	s.mu.RUnlock()
+
+	go func() {
+		<-ctx.Done()
+		releaseCtx, cancel := context.WithTimeout(context.Background(), scopedReleaseTimeout)
+		defer cancel()
+		if err := scoped.Close(releaseCtx); err != nil {
+			scoped.logf("session: failed to release handles: %s", err)
+		}
+	}()
+	return scoped
+}
@@ -0,0 +1,464 @@
+package goads
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mrpasztoradam/goads/ams"
+	"github.com/mrpasztoradam/goads/goadstest"
+	"github.com/pascaldekloe/goe/verify"
+)
+
+func TestHandleCache(t *testing.T) {
+	var c handleCache
+	c.setMax(2)
+	verify.Values(t, "add a", c.add("a"), []string(nil))
+	verify.Values(t, "add b", c.add("b"), []string(nil))
+	c.touch("a")
+	verify.Values(t, "add c", c.add("c"), []string{"b"})
+	c.remove("a")
+	verify.Values(t, "add d", c.add("d"), []string(nil))
+	verify.Values(t, "len", c.len(), 2)
+
+	c.setMax(0)
+	verify.Values(t, "add e", c.add("e"), []string(nil))
+	verify.Values(t, "len unlimited", c.len(), 3)
+}
+
+func TestSessionRetryInvalidHandle(t *testing.T) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+
+	// handle 1 was released, e.g. by an eviction during the read
+	srv.HandleReadWrite(ams.IdxGetSymHandleByName, func(req *ams.ReadWriteRequest) ([]byte, uint32) {
+		return []byte{2, 0, 0, 0}, ams.NoError
+	})
+	srv.HandleRead(0xF005, func(req *ams.ReadRequest) ([]byte, uint32) {
+		if req.IndexOffset != 2 {
+			return nil, ams.DeviceInvalidHandle
+		}
+		return []byte{7, 0}, ams.NoError
+	})
+	srv.HandleWrite(0xF005, func(req *ams.WriteRequest) uint32 {
+		if req.IndexOffset != 2 {
+			return ams.DeviceInvalidHandle
+		}
+		return ams.NoError
+	})
+
+	c := &Client{Addr: srv.Addr()}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	s := c.NewSession(srv.AMSAddr(), ams.MustParseAddr("10.0.0.1.1.1:32000"))
+	s.registry.Set("MAIN.a", &SymbolInfo{Name: "MAIN.a", DataType: "INT", Size: 2, Handle: 1})
+	s.registry.Set("MAIN.b", &SymbolInfo{Name: "MAIN.b", DataType: "INT", Size: 2, Handle: 1})
+
+	data, _, err := s.Read(context.Background(), "MAIN.a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "data", data, []byte{7, 0})
+	a, _ := s.registry.Get("MAIN.a")
+	verify.Values(t, "new handle", a.Handle, uint32(2))
+
+	if err := s.Write(context.Background(), "MAIN.b", []byte{8, 0}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSessionMaxHandles(t *testing.T) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+
+	handles := make(map[string]uint32)
+	var nextHandle uint32
+	var released []uint32
+	srv.HandleReadWrite(ams.IdxGetSymHandleByName, func(req *ams.ReadWriteRequest) ([]byte, uint32) {
+		h, ok := handles[string(req.Data)]
+		if !ok {
+			nextHandle++
+			h = nextHandle
+			handles[string(req.Data)] = h
+		}
+		data := make([]byte, 4)
+		binary.LittleEndian.PutUint32(data, h)
+		return data, ams.NoError
+	})
+	srv.HandleWrite(0xF006, func(req *ams.WriteRequest) uint32 {
+		h := binary.LittleEndian.Uint32(req.Data)
+		released = append(released, h)
+		for name, v := range handles {
+			if v == h {
+				delete(handles, name)
+			}
+		}
+		return ams.NoError
+	})
+	srv.HandleRead(0xF005, func(req *ams.ReadRequest) ([]byte, uint32) {
+		return make([]byte, req.Length), ams.NoError
+	})
+
+	c := &Client{Addr: srv.Addr()}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	s := c.NewSession(srv.AMSAddr(), ams.MustParseAddr("10.0.0.1.1.1:32000"))
+	s.SetMaxHandles(2)
+	for _, name := range []string{"MAIN.a", "MAIN.b", "MAIN.c", "MAIN.d"} {
+		s.registry.Set(name, &SymbolInfo{Name: name, DataType: "INT", Size: 2})
+	}
+
+	ctx := context.Background()
+	for _, name := range []string{"MAIN.a", "MAIN.b", "MAIN.a", "MAIN.c", "MAIN.d"} {
+		if _, _, err := s.Read(ctx, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// MAIN.b and then MAIN.a were the least recently used
+	verify.Values(t, "released", released, []uint32{2, 1})
+	verify.Values(t, "open handles", handles, map[string]uint32{"MAIN.c": 3, "MAIN.d": 4})
+	info, _ := s.registry.Get("MAIN.a")
+	verify.Values(t, "evicted handle", info.Handle, uint32(0))
+}
+
+func TestReleaseHandle(t *testing.T) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+
+	srv.HandleWrite(0xF006, func(req *ams.WriteRequest) uint32 {
+		if binary.LittleEndian.Uint32(req.Data) == 1 {
+			return ams.NoError
+		}
+		return 0x711 // ADSERR_DEVICE_INVALIDHANDLE
+	})
+
+	c := &Client{Addr: srv.Addr()}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	s := c.NewSession(srv.AMSAddr(), ams.MustParseAddr("10.0.0.1.1.1:32000"))
+	s.registry.Set("MAIN.a", &SymbolInfo{Name: "MAIN.a", Handle: 1})
+	s.registry.Set("MAIN.b", &SymbolInfo{Name: "MAIN.b", Handle: 2})
+
+	if err := s.Close(context.Background()); err == nil {
+		t.Fatal("want error for invalid handle")
+	}
+
+	a, _ := s.registry.Get("MAIN.a")
+	verify.Values(t, "released handle", a.Handle, uint32(0))
+	b, _ := s.registry.Get("MAIN.b")
+	verify.Values(t, "failed handle", b.Handle, uint32(2))
+}
+
+func TestSessionHandleCoalescing(t *testing.T) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+
+	var requests int32
+	srv.HandleReadWrite(ams.IdxGetSymHandleByName, func(req *ams.ReadWriteRequest) ([]byte, uint32) {
+		atomic.AddInt32(&requests, 1)
+		// give the other readers time to pile up
+		time.Sleep(20 * time.Millisecond)
+		return []byte{1, 0, 0, 0}, ams.NoError
+	})
+	srv.HandleRead(0xF005, func(req *ams.ReadRequest) ([]byte, uint32) {
+		return make([]byte, req.Length), ams.NoError
+	})
+
+	c := &Client{Addr: srv.Addr()}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	s := c.NewSession(srv.AMSAddr(), ams.MustParseAddr("10.0.0.1.1.1:32000"))
+	s.registry.Set("MAIN.a", &SymbolInfo{Name: "MAIN.a", DataType: "INT", Size: 2})
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 50)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, err := s.Read(context.Background(), "MAIN.a"); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	verify.Values(t, "handle requests", atomic.LoadInt32(&requests), int32(1))
+}
+
+func TestAcquireHandles(t *testing.T) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+
+	var sumRequests, singleRequests int32
+	srv.HandleReadWrite(0xF082, func(req *ams.ReadWriteRequest) ([]byte, uint32) {
+		atomic.AddInt32(&sumRequests, 1)
+		n := int(req.IndexOffset)
+		results := make([]byte, 8*n)
+		var data []byte
+		writeOffset := 16 * n
+		for i := 0; i < n; i++ {
+			writeLen := binary.LittleEndian.Uint32(req.Data[i*16+12:])
+			name := string(req.Data[writeOffset : writeOffset+int(writeLen)])
+			writeOffset += int(writeLen)
+
+			if name == "MAIN.missing" {
+				binary.LittleEndian.PutUint32(results[i*8:], ams.DeviceSymbolNotFound)
+				continue
+			}
+			binary.LittleEndian.PutUint32(results[i*8+4:], 4)
+			h := make([]byte, 4)
+			binary.LittleEndian.PutUint32(h, uint32(100+i))
+			data = append(data, h...)
+		}
+		return append(results, data...), ams.NoError
+	})
+	srv.HandleReadWrite(ams.IdxGetSymHandleByName, func(req *ams.ReadWriteRequest) ([]byte, uint32) {
+		atomic.AddInt32(&singleRequests, 1)
+		return []byte{1, 0, 0, 0}, ams.NoError
+	})
+
+	c := &Client{Addr: srv.Addr()}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	s := c.NewSession(srv.AMSAddr(), ams.MustParseAddr("10.0.0.1.1.1:32000"))
+	s.registry.Set("MAIN.a", &SymbolInfo{Name: "MAIN.a", DataType: "INT", Size: 2})
+	s.registry.Set("MAIN.cached", &SymbolInfo{Name: "MAIN.cached", DataType: "INT", Size: 2, Handle: 7})
+
+	err := s.AcquireHandles(context.Background(), []string{"MAIN.a", "MAIN.cached", "MAIN.b", "MAIN.missing"})
+	if err == nil {
+		t.Fatal("want error for MAIN.missing")
+	}
+
+	got := make(map[string]uint32)
+	for _, name := range []string{"MAIN.a", "MAIN.b", "MAIN.cached", "MAIN.missing"} {
+		if info, ok := s.registry.Get(name); ok {
+			got[name] = info.Handle
+		}
+	}
+	verify.Values(t, "handles", got, map[string]uint32{"MAIN.a": 100, "MAIN.b": 101, "MAIN.cached": 7})
+	verify.Values(t, "sum requests", atomic.LoadInt32(&sumRequests), int32(1))
+	verify.Values(t, "single requests", atomic.LoadInt32(&singleRequests), int32(0))
+}
+
+func TestHandlesAfterReconnect(t *testing.T) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+
+	// handles are only valid until the connection is closed
+	var mu sync.Mutex
+	var next uint32
+	valid := make(map[uint32]bool)
+	srv.HandleReadWrite(ams.IdxGetSymHandleByName, func(req *ams.ReadWriteRequest) ([]byte, uint32) {
+		mu.Lock()
+		defer mu.Unlock()
+		next++
+		valid[next] = true
+		h := make([]byte, 4)
+		binary.LittleEndian.PutUint32(h, next)
+		return h, ams.NoError
+	})
+	srv.HandleRead(0xF005, func(req *ams.ReadRequest) ([]byte, uint32) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !valid[req.IndexOffset] {
+			return nil, ams.DeviceInvalidHandle
+		}
+		return []byte{byte(req.IndexOffset), 0}, ams.NoError
+	})
+
+	c := NewClient(srv.Addr(), WithAutoReconnect(10*time.Millisecond))
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	s := c.NewSession(srv.AMSAddr(), ams.MustParseAddr("10.0.0.1.1.1:32000"))
+	s.registry.Set("MAIN.nValue", &SymbolInfo{Name: "MAIN.nValue", DataType: "INT", Size: 2})
+
+	ctx := context.Background()
+	data, _, err := s.Read(ctx, "MAIN.nValue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "first handle", data, []byte{1, 0})
+
+	mu.Lock()
+	valid = make(map[uint32]bool)
+	mu.Unlock()
+	srv.CloseConnections()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		data, _, err = s.Read(ctx, "MAIN.nValue")
+		if err == nil {
+			break
+		}
+		if errors.Is(err, ams.ADSError(ams.DeviceInvalidHandle)) {
+			t.Fatalf("read with stale handle: %v", err)
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("not reconnected: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	verify.Values(t, "new handle", data, []byte{2, 0})
+}
+
+func TestSessionWithContext(t *testing.T) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+
+	var nextHandle uint32
+	srv.HandleReadWrite(ams.IdxGetSymHandleByName, func(req *ams.ReadWriteRequest) ([]byte, uint32) {
+		data := make([]byte, 4)
+		binary.LittleEndian.PutUint32(data, atomic.AddUint32(&nextHandle, 1))
+		return data, ams.NoError
+	})
+	released := make(chan uint32, 10)
+	srv.HandleWrite(0xF006, func(req *ams.WriteRequest) uint32 {
+		released <- binary.LittleEndian.Uint32(req.Data)
+		return ams.NoError
+	})
+	srv.HandleRead(0xF005, func(req *ams.ReadRequest) ([]byte, uint32) {
+		return make([]byte, req.Length), ams.NoError
+	})
+
+	// the client returns the handle of the parent to the scoped session
+	c := &Client{Addr: srv.Addr(), CacheHandles: true}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	s := c.NewSession(srv.AMSAddr(), ams.MustParseAddr("10.0.0.1.1.1:32000"))
+	for _, name := range []string{"MAIN.a", "MAIN.b", "MAIN.c"} {
+		s.registry.Set(name, &SymbolInfo{Name: name, DataType: "INT", Size: 2})
+	}
+	if _, _, err := s.Read(context.Background(), "MAIN.a"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	scoped := s.WithContext(ctx)
+	for _, name := range []string{"MAIN.a", "MAIN.b", "MAIN.c"} {
+		if _, _, err := scoped.Read(ctx, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b, _ := scoped.registry.Get("MAIN.b")
+	verify.Values(t, "scoped handle", b.Handle, uint32(2))
+	if parent, _ := s.registry.Get("MAIN.b"); parent.Handle != 0 {
+		t.Errorf("parent got handle %d of the scoped session", parent.Handle)
+	}
+
+	// the parent picks up the cached handle of the scoped session
+	if _, _, err := s.Read(context.Background(), "MAIN.b"); err != nil {
+		t.Fatal(err)
+	}
+
+	cancel()
+	select {
+	case h := <-released:
+		verify.Values(t, "released", h, uint32(3))
+	case <-time.After(time.Second):
+		t.Fatal("handle not released")
+	}
+
+	// releasing again is harmless
+	if err := scoped.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case h := <-released:
+		t.Errorf("released handle %d again", h)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// the parent still has its handles
+	for _, name := range []string{"MAIN.a", "MAIN.b"} {
+		if _, _, err := s.Read(context.Background(), name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	verify.Values(t, "handle requests", atomic.LoadUint32(&nextHandle), uint32(3))
+	if err := s.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "released by parent", len(released), 2)
+}
+
+func TestSessionsShareCachedHandles(t *testing.T) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+
+	var nextHandle uint32
+	srv.HandleReadWrite(ams.IdxGetSymHandleByName, func(req *ams.ReadWriteRequest) ([]byte, uint32) {
+		data := make([]byte, 4)
+		binary.LittleEndian.PutUint32(data, atomic.AddUint32(&nextHandle, 1))
+		return data, ams.NoError
+	})
+	released := make(chan uint32, 10)
+	srv.HandleWrite(0xF006, func(req *ams.WriteRequest) uint32 {
+		released <- binary.LittleEndian.Uint32(req.Data)
+		return ams.NoError
+	})
+	srv.HandleRead(0xF005, func(req *ams.ReadRequest) ([]byte, uint32) {
+		return make([]byte, req.Length), ams.NoError
+	})
+
+	c := &Client{Addr: srv.Addr(), CacheHandles: true}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ctx := context.Background()
+	sender := ams.MustParseAddr("10.0.0.1.1.1:32000")
+	var sessions []*Session
+	for i := 0; i < 2; i++ {
+		s := c.NewSession(srv.AMSAddr(), sender)
+		s.registry.Set("MAIN.a", &SymbolInfo{Name: "MAIN.a", DataType: "INT", Size: 2})
+		if _, _, err := s.Read(ctx, "MAIN.a"); err != nil {
+			t.Fatal(err)
+		}
+		sessions = append(sessions, s)
+	}
+	verify.Values(t, "handle requests", atomic.LoadUint32(&nextHandle), uint32(1))
+
+	// the second session still holds the handle
+	if err := sessions[0].Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "released by first", len(released), 0)
+
+	if err := sessions[1].Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "released by last", <-released, uint32(1))
+	verify.Values(t, "released twice", len(released), 0)
+}
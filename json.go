@@ -0,0 +1,182 @@
+package goads
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// intBits are the sizes of the integer types and whether they are signed
+var intBits = map[string]struct {
+	bits   int
+	signed bool
+}{
+	"SINT":  {8, true},
+	"USINT": {8, false},
+	"BYTE":  {8, false},
+	"INT":   {16, true},
+	"UINT":  {16, false},
+	"WORD":  {16, false},
+	"DINT":  {32, true},
+	"UDINT": {32, false},
+	"DWORD": {32, false},
+	"LINT":  {64, true},
+	"ULINT": {64, false},
+	"LWORD": {64, false},
+}
+
+// stringSize returns the size in bytes of a STRING(n) type including
+// the null terminator. A plain STRING has 80 characters.
+func stringSize(dataType string) (uint32, error) {
+	n, wide, err := stringCapacity(dataType)
+	if err != nil || wide {
+		return 0, fmt.Errorf("invalid string type: %s", dataType)
+	}
+	return uint32(n) + 1, nil
+}
+
+// ValueToJSON encodes a value returned by DecodeFieldValue as JSON.
+// The Go type of the value must match the data type. Structs are passed
+// as []StructField with populated values and are encoded as JSON objects
+// in field order.
+func ValueToJSON(v interface{}, dataType string) (json.RawMessage, error) {
+	if fields, ok := v.([]StructField); ok {
+		return structToJSON(fields)
+	}
+
+	var ok bool
+	switch dataType {
+	case "BOOL":
+		_, ok = v.(bool)
+	case "SINT":
+		_, ok = v.(int8)
+	case "USINT", "BYTE":
+		_, ok = v.(uint8)
+	case "INT":
+		_, ok = v.(int16)
+	case "UINT", "WORD":
+		_, ok = v.(uint16)
+	case "DINT":
+		_, ok = v.(int32)
+	case "UDINT", "DWORD":
+		_, ok = v.(uint32)
+	case "LINT":
+		_, ok = v.(int64)
+	case "ULINT", "LWORD":
+		_, ok = v.(uint64)
+	case "REAL":
+		_, ok = v.(float32)
+	case "LREAL":
+		_, ok = v.(float64)
+	default:
+		// strings, BOOL arrays and the hex strings of unknown types
+		switch v.(type) {
+		case string, []bool:
+			ok = true
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("invalid %s value: %T", dataType, v)
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s value: %w", dataType, err)
+	}
+	return b, nil
+}
+
+func structToJSON(fields []StructField) (json.RawMessage, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, f := range fields {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		name, err := json.Marshal(f.Name)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(name)
+		b.WriteByte(':')
+
+		var value json.RawMessage
+		switch {
+		case len(f.Fields) > 0:
+			value, err = structToJSON(f.Fields)
+		case f.Value == nil:
+			value = json.RawMessage("null")
+		default:
+			value, err = ValueToJSON(f.Value, f.DataType)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", f.Name, err)
+		}
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// ValueFromJSON encodes a JSON value to ADS bytes of the data type.
+// It returns an error if the JSON type does not match the data type
+// or if a number is out of range.
+func ValueFromJSON(raw json.RawMessage, dataType string) ([]byte, error) {
+	d := json.NewDecoder(bytes.NewReader(raw))
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return nil, fmt.Errorf("invalid JSON value: %w", err)
+	}
+
+	switch x := v.(type) {
+	case bool:
+		if dataType != "BOOL" {
+			return nil, fmt.Errorf("invalid %s value: %s", dataType, raw)
+		}
+		return EncodeValue(strconv.FormatBool(x), dataType, 1)
+
+	case json.Number:
+		s := x.String()
+		if t, ok := intBits[dataType]; ok {
+			var err error
+			if t.signed {
+				_, err = strconv.ParseInt(s, 10, t.bits)
+			} else {
+				_, err = strconv.ParseUint(s, 10, t.bits)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s value: %s", dataType, s)
+			}
+			return EncodeValue(s, dataType, 0)
+		}
+		switch dataType {
+		case "REAL":
+			if _, err := strconv.ParseFloat(s, 32); err != nil {
+				return nil, fmt.Errorf("invalid %s value: %s", dataType, s)
+			}
+			return EncodeValue(s, dataType, 4)
+		case "LREAL":
+			if _, err := strconv.ParseFloat(s, 64); err != nil {
+				return nil, fmt.Errorf("invalid %s value: %s", dataType, s)
+			}
+			return EncodeValue(s, dataType, 8)
+		}
+
+	case string:
+		if strings.HasPrefix(dataType, "STRING") {
+			size, err := stringSize(dataType)
+			if err != nil {
+				return nil, err
+			}
+			if len(x) >= int(size) {
+				return nil, fmt.Errorf("string too long for %s: %d bytes", dataType, len(x))
+			}
+			return EncodeValue(x, dataType, size)
+		}
+	}
+
+	return nil, fmt.Errorf("invalid %s value: %s", dataType, raw)
+}
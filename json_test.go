@@ -0,0 +1,87 @@
+package goads
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/pascaldekloe/goe/verify"
+)
+
+func TestValueJSON(t *testing.T) {
+	tests := []struct {
+		dataType string
+		value    interface{}
+		json     string
+		data     []byte
+	}{
+		{"BOOL", true, `true`, []byte{1}},
+		{"SINT", int8(-2), `-2`, []byte{0xfe}},
+		{"BYTE", uint8(200), `200`, []byte{200}},
+		{"INT", int16(-300), `-300`, []byte{0xd4, 0xfe}},
+		{"UDINT", uint32(70000), `70000`, []byte{0x70, 0x11, 0x01, 0x00}},
+		{"ULINT", uint64(1 << 63), `9223372036854775808`, []byte{0, 0, 0, 0, 0, 0, 0, 0x80}},
+		{"LREAL", float64(1.5), `1.5`, []byte{0, 0, 0, 0, 0, 0, 0xf8, 0x3f}},
+		{"STRING(3)", "ab", `"ab"`, []byte{'a', 'b', 0, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dataType, func(t *testing.T) {
+			raw, err := ValueToJSON(tt.value, tt.dataType)
+			if err != nil {
+				t.Fatal(err)
+			}
+			verify.Values(t, "json", string(raw), tt.json)
+
+			data, err := ValueFromJSON(raw, tt.dataType)
+			if err != nil {
+				t.Fatal(err)
+			}
+			verify.Values(t, "data", data, tt.data)
+			verify.Values(t, "decoded", DecodeFieldValue(data, tt.dataType), tt.value)
+		})
+	}
+}
+
+func TestValueJSONErrors(t *testing.T) {
+	tests := []struct {
+		dataType string
+		json     string
+	}{
+		{"BOOL", `1`},
+		{"INT", `true`},
+		{"INT", `32768`},
+		{"UINT", `-1`},
+		{"DINT", `1.5`},
+		{"REAL", `"1"`},
+		{"STRING(3)", `"abcd"`},
+		{"STRING(3)", `3`},
+		{"ST_Pos", `{}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dataType+" "+tt.json, func(t *testing.T) {
+			if _, err := ValueFromJSON(json.RawMessage(tt.json), tt.dataType); err == nil {
+				t.Fatal("want error")
+			}
+		})
+	}
+
+	if _, err := ValueToJSON(int32(1), "INT"); err == nil {
+		t.Fatal("want error for type mismatch")
+	}
+}
+
+func TestStructToJSON(t *testing.T) {
+	fields := []StructField{
+		{Name: "bEnable", DataType: "BOOL", Value: true},
+		{Name: "stPos", DataType: "ST_Pos", Fields: []StructField{
+			{Name: "x", DataType: "BYTE", Value: uint8(3)},
+			{Name: "y", DataType: "BYTE"},
+		}},
+	}
+	raw, err := ValueToJSON(fields, "ST_Test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "", string(raw), `{"bEnable":true,"stPos":{"x":3,"y":null}}`)
+}
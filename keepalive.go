@@ -0,0 +1,71 @@
+package goads
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mrpasztoradam/goads/ams"
+)
+
+// EnableKeepalive periodically sends a ReadState request to the target
+// to keep idle connections open across firewalls and NAT timeouts.
+// The AMS addresses are required since the client does not know them.
+//
+// If a request fails then OnDisconnect is called once until a request
+// succeeds again. The keepalive stops when Close or DisableKeepalive
+// is called.
+func (c *Client) EnableKeepalive(targetAddr, senderAddr ams.Addr, interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("invalid keepalive interval: %s", interval)
+	}
+
+	stop := make(chan struct{})
+	c.mu.Lock()
+	if c.keepaliveStop != nil {
+		close(c.keepaliveStop)
+	}
+	c.keepaliveStop = stop
+	c.mu.Unlock()
+
+	go c.keepalive(targetAddr, senderAddr, interval, stop)
+	return nil
+}
+
+// DisableKeepalive stops the keepalive.
+func (c *Client) DisableKeepalive() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.keepaliveStop != nil {
+		close(c.keepaliveStop)
+		c.keepaliveStop = nil
+	}
+}
+
+func (c *Client) keepalive(targetAddr, senderAddr ams.Addr, interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	healthy := true
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		_, err := c.ReadState(ctx, ams.NewReadStateRequest(targetAddr, senderAddr))
+		cancel()
+
+		switch {
+		case err == nil:
+			healthy = true
+		case healthy:
+			healthy = false
+			if c.OnDisconnect != nil {
+				c.OnDisconnect(fmt.Errorf("keepalive failed: %w", err))
+			}
+		}
+	}
+}
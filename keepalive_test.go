@@ -0,0 +1,47 @@
+package goads
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mrpasztoradam/goads/ams"
+	"github.com/mrpasztoradam/goads/goadstest"
+)
+
+func TestKeepalive(t *testing.T) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+
+	// the server stops answering ReadState requests
+	srv.Handle(ams.CmdADSReadState, func(req goadstest.Packet) goadstest.Packet {
+		return nil
+	})
+
+	disconnected := make(chan error, 1)
+	c := &Client{
+		Addr:        srv.Addr(),
+		ReadTimeout: 10 * time.Millisecond,
+		OnDisconnect: func(err error) {
+			disconnected <- err
+		},
+	}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	sender := ams.MustParseAddr("10.0.0.1.1.1:32000")
+	if err := c.EnableKeepalive(srv.AMSAddr(), sender, 20*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-disconnected:
+		if err == nil {
+			t.Fatal("want error")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("OnDisconnect not called")
+	}
+}
@@ -0,0 +1,137 @@
+package goads
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Pack modes of structs. TwinCAT 2 packs struct members without gaps
+// and TwinCAT 3 aligns them to their natural alignment of up to 8 bytes
+// unless the type has a pack_mode attribute.
+const (
+	PackTwinCAT2 = 1
+	PackTwinCAT3 = 8
+)
+
+// AlignFields returns a copy of the fields with the offsets at which the
+// PLC places them with the given pack mode, and the size of the struct
+// including the padding at the end. Only the data types and sizes of the
+// fields are used. Use it to build a layout for SetTypeLayout. Offsets
+// reported by the PLC always take precedence over computed ones.
+func (s *Session) AlignFields(ctx context.Context, fields []StructField, pack uint32) ([]StructField, uint32, error) {
+	if pack == 0 || pack&(pack-1) != 0 || pack > 8 {
+		return nil, 0, fmt.Errorf("invalid pack mode %d", pack)
+	}
+
+	aligned := append([]StructField(nil), fields...)
+	offset, structAlign := uint32(0), uint32(1)
+	for i, f := range aligned {
+		a, err := s.alignment(ctx, f.DataType, f.Size, 0)
+		if err != nil {
+			return nil, 0, err
+		}
+		if a > pack {
+			a = pack
+		}
+		if a > structAlign {
+			structAlign = a
+		}
+		offset = alignUp(offset, a)
+		aligned[i].Offset = offset
+		offset += f.Size
+	}
+	return aligned, alignUp(offset, structAlign), nil
+}
+
+// CheckLayout compares the offsets and sizes of the fields with the
+// layout of the type reported by the PLC. It returns a descriptive error
+// for the first field which differs, e.g. because of a wrong pack mode,
+// so that no data is written to the wrong offset. If the PLC does not
+// support the data type upload the error is ErrDataTypeInfoUnsupported.
+func (s *Session) CheckLayout(ctx context.Context, typeName string, fields []StructField) error {
+	var plc []StructField
+	if info, ok := s.types.Get(typeName); ok {
+		plc = info.Fields
+	} else {
+		var err error
+		plc, err = s.client.GetDataTypeInfo(ctx, s.targetAddr, s.senderAddr, typeName)
+		if err != nil {
+			return err
+		}
+	}
+
+	byName := make(map[string]StructField, len(plc))
+	for _, f := range plc {
+		byName[f.Name] = f
+	}
+	for _, f := range fields {
+		p, ok := byName[f.Name]
+		switch {
+		case !ok:
+			return fmt.Errorf("%s has no field %s", typeName, f.Name)
+		case f.Offset != p.Offset:
+			return fmt.Errorf("field %s of %s at offset %d but the PLC has offset %d", f.Name, typeName, f.Offset, p.Offset)
+		case f.Size != p.Size:
+			return fmt.Errorf("field %s of %s has size %d but the PLC has size %d", f.Name, typeName, f.Size, p.Size)
+		}
+	}
+	return nil
+}
+
+// alignment returns the natural alignment of a data type of size bytes,
+// i.e. the alignment with pack mode 8.
+func (s *Session) alignment(ctx context.Context, dataType string, size uint32, depth int) (uint32, error) {
+	if depth > maxDescribeDepth {
+		return 0, fmt.Errorf("type %s nested too deep", dataType)
+	}
+	switch {
+	case strings.HasPrefix(dataType, "WSTRING"):
+		return 2, nil
+	case strings.HasPrefix(dataType, "STRING"):
+		return 1, nil
+	case IsArrayType(dataType):
+		arr, err := ParseArrayType(dataType)
+		if err != nil {
+			return 0, err
+		}
+		elemSize, err := arr.elementSize(size)
+		if err != nil {
+			return 0, err
+		}
+		return s.alignment(ctx, arr.ElementType, elemSize, depth+1)
+	case !IsPrimitiveType(dataType) && !strings.HasPrefix(dataType, "POINTER TO") && !strings.HasPrefix(dataType, "REFERENCE TO"):
+		fields, err := s.resolveType(ctx, dataType)
+		if err != nil && !errors.Is(err, ErrDataTypeInfoUnsupported) {
+			return 0, fmt.Errorf("failed to resolve %s: %w", dataType, err)
+		}
+		// enums and aliases have no fields and align like their size
+		if len(fields) > 0 {
+			a := uint32(1)
+			for _, f := range fields {
+				fa, err := s.alignment(ctx, f.DataType, f.Size, depth+1)
+				if err != nil {
+					return 0, err
+				}
+				if fa > a {
+					a = fa
+				}
+			}
+			return a, nil
+		}
+	}
+
+	// scalars align to the largest power of two up to 8 dividing the size
+	for _, a := range []uint32{8, 4, 2} {
+		if size > 0 && size%a == 0 {
+			return a, nil
+		}
+	}
+	return 1, nil
+}
+
+// alignUp rounds offset up to a multiple of align
+func alignUp(offset, align uint32) uint32 {
+	return (offset + align - 1) / align * align
+}
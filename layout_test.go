@@ -0,0 +1,97 @@
+package goads
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/mrpasztoradam/goads/ams"
+	"github.com/pascaldekloe/goe/verify"
+)
+
+func TestAlignFields(t *testing.T) {
+	s := (&Client{}).NewSession(ams.MustParseAddr("127.0.0.1.1.1:851"), ams.MustParseAddr("10.0.0.1.1.1:32000"))
+	s.types.Set("ST_Inner", &DataTypeInfo{Name: "ST_Inner", Fields: []StructField{
+		{Name: "b", DataType: "BYTE", Offset: 0, Size: 1},
+		{Name: "l", DataType: "LREAL", Offset: 8, Size: 8},
+	}})
+
+	fields := []StructField{
+		{Name: "bEnable", DataType: "BOOL", Size: 1},
+		{Name: "nValue", DataType: "DINT", Size: 4},
+		{Name: "sName", DataType: "STRING(4)", Size: 5},
+		{Name: "aWords", DataType: "ARRAY [0..1] OF WORD", Size: 4},
+		{Name: "stInner", DataType: "ST_Inner", Size: 16},
+	}
+
+	tests := []struct {
+		pack    uint32
+		offsets []uint32
+		size    uint32
+	}{
+		{PackTwinCAT2, []uint32{0, 1, 5, 10, 14}, 30},
+		{2, []uint32{0, 2, 6, 12, 16}, 32},
+		{PackTwinCAT3, []uint32{0, 4, 8, 14, 24}, 40},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("pack %d", tt.pack), func(t *testing.T) {
+			aligned, size, err := s.AlignFields(context.Background(), fields, tt.pack)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var offsets []uint32
+			for _, f := range aligned {
+				offsets = append(offsets, f.Offset)
+			}
+			verify.Values(t, "offsets", offsets, tt.offsets)
+			verify.Values(t, "size", size, tt.size)
+		})
+	}
+
+	if _, _, err := s.AlignFields(context.Background(), fields, 3); err == nil {
+		t.Fatal("want error for invalid pack mode")
+	}
+	for _, dataType := range []string{"ARRAY [0..9] OF WORD", "ARRAY [0..2147483647, 0..2147483647, 0..1] OF WORD"} {
+		invalid := []StructField{{Name: "aWords", DataType: dataType, Size: 4}}
+		if _, _, err := s.AlignFields(context.Background(), invalid, PackTwinCAT3); err == nil {
+			t.Errorf("want error for %s of 4 bytes", dataType)
+		}
+	}
+}
+
+func TestCheckLayout(t *testing.T) {
+	s := (&Client{}).NewSession(ams.MustParseAddr("127.0.0.1.1.1:851"), ams.MustParseAddr("10.0.0.1.1.1:32000"))
+	// the PLC pads the BOOL to align the DINT
+	s.types.Set("ST_Padded", &DataTypeInfo{Name: "ST_Padded", Fields: []StructField{
+		{Name: "bEnable", DataType: "BOOL", Offset: 0, Size: 1},
+		{Name: "nValue", DataType: "DINT", Offset: 4, Size: 4},
+	}})
+	fields := []StructField{
+		{Name: "bEnable", DataType: "BOOL", Size: 1},
+		{Name: "nValue", DataType: "DINT", Size: 4},
+	}
+
+	tests := []struct {
+		pack    uint32
+		wantErr bool
+	}{
+		{PackTwinCAT2, true},
+		{PackTwinCAT3, false},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("pack %d", tt.pack), func(t *testing.T) {
+			aligned, _, err := s.AlignFields(context.Background(), fields, tt.pack)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = s.CheckLayout(context.Background(), "ST_Padded", aligned)
+			if got, want := err != nil, tt.wantErr; got != want {
+				t.Fatalf("got error %v want error %v", err, want)
+			}
+		})
+	}
+
+	if err := s.CheckLayout(context.Background(), "ST_Padded", []StructField{{Name: "nOther", Size: 4}}); err == nil {
+		t.Fatal("want error for unknown field")
+	}
+}
@@ -0,0 +1,125 @@
+package goads
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// The 64-bit time types of TwinCAT are unsigned nanoseconds. LTIME is a
+// duration, LDT and LDATE are nanoseconds since 1970-01-01 and LTOD is
+// nanoseconds since midnight. Values above math.MaxInt64, i.e. after the
+// year 2262, cannot be represented by time.Duration and time.Time.
+
+// DecodeLTIME decodes an LTIME value. It returns an error if the value
+// exceeds the range of time.Duration.
+func DecodeLTIME(data []byte) (time.Duration, error) {
+	ns, err := decodeNanoseconds(data, "LTIME")
+	return time.Duration(ns), err
+}
+
+// EncodeLTIME encodes an LTIME value. Negative durations are an error.
+func EncodeLTIME(d time.Duration) ([]byte, error) {
+	if d < 0 {
+		return nil, fmt.Errorf("negative LTIME: %s", d)
+	}
+	data := make([]byte, 8)
+	ByteOrder.PutUint64(data, uint64(d))
+	return data, nil
+}
+
+// DecodeLDT decodes an LDT, LDATE or LTOD value to a time in UTC. LTOD
+// values are returned as the time of day on 1970-01-01. It returns an
+// error if the value exceeds the range of time.Time.UnixNano.
+func DecodeLDT(data []byte) (time.Time, error) {
+	ns, err := decodeNanoseconds(data, "LDT")
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, ns).UTC(), nil
+}
+
+// EncodeLDT encodes an LDT or LDATE value. Times before 1970 and after
+// 2262 are an error.
+func EncodeLDT(t time.Time) ([]byte, error) {
+	if t.Before(time.Unix(0, 0)) || t.After(time.Unix(0, math.MaxInt64)) {
+		return nil, fmt.Errorf("LDT out of range: %s", t)
+	}
+	data := make([]byte, 8)
+	ByteOrder.PutUint64(data, uint64(t.UnixNano()))
+	return data, nil
+}
+
+// EncodeLTOD encodes the time of day of t as LTOD value.
+func EncodeLTOD(t time.Time) []byte {
+	h, m, s := t.Clock()
+	d := time.Duration(h)*time.Hour + time.Duration(m)*time.Minute +
+		time.Duration(s)*time.Second + time.Duration(t.Nanosecond())
+	data := make([]byte, 8)
+	ByteOrder.PutUint64(data, uint64(d))
+	return data
+}
+
+// decodeNanoseconds decodes an unsigned 64-bit nanosecond value
+func decodeNanoseconds(data []byte, dataType string) (int64, error) {
+	if len(data) < 8 {
+		return 0, fmt.Errorf("insufficient data for %s: got %d bytes, want 8", dataType, len(data))
+	}
+	ns := ByteOrder.Uint64(data)
+	if ns > math.MaxInt64 {
+		return 0, fmt.Errorf("%s value %d ns out of range", dataType, ns)
+	}
+	return int64(ns), nil
+}
+
+// decodeLTime decodes the 64-bit time types. Values out of range are
+// capped at the maximum if capped is true and an error otherwise.
+func decodeLTime(data []byte, dataType string, capped bool) (interface{}, error) {
+	ns, err := decodeNanoseconds(data, dataType)
+	if err != nil {
+		if !capped || len(data) < 8 {
+			return nil, err
+		}
+		ns = math.MaxInt64
+	}
+	if dataType == "LTIME" {
+		return time.Duration(ns), nil
+	}
+	return time.Unix(0, ns).UTC(), nil
+}
+
+// encodeLTime encodes the string value of a 64-bit time type. LTIME
+// takes a Go duration like 1h2m3.5s, LDT an RFC 3339 time, LDATE also
+// a date like 2006-01-02 and LTOD a time of day like 15:04:05.123.
+// IEC prefixes like LTIME# are accepted.
+func encodeLTime(value string, dataType string) ([]byte, error) {
+	value = strings.TrimSpace(value)
+	if i := strings.Index(value, "#"); i >= 0 {
+		value = value[i+1:]
+	}
+
+	switch dataType {
+	case "LTIME":
+		d, err := time.ParseDuration(strings.ToLower(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid LTIME value: %w", err)
+		}
+		return EncodeLTIME(d)
+	case "LTOD":
+		t, err := time.Parse("15:04:05.999999999", value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid LTOD value: %w", err)
+		}
+		return EncodeLTOD(t), nil
+	default:
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if dataType == "LDATE" && err != nil {
+			t, err = time.Parse("2006-01-02", value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s value: %w", dataType, err)
+		}
+		return EncodeLDT(t)
+	}
+}
@@ -0,0 +1,71 @@
+package goads
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/pascaldekloe/goe/verify"
+)
+
+// ltimeBytes encodes nanoseconds as 64-bit PLC value
+func ltimeBytes(n uint64) []byte {
+	b := make([]byte, 8)
+	ByteOrder.PutUint64(b, n)
+	return b
+}
+
+func TestLTimeTypes(t *testing.T) {
+	day := time.Date(2024, 3, 1, 12, 30, 15, 500, time.UTC)
+
+	tests := []struct {
+		dataType string
+		value    string
+		data     []byte
+		want     interface{}
+	}{
+		{"LTIME", "LTIME#1h2m3s4ms5us6ns", ltimeBytes(uint64(time.Hour + 2*time.Minute + 3*time.Second + 4*time.Millisecond + 5*time.Microsecond + 6)), time.Hour + 2*time.Minute + 3*time.Second + 4*time.Millisecond + 5*time.Microsecond + 6},
+		{"LTIME", "1.5s", ltimeBytes(1500000000), 1500 * time.Millisecond},
+		{"LDT", "2024-03-01T12:30:15.0000005Z", ltimeBytes(uint64(day.UnixNano())), day},
+		{"LDATE", "2024-03-01", ltimeBytes(uint64(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC).UnixNano())), time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{"LTOD", "LTOD#12:30:15.5", ltimeBytes(uint64(12*time.Hour + 30*time.Minute + 15500*time.Millisecond)), time.Date(1970, 1, 1, 12, 30, 15, 500000000, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dataType+" "+tt.value, func(t *testing.T) {
+			data, err := EncodeValue(tt.value, tt.dataType, 8)
+			if err != nil {
+				t.Fatal(err)
+			}
+			verify.Values(t, "encoded", data, tt.data)
+
+			got, err := DecodeFieldValueStrict(data, tt.dataType)
+			if err != nil {
+				t.Fatal(err)
+			}
+			verify.Values(t, "decoded", got, tt.want)
+		})
+	}
+}
+
+func TestLTimeOverflow(t *testing.T) {
+	data := ltimeBytes(math.MaxInt64 + 1)
+
+	if _, err := DecodeLTIME(data); err == nil {
+		t.Error("DecodeLTIME: want error for value out of range")
+	}
+	if _, err := DecodeFieldValueStrict(data, "LDT"); err == nil {
+		t.Error("DecodeFieldValueStrict: want error for value out of range")
+	}
+	verify.Values(t, "capped LTIME", DecodeFieldValue(data, "LTIME"), time.Duration(math.MaxInt64))
+	verify.Values(t, "capped LDT", DecodeFieldValue(data, "LDT"), time.Unix(0, math.MaxInt64).UTC())
+
+	if _, err := EncodeLTIME(-time.Second); err == nil {
+		t.Error("EncodeLTIME: want error for negative duration")
+	}
+	if _, err := EncodeLDT(time.Date(1969, 12, 31, 0, 0, 0, 0, time.UTC)); err == nil {
+		t.Error("EncodeLDT: want error before 1970")
+	}
+	if _, err := EncodeValue("2300-01-01T00:00:00Z", "LDT", 8); err == nil {
+		t.Error("EncodeValue: want error after 2262")
+	}
+}
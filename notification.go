@@ -1,6 +1,7 @@
 package goads
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"sync"
@@ -42,9 +43,11 @@ type NotificationAttribs struct {
 
 // NotificationSample contains a notification data sample
 type NotificationSample struct {
-	Handle    uint32    // Notification handle
-	Timestamp time.Time // Timestamp of notification
-	Data      []byte    // Notification data
+	Handle    uint32        // Notification handle
+	Timestamp time.Time     // Timestamp of notification
+	Data      []byte        // Notification data
+	Value     interface{}   // Data decoded by the data type of the symbol
+	Fields    []StructField // Populated fields of structs, see SetDecodeStructs
 }
 
 // NotificationCallback is called when a notification is received
@@ -57,6 +60,7 @@ type notificationHandler struct {
 	varHandle  uint32 // ADS variable handle
 	callback   NotificationCallback
 	symbolInfo *SymbolInfo
+	fields     []StructField // resolved fields of structs without values
 }
 
 // NotificationManager manages ADS device notifications
@@ -64,8 +68,11 @@ type NotificationManager struct {
 	session  *Session
 	handlers map[uint32]*notificationHandler
 	mu       sync.RWMutex
-	stopCh   chan struct{}
 	running  bool
+	stopped  bool           // no callbacks after Stop
+	inflight sync.WaitGroup // running callbacks
+
+	decodeStructs bool
 }
 
 // NewNotificationManager creates a new notification manager for a session
@@ -73,43 +80,187 @@ func (s *Session) NewNotificationManager() *NotificationManager {
 	return &NotificationManager{
 		session:  s,
 		handlers: make(map[uint32]*notificationHandler),
-		stopCh:   make(chan struct{}),
 	}
 }
 
+// SetDecodeStructs enables populating NotificationSample.Fields for
+// variables of struct types. The types are resolved when subscribing,
+// so it only applies to later subscriptions. Fields stays nil if the
+// type cannot be resolved.
+func (nm *NotificationManager) SetDecodeStructs(enabled bool) {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+	nm.decodeStructs = enabled
+}
+
 // Subscribe creates a notification subscription for a variable
+// which is sent on change and checked every cycleTime
 func (nm *NotificationManager) Subscribe(
 	ctx context.Context,
 	varName string,
 	cycleTime time.Duration,
 	callback NotificationCallback,
 ) (uint32, error) {
+	attribs := NotificationAttribs{
+		TransMode: TransModeServerOnChange,
+		MaxDelay:  uint32(cycleTime.Nanoseconds() / 100), // Convert to 100ns units
+		CycleTime: uint32(cycleTime.Nanoseconds() / 100),
+	}
+	return nm.SubscribeWithOptions(ctx, varName, attribs, callback)
+}
+
+// SubscribeWithOptions creates a notification subscription for a variable
+// with the given transmission mode, maximum delay and cycle time.
+// If attribs.Length is zero the size of the symbol is used.
+func (nm *NotificationManager) SubscribeWithOptions(
+	ctx context.Context,
+	varName string,
+	attribs NotificationAttribs,
+	callback NotificationCallback,
+) (uint32, error) {
+	if err := attribs.validate(); err != nil {
+		return 0, err
+	}
+	sub, err := nm.prepare(ctx, varName, attribs, callback)
+	if err != nil {
+		return 0, err
+	}
+
+	notificationHandle, err := nm.addDeviceNotification(ctx, sub.indexGroup, sub.indexOffset, sub.attribs)
+	if err != nil {
+		return 0, err
+	}
+	nm.store(notificationHandle, sub.handler)
+	return notificationHandle, nil
+}
+
+// subscription is a subscription of a variable which is ready
+// to be added
+type subscription struct {
+	indexGroup  uint32
+	indexOffset uint32
+	attribs     NotificationAttribs
+	handler     *notificationHandler
+}
+
+// prepare looks up the symbol of the variable and its address.
+func (nm *NotificationManager) prepare(
+	ctx context.Context,
+	varName string,
+	attribs NotificationAttribs,
+	callback NotificationCallback,
+) (subscription, error) {
+	// Get symbol info for data length
+	symbolInfo, err := nm.session.GetSymbol(ctx, varName)
+	if err != nil {
+		return subscription{}, fmt.Errorf("failed to get symbol info for %s: %w", varName, err)
+	}
+
 	// Get or create variable handle
 	handle, err := nm.session.getOrCreateHandle(ctx, varName)
 	if err != nil {
-		return 0, fmt.Errorf("failed to get handle for %s: %w", varName, err)
+		return subscription{}, fmt.Errorf("failed to get handle for %s: %w", varName, err)
 	}
 
-	// Get symbol info for data length
-	symbolInfo, ok := nm.session.registry.Get(varName)
-	if !ok {
-		return 0, fmt.Errorf("symbol %s not found in registry", varName)
+	if attribs.Length == 0 {
+		attribs.Length = symbolInfo.Size
 	}
 
-	// Create notification attributes
-	attribs := NotificationAttribs{
-		Length:    symbolInfo.Size,
-		TransMode: TransModeServerOnChange,
-		MaxDelay:  uint32(cycleTime.Nanoseconds() / 100), // Convert to 100ns units
-		CycleTime: uint32(cycleTime.Nanoseconds() / 100),
+	// Address the variable by handle unless the symbol table
+	// provided its index group and offset
+	indexGroup, indexOffset := uint32(0xF005), handle // ADSIGRP_SYM_VALBYHND
+	if symbolInfo.IndexGroup != 0 {
+		indexGroup, indexOffset = symbolInfo.IndexGroup, symbolInfo.IndexOffset
+	}
+
+	// Resolve the struct type now since the receive loop which
+	// dispatches the samples cannot wait for requests
+	nm.mu.RLock()
+	decodeStructs := nm.decodeStructs
+	nm.mu.RUnlock()
+	var fields []StructField
+	if decodeStructs && !IsPrimitiveType(symbolInfo.DataType) {
+		fields, _ = nm.session.decodeStruct(ctx, symbolInfo.DataType, make([]byte, symbolInfo.Size))
+	}
+
+	return subscription{
+		indexGroup:  indexGroup,
+		indexOffset: indexOffset,
+		attribs:     attribs,
+		handler: &notificationHandler{
+			varName:    varName,
+			varHandle:  handle,
+			callback:   callback,
+			symbolInfo: symbolInfo,
+			fields:     fields,
+		},
+	}, nil
+}
+
+// store registers the handler for the notification handle
+func (nm *NotificationManager) store(notificationHandle uint32, handler *notificationHandler) {
+	handler.handle = notificationHandle
+	nm.mu.Lock()
+	nm.handlers[notificationHandle] = handler
+	nm.mu.Unlock()
+}
+
+// SubscribeRaw creates a notification subscription for length bytes at
+// the given index group and offset, e.g. for process image or %MB memory
+// which has no symbol. The callback receives the raw data only.
+func (nm *NotificationManager) SubscribeRaw(
+	ctx context.Context,
+	indexGroup, indexOffset, length uint32,
+	attribs NotificationAttribs,
+	callback NotificationCallback,
+) (uint32, error) {
+	if err := attribs.validate(); err != nil {
+		return 0, err
+	}
+	if length == 0 {
+		return 0, fmt.Errorf("invalid notification length: 0")
+	}
+	attribs.Length = length
+
+	notificationHandle, err := nm.addDeviceNotification(ctx, indexGroup, indexOffset, attribs)
+	if err != nil {
+		return 0, err
+	}
+
+	// Store handler
+	nm.mu.Lock()
+	nm.handlers[notificationHandle] = &notificationHandler{
+		handle:   notificationHandle,
+		callback: callback,
 	}
+	nm.mu.Unlock()
+
+	return notificationHandle, nil
+}
 
-	// Create AddDeviceNotification request
+// validate checks that the attributes describe a valid subscription
+func (a NotificationAttribs) validate() error {
+	switch a.TransMode {
+	case TransModeServerOnChange:
+		return nil
+	case TransModeServerCycle, TransModeCyclic:
+		if a.CycleTime == 0 {
+			return fmt.Errorf("cyclic notification requires a cycle time")
+		}
+		return nil
+	default:
+		return fmt.Errorf("invalid notification transmission mode: %d", a.TransMode)
+	}
+}
+
+// addDeviceNotification sends an AddDeviceNotification request
+// and returns the notification handle
+func (nm *NotificationManager) addDeviceNotification(ctx context.Context, indexGroup, indexOffset uint32, attribs NotificationAttribs) (uint32, error) {
 	req := ams.NewAddDeviceNotificationRequest(
 		nm.session.targetAddr,
 		nm.session.senderAddr,
-		symbolInfo.IndexGroup,
-		symbolInfo.IndexOffset,
+		indexGroup,
+		indexOffset,
 		attribs.Length,
 		uint32(attribs.TransMode),
 		attribs.MaxDelay,
@@ -126,20 +277,7 @@ func (nm *NotificationManager) Subscribe(
 		return 0, fmt.Errorf("add notification error: %d", resp.Result)
 	}
 
-	notificationHandle := resp.NotificationHandle
-
-	// Store handler
-	nm.mu.Lock()
-	nm.handlers[notificationHandle] = &notificationHandler{
-		handle:     notificationHandle,
-		varName:    varName,
-		varHandle:  handle,
-		callback:   callback,
-		symbolInfo: symbolInfo,
-	}
-	nm.mu.Unlock()
-
-	return notificationHandle, nil
+	return resp.NotificationHandle, nil
 }
 
 // Unsubscribe removes a notification subscription
@@ -153,7 +291,19 @@ func (nm *NotificationManager) Unsubscribe(ctx context.Context, notificationHand
 	delete(nm.handlers, notificationHandle)
 	nm.mu.Unlock()
 
-	// Create DeleteDeviceNotification request
+	if err := nm.deleteDeviceNotification(ctx, notificationHandle); err != nil {
+		return err
+	}
+
+	// Release the variable handle if no longer needed
+	// Note: In practice, you may want to keep handles cached
+	_ = handler.varHandle
+
+	return nil
+}
+
+// deleteDeviceNotification sends a DeleteDeviceNotification request
+func (nm *NotificationManager) deleteDeviceNotification(ctx context.Context, notificationHandle uint32) error {
 	req := ams.NewDeleteDeviceNotificationRequest(
 		nm.session.targetAddr,
 		nm.session.senderAddr,
@@ -169,15 +319,12 @@ func (nm *NotificationManager) Unsubscribe(ctx context.Context, notificationHand
 	if resp.Result != ams.NoError {
 		return fmt.Errorf("delete notification error: %d", resp.Result)
 	}
-
-	// Release the variable handle if no longer needed
-	// Note: In practice, you may want to keep handles cached
-	_ = handler.varHandle
-
 	return nil
 }
 
-// Start begins processing notifications
+// Start begins processing notifications. It registers the manager
+// with the client which routes the samples of the notification handles
+// of the manager to it.
 func (nm *NotificationManager) Start() error {
 	nm.mu.Lock()
 	if nm.running {
@@ -185,63 +332,136 @@ func (nm *NotificationManager) Start() error {
 		return fmt.Errorf("notification manager already running")
 	}
 	nm.running = true
-	nm.stopCh = make(chan struct{})
+	nm.stopped = false
 	nm.mu.Unlock()
 
-	// Start goroutine to process notifications
-	go nm.processNotifications()
-
+	nm.session.client.AddNotificationSink(nm)
 	return nil
 }
 
-// Stop stops processing notifications
+// Stop stops processing notifications. Callbacks which are already
+// running are not waited for, use Shutdown for that.
 func (nm *NotificationManager) Stop() {
 	nm.mu.Lock()
+	nm.stopped = true
 	if !nm.running {
 		nm.mu.Unlock()
 		return
 	}
 	nm.running = false
-	close(nm.stopCh)
 	nm.mu.Unlock()
+
+	nm.session.client.RemoveNotificationSink(nm)
+}
+
+// Detach unregisters the manager from the client so that it does not
+// receive notifications anymore. Unlike Shutdown it leaves the
+// subscriptions on the PLC. It is the same as Stop.
+func (nm *NotificationManager) Detach() {
+	nm.Stop()
 }
 
-// processNotifications processes incoming notification packets
-func (nm *NotificationManager) processNotifications() {
-	// Set up the client callback to receive notifications
-	nm.session.client.SetNotificationCallback(func(req *ams.DeviceNotificationRequest) {
-		// Process each stamp in the notification
-		for _, stamp := range req.Stamps {
-			// Convert Windows FILETIME to Go time
-			// FILETIME is 100-nanosecond intervals since January 1, 1601
-			const ticksPerSecond = 10000000
-			const epochDiff = 11644473600 // Seconds between 1601 and 1970
-			secs := int64(stamp.Timestamp)/ticksPerSecond - epochDiff
-			nsecs := (int64(stamp.Timestamp) % ticksPerSecond) * 100
-			timestamp := time.Unix(secs, nsecs)
-
-			// Process each sample in the stamp
-			for _, sample := range stamp.Samples {
-				nm.mu.RLock()
-				handler, ok := nm.handlers[sample.Handle]
-				nm.mu.RUnlock()
-
-				if ok && handler.callback != nil {
-					// Call the user's callback with the notification data
-					handler.callback(NotificationSample{
-						Handle:    sample.Handle,
-						Timestamp: timestamp,
-						Data:      sample.Data,
-					})
-				}
+// shutdownUnsubscribeTimeout limits the time Shutdown takes to delete
+// the subscriptions after its context is done.
+const shutdownUnsubscribeTimeout = 5 * time.Second
+
+// Shutdown stops processing notifications, waits up to timeout for
+// running callbacks to return and then deletes all subscriptions on
+// the PLC. The subscriptions are deleted even if callbacks are still
+// running after the timeout or ctx is done and an error is returned in
+// that case. Shutdown must not be called from a callback.
+func (nm *NotificationManager) Shutdown(ctx context.Context, timeout time.Duration) error {
+	nm.Stop()
+
+	done := make(chan struct{})
+	go func() {
+		nm.inflight.Wait()
+		close(done)
+	}()
+
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+
+	var waitErr error
+	select {
+	case <-done:
+	case <-t.C:
+		waitErr = fmt.Errorf("notification callbacks still running after %s", timeout)
+	case <-ctx.Done():
+		waitErr = ctx.Err()
+	}
+
+	// a done ctx would not send the delete requests
+	if ctx.Err() != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(context.Background(), shutdownUnsubscribeTimeout)
+		defer cancel()
+	}
+	if err := nm.UnsubscribeAll(ctx); err != nil {
+		return err
+	}
+	return waitErr
+}
+
+// dispatch calls the handler callback for every sample in the notification.
+// A notification can contain multiple stamps and each stamp can contain
+// samples for multiple handles.
+func (nm *NotificationManager) dispatch(req *ams.DeviceNotificationRequest) {
+	// notification handles are only unique per ADS port, so samples of
+	// other targets on the same client are not ours
+	sender, target := req.Header().Sender, nm.session.targetAddr
+	if !bytes.Equal(sender.NetID, target.NetID) || sender.Port != target.Port {
+		return
+	}
+
+	// register the callbacks before Stop so that Shutdown waits for them
+	nm.mu.RLock()
+	if nm.stopped {
+		nm.mu.RUnlock()
+		return
+	}
+	nm.inflight.Add(1)
+	nm.mu.RUnlock()
+	defer nm.inflight.Done()
+
+	for _, stamp := range req.Stamps {
+		timestamp := stamp.Time()
+
+		for _, sample := range stamp.Samples {
+			nm.mu.RLock()
+			handler, ok := nm.handlers[sample.Handle]
+			nm.mu.RUnlock()
+
+			if !ok || handler.callback == nil {
+				continue
+			}
+
+			var value interface{}
+			if handler.symbolInfo != nil {
+				value = DecodeFieldValue(sample.Data, handler.symbolInfo.DataType)
+			}
+			var fields []StructField
+			if handler.fields != nil {
+				// the nested fields are already resolved
+				fields = append([]StructField(nil), handler.fields...)
+				populateFieldValues(context.Background(), fields, sample.Data, noResolve)
 			}
-		}
-	})
 
-	<-nm.stopCh
+			// Call the user's callback with the notification data
+			handler.callback(NotificationSample{
+				Handle:    sample.Handle,
+				Timestamp: timestamp,
+				Data:      sample.Data,
+				Value:     value,
+				Fields:    fields,
+			})
+		}
+	}
+}
 
-	// Clear the callback when stopping
-	nm.session.client.SetNotificationCallback(nil)
+// noResolve is a type resolver which resolves no types
+func noResolve(ctx context.Context, typeName string) ([]StructField, error) {
+	return nil, fmt.Errorf("unknown data type %s", typeName)
 }
 
 // UnsubscribeAll removes all notification subscriptions
@@ -253,12 +473,5 @@ func (nm *NotificationManager) UnsubscribeAll(ctx context.Context) error {
 	}
 	nm.mu.Unlock()
 
-	var lastErr error
-	for _, h := range handles {
-		if err := nm.Unsubscribe(ctx, h); err != nil {
-			lastErr = err
-		}
-	}
-
-	return lastErr
+	return nm.UnsubscribeMany(ctx, handles)
 }
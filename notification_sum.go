@@ -0,0 +1,213 @@
+package goads
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/mrpasztoradam/goads/ams"
+)
+
+// maxSumNotifications is the maximum number of notifications which
+// are added or deleted with a single sum-up request
+const maxSumNotifications = 500
+
+// SubscribeSpec describes a single subscription of SubscribeMany
+type SubscribeSpec struct {
+	VarName  string
+	Attribs  NotificationAttribs // Length zero uses the size of the symbol
+	Callback NotificationCallback
+}
+
+// SubscribeMany creates the subscriptions for many variables with ADS
+// sum-up requests instead of one request per variable. The returned
+// notification handles are in the order of the specs and zero for the
+// subscriptions which failed. It returns the first error. If the PLC
+// does not support sum-up requests the notifications are added one
+// by one.
+func (nm *NotificationManager) SubscribeMany(ctx context.Context, specs []SubscribeSpec) ([]uint32, error) {
+	var firstErr error
+	fail := func(name string, err error) {
+		if firstErr == nil {
+			firstErr = fmt.Errorf("failed to subscribe %s: %w", name, err)
+		}
+	}
+
+	// errors are reported by prepare
+	names := make([]string, len(specs))
+	for i, spec := range specs {
+		names[i] = spec.VarName
+	}
+	_ = nm.session.AcquireHandles(ctx, names)
+
+	var subs []subscription
+	var index []int // index of the spec of each subscription
+	for i, spec := range specs {
+		if err := spec.Attribs.validate(); err != nil {
+			fail(spec.VarName, err)
+			continue
+		}
+		sub, err := nm.prepare(ctx, spec.VarName, spec.Attribs, spec.Callback)
+		if err != nil {
+			fail(spec.VarName, err)
+			continue
+		}
+		subs = append(subs, sub)
+		index = append(index, i)
+	}
+
+	handles := make([]uint32, len(specs))
+	for start := 0; start < len(subs); start += maxSumNotifications {
+		end := start + maxSumNotifications
+		if end > len(subs) {
+			end = len(subs)
+		}
+		added, errs := nm.addDeviceNotifications(ctx, subs[start:end])
+		for j, sub := range subs[start:end] {
+			if errs[j] != nil {
+				fail(sub.handler.varName, errs[j])
+				continue
+			}
+			nm.store(added[j], sub.handler)
+			handles[index[start+j]] = added[j]
+		}
+	}
+	return handles, firstErr
+}
+
+// UnsubscribeMany removes many subscriptions with ADS sum-up requests
+// instead of one request per subscription. It returns the first error.
+// If the PLC does not support sum-up requests the notifications are
+// deleted one by one.
+func (nm *NotificationManager) UnsubscribeMany(ctx context.Context, notificationHandles []uint32) error {
+	var firstErr error
+	handles := make([]uint32, 0, len(notificationHandles))
+	nm.mu.Lock()
+	for _, h := range notificationHandles {
+		if _, exists := nm.handlers[h]; !exists {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("notification handle %d not found", h)
+			}
+			continue
+		}
+		delete(nm.handlers, h)
+		handles = append(handles, h)
+	}
+	nm.mu.Unlock()
+
+	for start := 0; start < len(handles); start += maxSumNotifications {
+		end := start + maxSumNotifications
+		if end > len(handles) {
+			end = len(handles)
+		}
+		for _, err := range nm.deleteDeviceNotifications(ctx, handles[start:end]) {
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// addDeviceNotifications adds the notifications with a single sum-up
+// request and falls back to individual requests if the target does not
+// support it. It returns the notification handles and the errors in the
+// order of subs.
+func (nm *NotificationManager) addDeviceNotifications(ctx context.Context, subs []subscription) ([]uint32, []error) {
+	handles := make([]uint32, len(subs))
+	errs := make([]error, len(subs))
+
+	// Format: [indexGroup][indexOffset][length][transMode]
+	// [maxDelay][cycleTime][reserved 16 bytes] * N
+	data := make([]byte, len(subs)*40)
+	for i, sub := range subs {
+		b := data[i*40:]
+		binary.LittleEndian.PutUint32(b[0:], sub.indexGroup)
+		binary.LittleEndian.PutUint32(b[4:], sub.indexOffset)
+		binary.LittleEndian.PutUint32(b[8:], sub.attribs.Length)
+		binary.LittleEndian.PutUint32(b[12:], uint32(sub.attribs.TransMode))
+		binary.LittleEndian.PutUint32(b[16:], sub.attribs.MaxDelay)
+		binary.LittleEndian.PutUint32(b[20:], sub.attribs.CycleTime)
+	}
+
+	// Format of the response: [errorCode][notificationHandle] * N
+	resp, err := nm.sumReadWrite(ctx, 0xF085, len(subs), len(subs)*8, data) // ADSIGRP_SUMUP_ADDDEVNOTE
+	if errors.Is(err, ams.ADSError(ams.DeviceServiceNotSupported)) {
+		// fall back to individual requests
+		for i, sub := range subs {
+			handles[i], errs[i] = nm.addDeviceNotification(ctx, sub.indexGroup, sub.indexOffset, sub.attribs)
+		}
+		return handles, errs
+	}
+	if err != nil {
+		for i := range subs {
+			errs[i] = fmt.Errorf("failed to add notification: %w", err)
+		}
+		return handles, errs
+	}
+	for i := range subs {
+		if code := binary.LittleEndian.Uint32(resp[i*8:]); code != ams.NoError {
+			errs[i] = fmt.Errorf("failed to add notification: %w", ams.ADSError(code))
+			continue
+		}
+		handles[i] = binary.LittleEndian.Uint32(resp[i*8+4:])
+	}
+	return handles, errs
+}
+
+// deleteDeviceNotifications deletes the notifications with a single
+// sum-up request and falls back to individual requests if the target
+// does not support it. It returns the errors in the order of the handles.
+func (nm *NotificationManager) deleteDeviceNotifications(ctx context.Context, handles []uint32) []error {
+	errs := make([]error, len(handles))
+
+	// Format: [notificationHandle] * N
+	data := make([]byte, len(handles)*4)
+	for i, h := range handles {
+		binary.LittleEndian.PutUint32(data[i*4:], h)
+	}
+
+	// Format of the response: [errorCode] * N
+	resp, err := nm.sumReadWrite(ctx, 0xF086, len(handles), len(handles)*4, data) // ADSIGRP_SUMUP_DELDEVNOTE
+	if errors.Is(err, ams.ADSError(ams.DeviceServiceNotSupported)) {
+		// fall back to individual requests
+		for i, h := range handles {
+			errs[i] = nm.deleteDeviceNotification(ctx, h)
+		}
+		return errs
+	}
+	if err != nil {
+		for i, h := range handles {
+			errs[i] = fmt.Errorf("failed to delete notification %d: %w", h, err)
+		}
+		return errs
+	}
+	for i := range handles {
+		if code := binary.LittleEndian.Uint32(resp[i*4:]); code != ams.NoError {
+			errs[i] = fmt.Errorf("failed to delete notification %d: %w", handles[i], ams.ADSError(code))
+		}
+	}
+	return errs
+}
+
+// sumReadWrite sends a sum-up request with n sub-commands to the index
+// group and returns the response data of at least readLength bytes.
+func (nm *NotificationManager) sumReadWrite(ctx context.Context, indexGroup uint32, n, readLength int, data []byte) ([]byte, error) {
+	req := ams.NewReadWriteRequest(
+		nm.session.targetAddr,
+		nm.session.senderAddr,
+		indexGroup,
+		uint32(n),
+		uint32(readLength),
+		data,
+	)
+	resp, err := nm.session.client.ReadWrite(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Data) < readLength {
+		return nil, fmt.Errorf("sum-up response too short (length: %d)", len(resp.Data))
+	}
+	return resp.Data, nil
+}
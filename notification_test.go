@@ -0,0 +1,461 @@
+package goads
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mrpasztoradam/goads/ams"
+	"github.com/mrpasztoradam/goads/goadstest"
+	"github.com/pascaldekloe/goe/verify"
+)
+
+func TestNotificationDispatch(t *testing.T) {
+	var got []NotificationSample
+	callback := func(sample NotificationSample) {
+		got = append(got, sample)
+	}
+
+	nm := &NotificationManager{
+		session: &Session{},
+		handlers: map[uint32]*notificationHandler{
+			1: {handle: 1, callback: callback, symbolInfo: &SymbolInfo{DataType: "BYTE"}},
+			2: {handle: 2, callback: callback, symbolInfo: &SymbolInfo{DataType: "DINT"}},
+			3: {handle: 3, callback: callback, symbolInfo: &SymbolInfo{DataType: "INT"}},
+		},
+	}
+
+	stamps := []ams.NotificationStamp{
+		{
+			Timestamp: 116444736000000000,
+			Samples: []ams.NotificationSample{
+				{Handle: 1, Size: 1, Data: []byte{0xaa}},
+				{Handle: 2, Size: 4, Data: []byte{0x01, 0x00, 0x00, 0x00}},
+				{Handle: 3, Size: 2, Data: []byte{0xff, 0xff}},
+			},
+		},
+		{
+			Timestamp: 116444736010000000,
+			Samples: []ams.NotificationSample{
+				{Handle: 3, Size: 2, Data: []byte{0x02, 0x00}},
+				{Handle: 4, Size: 1, Data: []byte{0x01}}, // unknown handle
+				{Handle: 1, Size: 1, Data: []byte{0xbb}},
+			},
+		},
+	}
+	nm.dispatch(&ams.DeviceNotificationRequest{Stamps: stamps})
+
+	t0, t1 := stamps[0].Time(), stamps[1].Time()
+	want := []NotificationSample{
+		{Handle: 1, Timestamp: t0, Data: []byte{0xaa}, Value: uint8(0xaa)},
+		{Handle: 2, Timestamp: t0, Data: []byte{0x01, 0x00, 0x00, 0x00}, Value: int32(1)},
+		{Handle: 3, Timestamp: t0, Data: []byte{0xff, 0xff}, Value: int16(-1)},
+		{Handle: 3, Timestamp: t1, Data: []byte{0x02, 0x00}, Value: int16(2)},
+		{Handle: 1, Timestamp: t1, Data: []byte{0xbb}, Value: uint8(0xbb)},
+	}
+	verify.Values(t, "", got, want)
+}
+
+func TestNotificationAttribsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		attribs NotificationAttribs
+		ok      bool
+	}{
+		{"on change", NotificationAttribs{TransMode: TransModeServerOnChange}, true},
+		{"cyclic", NotificationAttribs{TransMode: TransModeServerCycle, CycleTime: 10000}, true},
+		{"cyclic without cycle time", NotificationAttribs{TransMode: TransModeServerCycle}, false},
+		{"invalid mode", NotificationAttribs{TransMode: 99, CycleTime: 10000}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.attribs.validate()
+			verify.Values(t, "", err == nil, tt.ok)
+		})
+	}
+}
+
+func TestNotificationShutdown(t *testing.T) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+
+	var mu sync.Mutex
+	var deleted []uint32
+	srv.Handle(ams.CmdADSDeleteDeviceNotification, func(req goadstest.Packet) goadstest.Packet {
+		mu.Lock()
+		deleted = append(deleted, req.(*ams.DeleteDeviceNotificationRequest).NotificationHandle)
+		mu.Unlock()
+		h := req.Header()
+		return ams.NewDeleteDeviceNotificationResponse(h.Sender, h.Target, ams.NoError)
+	})
+
+	c := &Client{Addr: srv.Addr()}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	s := c.NewSession(srv.AMSAddr(), ams.MustParseAddr("10.0.0.1.1.1:32000"))
+
+	started, release := make(chan struct{}), make(chan struct{})
+	calls := 0
+	nm := s.NewNotificationManager()
+	nm.handlers[7] = &notificationHandler{handle: 7, callback: func(NotificationSample) {
+		calls++
+		close(started)
+		<-release
+	}}
+
+	req := ams.NewDeviceNotificationRequest(s.senderAddr, srv.AMSAddr(), []ams.NotificationStamp{
+		{Samples: []ams.NotificationSample{{Handle: 7, Size: 1, Data: []byte{1}}}},
+	})
+	go nm.dispatch(req)
+	<-started
+
+	done := make(chan error)
+	go func() { done <- nm.Shutdown(context.Background(), time.Second) }()
+
+	select {
+	case err := <-done:
+		t.Fatalf("Shutdown returned %v before the callback", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	// no callbacks after shutdown
+	nm.handlers[7] = &notificationHandler{handle: 7, callback: func(NotificationSample) { calls++ }}
+	nm.dispatch(req)
+
+	verify.Values(t, "calls", calls, 1)
+	mu.Lock()
+	verify.Values(t, "deleted", deleted, []uint32{7})
+	mu.Unlock()
+}
+
+func TestNotificationShutdownTimeout(t *testing.T) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+
+	c := &Client{Addr: srv.Addr()}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	s := c.NewSession(srv.AMSAddr(), ams.MustParseAddr("10.0.0.1.1.1:32000"))
+
+	started, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	nm := s.NewNotificationManager()
+	nm.handlers[7] = &notificationHandler{handle: 7, callback: func(NotificationSample) {
+		close(started)
+		<-release
+	}}
+	go nm.dispatch(ams.NewDeviceNotificationRequest(s.senderAddr, srv.AMSAddr(), []ams.NotificationStamp{
+		{Samples: []ams.NotificationSample{{Handle: 7, Size: 1, Data: []byte{1}}}},
+	}))
+	<-started
+
+	if err := nm.Shutdown(context.Background(), 10*time.Millisecond); err == nil {
+		t.Fatal("want error for running callback")
+	}
+	verify.Values(t, "handlers", len(nm.handlers), 0)
+}
+
+func TestNotificationShutdownCancelled(t *testing.T) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+
+	deleted := make(chan uint32, 1)
+	srv.Handle(ams.CmdADSDeleteDeviceNotification, func(req goadstest.Packet) goadstest.Packet {
+		deleted <- req.(*ams.DeleteDeviceNotificationRequest).NotificationHandle
+		h := req.Header()
+		return ams.NewDeleteDeviceNotificationResponse(h.Sender, h.Target, ams.NoError)
+	})
+
+	c := &Client{Addr: srv.Addr()}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	s := c.NewSession(srv.AMSAddr(), ams.MustParseAddr("10.0.0.1.1.1:32000"))
+
+	nm := s.NewNotificationManager()
+	nm.handlers[7] = &notificationHandler{handle: 7, callback: func(NotificationSample) {}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := nm.Shutdown(ctx, time.Second); err != nil && !errors.Is(err, context.Canceled) {
+		t.Fatal(err)
+	}
+	select {
+	case h := <-deleted:
+		verify.Values(t, "deleted handle", h, uint32(7))
+	default:
+		t.Fatal("subscription not deleted")
+	}
+}
+
+func TestNotificationSinks(t *testing.T) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+
+	c := &Client{Addr: srv.Addr()}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	s := c.NewSession(srv.AMSAddr(), ams.MustParseAddr("10.0.0.1.1.1:32000"))
+
+	ctx := context.Background()
+	attribs := NotificationAttribs{TransMode: TransModeServerOnChange}
+	got := make(chan string, 10)
+	subscribe := func(name string) (*NotificationManager, uint32) {
+		nm := s.NewNotificationManager()
+		if err := nm.Start(); err != nil {
+			t.Fatal(err)
+		}
+		h, err := nm.SubscribeRaw(ctx, 0x4020, 0, 1, attribs, func(sample NotificationSample) {
+			got <- name
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return nm, h
+	}
+	nm1, h1 := subscribe("first")
+	_, h2 := subscribe("second")
+
+	notify := func(handle uint32) string {
+		stamp := ams.NotificationStamp{Samples: []ams.NotificationSample{{Handle: handle, Size: 1, Data: []byte{1}}}}
+		if err := srv.Notify(stamp); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case name := <-got:
+			return name
+		case <-time.After(100 * time.Millisecond):
+			return ""
+		}
+	}
+	verify.Values(t, "first handle", notify(h1), "first")
+	verify.Values(t, "second handle", notify(h2), "second")
+
+	nm1.Detach()
+	verify.Values(t, "detached", notify(h1), "")
+	verify.Values(t, "still attached", notify(h2), "second")
+}
+
+func TestNotificationTargets(t *testing.T) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+
+	c := &Client{Addr: srv.Addr()}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// two runtimes of the same PLC which both use notification handle 1
+	sender := ams.MustParseAddr("10.0.0.1.1.1:32000")
+	plc1 := srv.AMSAddr()
+	plc2 := ams.Addr{NetID: plc1.NetID, Port: 852}
+
+	// the server sends notifications to the sender of the last request
+	if _, err := c.ReadState(context.Background(), ams.NewReadStateRequest(plc1, sender)); err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(chan ams.Addr, 10)
+	for _, target := range []ams.Addr{plc1, plc2} {
+		target := target
+		nm := c.NewSession(target, sender).NewNotificationManager()
+		if err := nm.Start(); err != nil {
+			t.Fatal(err)
+		}
+		nm.handlers[1] = &notificationHandler{handle: 1, callback: func(NotificationSample) {
+			got <- target
+		}}
+	}
+
+	for _, from := range []ams.Addr{plc1, plc2} {
+		stamp := ams.NotificationStamp{Samples: []ams.NotificationSample{{Handle: 1, Size: 1, Data: []byte{1}}}}
+		if err := srv.NotifyFrom(from, stamp); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case target := <-got:
+			verify.Values(t, "target of "+from.String(), target.String(), from.String())
+		case <-time.After(time.Second):
+			t.Fatalf("no sample for %s", from)
+		}
+		select {
+		case target := <-got:
+			t.Errorf("sample of %s dispatched to %s", from, target)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+func TestNotificationSubscribeMany(t *testing.T) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+
+	var addData, delData []byte
+	srv.HandleReadWrite(0xF085, func(req *ams.ReadWriteRequest) ([]byte, uint32) {
+		addData = req.Data
+		// the second notification fails
+		return []byte{
+			0, 0, 0, 0, 100, 0, 0, 0,
+			0x10, 0x07, 0, 0, 0, 0, 0, 0,
+			0, 0, 0, 0, 101, 0, 0, 0,
+		}, ams.NoError
+	})
+	srv.HandleReadWrite(0xF086, func(req *ams.ReadWriteRequest) ([]byte, uint32) {
+		delData = req.Data
+		return []byte{0, 0, 0, 0, 0, 0, 0, 0}, ams.NoError
+	})
+
+	c := &Client{Addr: srv.Addr()}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	s := c.NewSession(srv.AMSAddr(), ams.MustParseAddr("10.0.0.1.1.1:32000"))
+	s.registry.Set("MAIN.a", &SymbolInfo{Name: "MAIN.a", DataType: "INT", Size: 2, Handle: 1, IndexGroup: 0x4020, IndexOffset: 8})
+	s.registry.Set("MAIN.b", &SymbolInfo{Name: "MAIN.b", DataType: "INT", Size: 2, Handle: 2, IndexGroup: 0x4020, IndexOffset: 10})
+	s.registry.Set("MAIN.c", &SymbolInfo{Name: "MAIN.c", DataType: "BYTE", Size: 1, Handle: 3})
+
+	onChange := NotificationAttribs{TransMode: TransModeServerOnChange}
+	nm := s.NewNotificationManager()
+	handles, err := nm.SubscribeMany(context.Background(), []SubscribeSpec{
+		{VarName: "MAIN.a", Attribs: onChange},
+		{VarName: "MAIN.b", Attribs: onChange},
+		{VarName: "MAIN.invalid", Attribs: NotificationAttribs{}},
+		{VarName: "MAIN.c", Attribs: NotificationAttribs{TransMode: TransModeCyclic, CycleTime: 10000}},
+	})
+	if err == nil {
+		t.Fatal("got no error for the failed subscriptions")
+	}
+	verify.Values(t, "handles", handles, []uint32{100, 0, 0, 101})
+	verify.Values(t, "registered", len(nm.handlers), 2)
+	verify.Values(t, "handler", nm.handlers[101].varName, "MAIN.c")
+
+	want := make([]byte, 3*40)
+	for i, v := range []uint32{
+		0x4020, 8, 2, 4, 0, 0,
+		0x4020, 10, 2, 4, 0, 0,
+		0xF005, 3, 1, 10, 0, 10000,
+	} {
+		ByteOrder.PutUint32(want[i/6*40+i%6*4:], v)
+	}
+	verify.Values(t, "add request", addData, want)
+
+	if err := nm.UnsubscribeAll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "registered after unsubscribe", len(nm.handlers), 0)
+	verify.Values(t, "delete request", len(delData), 8)
+}
+
+func TestNotificationSubscribeManyFallback(t *testing.T) {
+	tests := []struct {
+		name     string
+		result   uint32
+		wantAdds int
+	}{
+		{"not supported", ams.DeviceServiceNotSupported, 2},
+		{"other error", ams.DeviceSymbolNotFound, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := goadstest.NewServer()
+			defer srv.Close()
+			srv.HandleReadWrite(0xF085, func(req *ams.ReadWriteRequest) ([]byte, uint32) {
+				return nil, tt.result
+			})
+			var adds uint32
+			srv.Handle(ams.CmdADSAddDeviceNotification, func(req goadstest.Packet) goadstest.Packet {
+				h := req.Header()
+				return ams.NewAddDeviceNotificationResponse(h.Sender, h.Target, ams.NoError, atomic.AddUint32(&adds, 1))
+			})
+
+			c := &Client{Addr: srv.Addr()}
+			if err := c.Dial(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+			s := c.NewSession(srv.AMSAddr(), ams.MustParseAddr("10.0.0.1.1.1:32000"))
+			s.registry.Set("MAIN.a", &SymbolInfo{Name: "MAIN.a", DataType: "INT", Size: 2, Handle: 1})
+			s.registry.Set("MAIN.b", &SymbolInfo{Name: "MAIN.b", DataType: "INT", Size: 2, Handle: 2})
+
+			nm := s.NewNotificationManager()
+			_, err := nm.SubscribeMany(context.Background(), []SubscribeSpec{
+				{VarName: "MAIN.a", Attribs: NotificationAttribs{TransMode: TransModeServerOnChange}},
+				{VarName: "MAIN.b", Attribs: NotificationAttribs{TransMode: TransModeServerOnChange}},
+			})
+			if got, want := err != nil, tt.wantAdds == 0; got != want {
+				t.Fatalf("got error %v want error %v", err, want)
+			}
+			verify.Values(t, "individual adds", int(atomic.LoadUint32(&adds)), tt.wantAdds)
+		})
+	}
+}
+
+func TestNotificationDecodeStructs(t *testing.T) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+
+	srv.Handle(ams.CmdADSAddDeviceNotification, func(req goadstest.Packet) goadstest.Packet {
+		h := req.Header()
+		return ams.NewAddDeviceNotificationResponse(h.Sender, h.Target, ams.NoError, 5)
+	})
+
+	c := &Client{Addr: srv.Addr()}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	s := c.NewSession(srv.AMSAddr(), ams.MustParseAddr("10.0.0.1.1.1:32000"))
+	s.registry.Set("MAIN.axis", &SymbolInfo{Name: "MAIN.axis", DataType: "ST_Axis", Size: 3, Handle: 1})
+	s.types.Set("ST_Axis", &DataTypeInfo{Name: "ST_Axis", Fields: []StructField{
+		{Name: "Enabled", DataType: "BOOL", Offset: 0, Size: 1},
+		{Name: "Pos", DataType: "ST_Pos", Offset: 1, Size: 2},
+	}})
+	s.types.Set("ST_Pos", &DataTypeInfo{Name: "ST_Pos", Fields: []StructField{
+		{Name: "X", DataType: "INT", Offset: 0, Size: 2},
+	}})
+
+	var got []NotificationSample
+	nm := s.NewNotificationManager()
+	nm.SetDecodeStructs(true)
+	_, err := nm.Subscribe(context.Background(), "MAIN.axis", time.Second, func(sample NotificationSample) {
+		got = append(got, sample)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, data := range [][]byte{{1, 7, 0}, {0, 8, 0}} {
+		nm.dispatch(ams.NewDeviceNotificationRequest(s.senderAddr, srv.AMSAddr(), []ams.NotificationStamp{
+			{Samples: []ams.NotificationSample{{Handle: 5, Size: 3, Data: data}}},
+		}))
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d samples want 2", len(got))
+	}
+	verify.Values(t, "first", StructToMap(got[0].Fields), map[string]interface{}{
+		"Enabled": true,
+		"Pos":     map[string]interface{}{"X": int16(7)},
+	})
+	verify.Values(t, "second", StructToMap(got[1].Fields), map[string]interface{}{
+		"Enabled": false,
+		"Pos":     map[string]interface{}{"X": int16(8)},
+	})
+}
@@ -0,0 +1,69 @@
+package goads
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mrpasztoradam/goads/ams"
+)
+
+// appInfoSymbol is the TwinCAT 3 system variable of type PlcAppSystemInfo
+// which describes the PLC application
+const appInfoSymbol = "TwinCAT_SystemInfoVarList._AppInfo"
+
+// ProjectInfo describes the PLC project and the TwinCAT runtime
+type ProjectInfo struct {
+	DeviceName   string    // Name of the ADS device, e.g. "Plc30 App"
+	Version      string    // TwinCAT version, e.g. "3.1.4024"
+	ProjectName  string    // Name of the PLC project
+	AppName      string    // Name of the PLC application
+	AppTimestamp time.Time // Time the application was built, zero if unknown
+}
+
+// GetProjectInfo reads the device info and the application info of the
+// PLC. The project fields are empty if the PLC has no application info
+// variable, e.g. on TwinCAT 2.
+func (s *Session) GetProjectInfo(ctx context.Context) (ProjectInfo, error) {
+	resp, err := s.client.ReadDeviceInfo(ctx, ams.NewReadDeviceInfoRequest(s.targetAddr, s.senderAddr))
+	if err != nil {
+		return ProjectInfo{}, fmt.Errorf("failed to read device info: %w", err)
+	}
+	if resp.Result != ams.NoError {
+		return ProjectInfo{}, fmt.Errorf("failed to read device info: %w", ams.ADSError(resp.Result))
+	}
+	info := ProjectInfo{
+		DeviceName: nullTerminatedString(resp.DeviceName[:]),
+		Version:    fmt.Sprintf("%d.%d.%d", resp.MajorVersion, resp.MinorVersion, resp.BuildVersion),
+	}
+
+	data, sym, err := s.Read(ctx, appInfoSymbol)
+	if errors.Is(err, ErrSymbolNotFound) {
+		return info, nil
+	}
+	if err != nil {
+		return ProjectInfo{}, err
+	}
+	fields, err := s.resolveType(ctx, sym.DataType)
+	if err != nil {
+		return ProjectInfo{}, fmt.Errorf("failed to get data type info: %w", err)
+	}
+
+	// the layout differs between TwinCAT versions so the
+	// fields are looked up by name and missing ones skipped
+	field := func(name string) []byte {
+		_, b, err := FindNestedField(fields, []string{name}, data)
+		if err != nil {
+			return nil
+		}
+		return b
+	}
+	info.ProjectName = nullTerminatedString(field("ProjectName"))
+	info.AppName = nullTerminatedString(field("AppName"))
+	if b := field("AppTimestamp"); len(b) == 4 {
+		// DT is seconds since 1970
+		info.AppTimestamp = time.Unix(int64(ByteOrder.Uint32(b)), 0).UTC()
+	}
+	return info, nil
+}
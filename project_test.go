@@ -0,0 +1,59 @@
+package goads
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mrpasztoradam/goads/ams"
+	"github.com/mrpasztoradam/goads/goadstest"
+	"github.com/pascaldekloe/goe/verify"
+)
+
+func TestGetProjectInfo(t *testing.T) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+
+	appInfo := make([]byte, 12)
+	ByteOrder.PutUint32(appInfo[0:4], 1700000000)
+	copy(appInfo[4:], "Demo\x00")
+	srv.HandleReadWrite(0xF009, func(req *ams.ReadWriteRequest) ([]byte, uint32) {
+		return nil, ams.DeviceSymbolNotFound
+	})
+	srv.HandleReadWrite(ams.IdxGetSymHandleByName, func(req *ams.ReadWriteRequest) ([]byte, uint32) {
+		return []byte{1, 0, 0, 0}, ams.NoError
+	})
+	srv.HandleRead(0xF005, func(req *ams.ReadRequest) ([]byte, uint32) {
+		return appInfo, ams.NoError
+	})
+
+	c := &Client{Addr: srv.Addr()}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// TwinCAT 2 has no application info
+	s := c.NewSession(srv.AMSAddr(), ams.MustParseAddr("10.0.0.1.1.1:32000"))
+	got, err := s.GetProjectInfo(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "without app info", got, ProjectInfo{DeviceName: "goadstest", Version: "3.1.4024"})
+
+	s.registry.Set(appInfoSymbol, &SymbolInfo{Name: appInfoSymbol, DataType: "PlcAppSystemInfo", Size: 12})
+	s.types.Set("PlcAppSystemInfo", &DataTypeInfo{Name: "PlcAppSystemInfo", Fields: []StructField{
+		{Name: "AppTimestamp", DataType: "DT", Offset: 0, Size: 4},
+		{Name: "ProjectName", DataType: "STRING(7)", Offset: 4, Size: 8},
+	}})
+	got, err = s.GetProjectInfo(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "with app info", got, ProjectInfo{
+		DeviceName:   "goadstest",
+		Version:      "3.1.4024",
+		ProjectName:  "Demo",
+		AppTimestamp: time.Unix(1700000000, 0).UTC(),
+	})
+}
@@ -0,0 +1,152 @@
+package goads
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// maxRESTBody limits the size of PUT request bodies
+const maxRESTBody = 1 << 20
+
+// restHandler serves the symbols of a session over HTTP
+type restHandler struct {
+	session *Session
+}
+
+// NewRESTHandler returns an http.Handler which exposes the symbols of
+// the session as JSON:
+//
+//	GET /symbols         lists the cached symbols
+//	GET /symbols/{name}  returns the value of the symbol
+//	PUT /symbols/{name}  writes the JSON value in the body to the symbol
+//
+// Unknown symbols return 404 and values which do not match the data type
+// of the symbol return 400. The list only contains the symbols loaded
+// with LoadSymbolTable or accessed before.
+func NewRESTHandler(session *Session) http.Handler {
+	return &restHandler{session: session}
+}
+
+func (h *restHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/symbols" || r.URL.Path == "/symbols/":
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, http.MethodGet)
+			return
+		}
+		h.list(w, r)
+
+	case strings.HasPrefix(r.URL.Path, "/symbols/"):
+		name := strings.TrimPrefix(r.URL.Path, "/symbols/")
+		switch r.Method {
+		case http.MethodGet:
+			h.read(w, r, name)
+		case http.MethodPut:
+			h.write(w, r, name)
+		default:
+			methodNotAllowed(w, http.MethodGet+", "+http.MethodPut)
+		}
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *restHandler) list(w http.ResponseWriter, r *http.Request) {
+	all := h.session.registry.GetAll()
+	symbols := make([]*SymbolInfo, 0, len(all))
+	for _, info := range all {
+		symbols = append(symbols, info)
+	}
+	sort.Slice(symbols, func(i, j int) bool {
+		return symbols[i].Name < symbols[j].Name
+	})
+
+	b, err := json.Marshal(symbols)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, b)
+}
+
+func (h *restHandler) read(w http.ResponseWriter, r *http.Request, name string) {
+	ctx := r.Context()
+	data, info, err := h.session.Read(ctx, name)
+	if err != nil {
+		symbolError(w, err)
+		return
+	}
+
+	var v interface{}
+	if IsPrimitiveType(info.DataType) {
+		v, err = DecodeFieldValueStrict(data, info.DataType)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+	} else {
+		fields, err := h.session.decodeStruct(ctx, info.DataType, data)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		v = fields
+	}
+
+	b, err := ValueToJSON(v, info.DataType)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, b)
+}
+
+func (h *restHandler) write(w http.ResponseWriter, r *http.Request, name string) {
+	ctx := r.Context()
+	info, err := h.session.GetSymbol(ctx, name)
+	if err != nil {
+		symbolError(w, err)
+		return
+	}
+
+	raw, err := io.ReadAll(io.LimitReader(r.Body, maxRESTBody))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	data, err := ValueFromJSON(raw, info.DataType)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.session.Write(ctx, name, data); err != nil {
+		symbolError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// symbolError writes 404 for unknown symbols and 502 otherwise
+func symbolError(w http.ResponseWriter, err error) {
+	code := http.StatusBadGateway
+	if errors.Is(err, ErrSymbolNotFound) {
+		code = http.StatusNotFound
+	}
+	http.Error(w, err.Error(), code)
+}
+
+func methodNotAllowed(w http.ResponseWriter, allow string) {
+	w.Header().Set("Allow", allow)
+	http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+}
+
+func writeJSON(w http.ResponseWriter, b []byte) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
@@ -0,0 +1,80 @@
+package goads
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mrpasztoradam/goads/ams"
+	"github.com/mrpasztoradam/goads/goadstest"
+	"github.com/pascaldekloe/goe/verify"
+)
+
+func TestRESTHandler(t *testing.T) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+
+	value := []byte{0xfe, 0xff} // -2
+	srv.HandleReadWrite(0xF009, func(req *ams.ReadWriteRequest) ([]byte, uint32) {
+		return nil, ams.DeviceSymbolNotFound
+	})
+	srv.HandleReadWrite(ams.IdxGetSymHandleByName, func(req *ams.ReadWriteRequest) ([]byte, uint32) {
+		return []byte{1, 0, 0, 0}, ams.NoError
+	})
+	srv.HandleRead(0xF005, func(req *ams.ReadRequest) ([]byte, uint32) {
+		return value, ams.NoError
+	})
+	srv.HandleWrite(0xF005, func(req *ams.WriteRequest) uint32 {
+		value = req.Data
+		return ams.NoError
+	})
+
+	c := &Client{Addr: srv.Addr()}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	s := c.NewSession(srv.AMSAddr(), ams.MustParseAddr("10.0.0.1.1.1:32000"))
+	s.registry.Set("MAIN.nValue", &SymbolInfo{Name: "MAIN.nValue", DataType: "INT", Size: 2})
+
+	h := httptest.NewServer(NewRESTHandler(s))
+	defer h.Close()
+
+	tests := []struct {
+		method, path, body string
+		code               int
+		resp               string
+	}{
+		{"GET", "/symbols", "", 200, `[{"name":"MAIN.nValue","dataType":"INT","size":2,"indexGroup":0,"indexOffset":0}]`},
+		{"GET", "/symbols/MAIN.nValue", "", 200, `-2`},
+		{"PUT", "/symbols/MAIN.nValue", `7`, 204, ``},
+		{"GET", "/symbols/MAIN.nValue", "", 200, `7`},
+		{"PUT", "/symbols/MAIN.nValue", `"7"`, 400, "invalid INT value: \"7\"\n"},
+		{"PUT", "/symbols/MAIN.nValue", `70000`, 400, "invalid INT value: 70000\n"},
+		{"GET", "/symbols/MAIN.unknown", "", 404, "failed to get symbol info: symbol not found: MAIN.unknown\n"},
+		{"DELETE", "/symbols/MAIN.nValue", "", 405, "Method Not Allowed\n"},
+	}
+
+	for _, tt := range tests {
+		req, err := http.NewRequest(tt.method, h.URL+tt.path, strings.NewReader(tt.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		name := tt.method + " " + tt.path + " " + tt.body
+		verify.Values(t, name+" code", resp.StatusCode, tt.code)
+		verify.Values(t, name+" body", string(b), tt.resp)
+	}
+}
@@ -0,0 +1,103 @@
+package goads
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/mrpasztoradam/goads/ams"
+)
+
+// DefaultTransientErrors are the ADS errors which RetryPolicy retries
+// unless configured otherwise. They occur while the PLC or the router
+// restart.
+var DefaultTransientErrors = []ams.ADSError{
+	ams.InsertMailboxError,
+	ams.TargetPortNotFound,
+	ams.TargetMachineNotFound,
+	ams.ClientSyncTimeout,
+}
+
+// RetryPolicy retries operations which fail with a transient ADS error
+// with exponential backoff and jitter. All other errors fail immediately.
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts including the first one.
+	// Values below one mean one attempt.
+	MaxAttempts int
+
+	// BaseDelay is the delay before the first retry. It doubles with
+	// every retry and a random jitter of up to half the delay is
+	// subtracted.
+	BaseDelay time.Duration
+
+	// Transient are the ADS errors which are retried.
+	// DefaultTransientErrors is used if it is nil.
+	Transient []ams.ADSError
+}
+
+// Do calls fn until it succeeds, fails with a non-transient error,
+// the attempts are used up or the context is cancelled. It returns
+// the last error of fn or the context error.
+func (p RetryPolicy) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	delay := p.BaseDelay
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil || attempt >= p.MaxAttempts || !p.isTransient(err) {
+			return err
+		}
+
+		t := time.NewTimer(jitter(delay))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+		delay *= 2
+	}
+}
+
+func (p RetryPolicy) isTransient(err error) bool {
+	var adsErr ams.ADSError
+	if !errors.As(err, &adsErr) {
+		return false
+	}
+	transient := p.Transient
+	if transient == nil {
+		transient = DefaultTransientErrors
+	}
+	for _, e := range transient {
+		if e == adsErr {
+			return true
+		}
+	}
+	return false
+}
+
+// jitter returns a random duration between d/2 and d
+func jitter(d time.Duration) time.Duration {
+	if d <= 1 {
+		return d
+	}
+	return d - time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// RetryRead is like Read but retries transient errors with the policy.
+func (s *Session) RetryRead(ctx context.Context, name string, p RetryPolicy) ([]byte, *SymbolInfo, error) {
+	var data []byte
+	var info *SymbolInfo
+	err := p.Do(ctx, func(ctx context.Context) error {
+		var err error
+		data, info, err = s.Read(ctx, name)
+		return err
+	})
+	return data, info, err
+}
+
+// RetryWrite is like Write but retries transient errors with the policy.
+func (s *Session) RetryWrite(ctx context.Context, name string, data []byte, p RetryPolicy) error {
+	return p.Do(ctx, func(ctx context.Context) error {
+		return s.Write(ctx, name, data)
+	})
+}
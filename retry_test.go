@@ -0,0 +1,98 @@
+package goads
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mrpasztoradam/goads/ams"
+	"github.com/mrpasztoradam/goads/goadstest"
+	"github.com/pascaldekloe/goe/verify"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	transient := fmt.Errorf("failed to read: %w", ams.ADSError(ams.TargetPortNotFound))
+	permanent := fmt.Errorf("failed to read: %w", ams.ADSError(ams.DeviceSymbolNotFound))
+
+	tests := []struct {
+		name    string
+		policy  RetryPolicy
+		errs    []error
+		calls   int
+		wantErr error
+	}{
+		{"success", RetryPolicy{MaxAttempts: 3}, []error{nil}, 1, nil},
+		{"transient then success", RetryPolicy{MaxAttempts: 3}, []error{transient, transient, nil}, 3, nil},
+		{"attempts used up", RetryPolicy{MaxAttempts: 2}, []error{transient, transient, nil}, 2, transient},
+		{"permanent", RetryPolicy{MaxAttempts: 3}, []error{permanent, nil}, 1, permanent},
+		{"not an ADS error", RetryPolicy{MaxAttempts: 3}, []error{ErrTimeout, nil}, 1, ErrTimeout},
+		{
+			"custom transient",
+			RetryPolicy{MaxAttempts: 3, Transient: []ams.ADSError{ams.DeviceSymbolNotFound}},
+			[]error{permanent, transient},
+			2, transient,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.policy.BaseDelay = time.Millisecond
+			calls := 0
+			err := tt.policy.Do(context.Background(), func(ctx context.Context) error {
+				err := tt.errs[calls]
+				calls++
+				return err
+			})
+			verify.Values(t, "error", err, tt.wantErr)
+			verify.Values(t, "calls", calls, tt.calls)
+		})
+	}
+}
+
+func TestRetryPolicyCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := RetryPolicy{MaxAttempts: 10, BaseDelay: time.Hour}
+	err := p.Do(ctx, func(ctx context.Context) error {
+		cancel()
+		return ams.ADSError(ams.TargetPortNotFound)
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("got error %v want %v", err, context.Canceled)
+	}
+}
+
+func TestRetryRead(t *testing.T) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+
+	// the PLC runtime is restarting for the first two reads
+	reads := 0
+	srv.HandleReadWrite(ams.IdxGetSymHandleByName, func(req *ams.ReadWriteRequest) ([]byte, uint32) {
+		return []byte{1, 0, 0, 0}, ams.NoError
+	})
+	srv.HandleRead(0xF005, func(req *ams.ReadRequest) ([]byte, uint32) {
+		reads++
+		if reads < 3 {
+			return nil, ams.TargetPortNotFound
+		}
+		return []byte{7, 0}, ams.NoError
+	})
+
+	c := &Client{Addr: srv.Addr()}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	s := c.NewSession(srv.AMSAddr(), ams.MustParseAddr("10.0.0.1.1.1:32000"))
+	s.registry.Set("MAIN.nValue", &SymbolInfo{Name: "MAIN.nValue", DataType: "INT", Size: 2})
+
+	data, _, err := s.RetryRead(context.Background(), "MAIN.nValue", RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "data", data, []byte{7, 0})
+	verify.Values(t, "reads", reads, 3)
+}
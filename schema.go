@@ -0,0 +1,72 @@
+package goads
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SchemaMismatch describes a symbol which does not match the expected schema
+type SchemaMismatch struct {
+	Name         string // Symbol name
+	Expected     string // Expected data type
+	Actual       string // Data type in the registry, empty if Missing
+	ExpectedSize uint32 // Expected size in bytes, zero if not declared
+	ActualSize   uint32 // Size in the registry
+	Missing      bool   // Symbol is not in the registry
+}
+
+func (m SchemaMismatch) String() string {
+	if m.Missing {
+		return fmt.Sprintf("%s: missing, want %s", m.Name, m.Expected)
+	}
+	if m.ExpectedSize != 0 && m.ActualSize != m.ExpectedSize {
+		return fmt.Sprintf("%s: got %s:%d want %s:%d", m.Name, m.Actual, m.ActualSize, m.Expected, m.ExpectedSize)
+	}
+	return fmt.Sprintf("%s: got %s want %s", m.Name, m.Actual, m.Expected)
+}
+
+// ValidateSchema compares the expected data types by symbol name with the
+// symbol registry and returns the missing symbols and those with a
+// different data type or size sorted by name. An expected data type may
+// declare the size in bytes after a colon, e.g. "STRING(80):81". Data
+// types are compared case-insensitively. It should be called after
+// LoadSymbolTable.
+func (s *Session) ValidateSchema(expected map[string]string) []SchemaMismatch {
+	var mismatches []SchemaMismatch
+	for name, dataType := range expected {
+		dataType, size := splitSchemaSize(dataType)
+		info, ok := s.registry.Get(name)
+		switch {
+		case !ok:
+			mismatches = append(mismatches, SchemaMismatch{Name: name, Expected: dataType, ExpectedSize: size, Missing: true})
+		case !strings.EqualFold(info.DataType, dataType), size != 0 && info.Size != size:
+			mismatches = append(mismatches, SchemaMismatch{
+				Name:         name,
+				Expected:     dataType,
+				Actual:       info.DataType,
+				ExpectedSize: size,
+				ActualSize:   info.Size,
+			})
+		}
+	}
+	sort.Slice(mismatches, func(i, j int) bool {
+		return mismatches[i].Name < mismatches[j].Name
+	})
+	return mismatches
+}
+
+// splitSchemaSize splits an expected data type into the type and the
+// optional size after the last colon. The size is zero if not declared.
+func splitSchemaSize(s string) (dataType string, size uint32) {
+	i := strings.LastIndexByte(s, ':')
+	if i < 0 {
+		return s, 0
+	}
+	n, err := strconv.ParseUint(s[i+1:], 10, 32)
+	if err != nil {
+		return s, 0
+	}
+	return s[:i], uint32(n)
+}
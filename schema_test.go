@@ -0,0 +1,32 @@
+package goads
+
+import (
+	"testing"
+
+	"github.com/mrpasztoradam/goads/ams"
+	"github.com/pascaldekloe/goe/verify"
+)
+
+func TestValidateSchema(t *testing.T) {
+	s := (&Client{}).NewSession(ams.Addr{}, ams.Addr{})
+	s.registry.Set("MAIN.nCount", &SymbolInfo{Name: "MAIN.nCount", DataType: "INT"})
+	s.registry.Set("MAIN.fTemp", &SymbolInfo{Name: "MAIN.fTemp", DataType: "REAL"})
+	s.registry.Set("MAIN.sName", &SymbolInfo{Name: "MAIN.sName", DataType: "STRING(80)", Size: 81})
+	s.registry.Set("MAIN.aData", &SymbolInfo{Name: "MAIN.aData", DataType: "ST_Data", Size: 12})
+
+	got := s.ValidateSchema(map[string]string{
+		"MAIN.nCount": "INT",
+		"MAIN.fTemp":  "LREAL",
+		"MAIN.sName":  "string(80):81",
+		"MAIN.aData":  "ST_Data:16",
+		"MAIN.bReady": "BOOL",
+	})
+	verify.Values(t, "mismatches", got, []SchemaMismatch{
+		{Name: "MAIN.aData", Expected: "ST_Data", Actual: "ST_Data", ExpectedSize: 16, ActualSize: 12},
+		{Name: "MAIN.bReady", Expected: "BOOL", Missing: true},
+		{Name: "MAIN.fTemp", Expected: "LREAL", Actual: "REAL"},
+	})
+	verify.Values(t, "size", got[0].String(), "MAIN.aData: got ST_Data:12 want ST_Data:16")
+	verify.Values(t, "missing", got[1].String(), "MAIN.bReady: missing, want BOOL")
+	verify.Values(t, "type", got[2].String(), "MAIN.fTemp: got REAL want LREAL")
+}
@@ -1,12 +1,21 @@
 package goads
 
 import (
+	"bytes"
 	"context"
 	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log"
+	"math"
 	"os"
+	"path"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/mrpasztoradam/goads/ams"
@@ -19,55 +28,117 @@ func min(a, b int) int {
 	return b
 }
 
+// ADSClient is the part of the client which a Session uses. It is
+// implemented by *Client. Like *Client, Read, Write and ReadWrite must
+// return the result of a failed command as ams.ADSError. Fakes which do
+// not support notifications can implement AddNotificationSink and
+// RemoveNotificationSink as no-ops.
+type ADSClient interface {
+	Read(ctx context.Context, r *ams.ReadRequest) (*ams.ReadResponse, error)
+	Write(ctx context.Context, r *ams.WriteRequest) (*ams.WriteResponse, error)
+	ReadWrite(ctx context.Context, r *ams.ReadWriteRequest) (*ams.ReadWriteResponse, error)
+	ReadDeviceInfo(ctx context.Context, r *ams.ReadDeviceInfoRequest) (*ams.ReadDeviceInfoResponse, error)
+	AddDeviceNotification(ctx context.Context, r *ams.AddDeviceNotificationRequest) (*ams.AddDeviceNotificationResponse, error)
+	DeleteDeviceNotification(ctx context.Context, r *ams.DeleteDeviceNotificationRequest) (*ams.DeleteDeviceNotificationResponse, error)
+	GetSymbol(ctx context.Context, targetAddr, senderAddr ams.Addr, name string) (*Symbol, error)
+	GetSymHandleByName(ctx context.Context, targetAddr, senderAddr ams.Addr, name string) (uint32, error)
+	GetDataTypeInfo(ctx context.Context, targetAddr, senderAddr ams.Addr, typeName string) ([]StructField, error)
+	AddNotificationSink(nm *NotificationManager)
+	RemoveNotificationSink(nm *NotificationManager)
+}
+
 // Session represents a cached ADS session with a specific target
 type Session struct {
-	client            *Client
+	client            ADSClient
 	targetAddr        ams.Addr
 	senderAddr        ams.Addr
 	registry          *SymbolRegistry
+	symbolVersion     uint8   // of the loaded symbol table
+	readChunkSize     uint32  // of ReadLarge, 0 for the default
+	maxSymbolTable    uint32  // of LoadSymbolTable, 0 for the default
+	verifyEpsilon     float64 // of WriteAndVerify for REAL and LREAL
+	noValueByName     bool    // PLC does not support reading by name
+	connection        uint32  // of the cached handles, see dropStaleHandles
+	handles           handleCache
+	handleGroup       handleGroup
+	types             *TypeRegistry
+	layouts           *TypeRegistry // used if the PLC has no data type info
+	enums             *TypeRegistry // set with SetEnumType
 	notificationMgr   *NotificationManager
 	notificationMgrMu sync.Mutex
+	parent            *Session   // of a session from WithContext
+	closeMu           sync.Mutex // serializes Close
 	mu                sync.RWMutex
 }
 
 // SymbolInfo contains cached information about a PLC symbol
 type SymbolInfo struct {
-	Name        string        `json:"name"`
-	DataType    string        `json:"dataType"`
-	Size        uint32        `json:"size"`
-	IndexGroup  uint32        `json:"indexGroup"`
-	IndexOffset uint32        `json:"indexOffset"`
-	Handle      uint32        `json:"handle,omitempty"`
-	Comment     string        `json:"comment,omitempty"`
-	Fields      []StructField `json:"fields,omitempty"`
+	Name        string            `json:"name"`
+	DataType    string            `json:"dataType"`
+	Size        uint32            `json:"size"`
+	IndexGroup  uint32            `json:"indexGroup"`
+	IndexOffset uint32            `json:"indexOffset"`
+	Handle      uint32            `json:"handle,omitempty"`
+	Comment     string            `json:"comment,omitempty"`
+	Attributes  map[string]string `json:"attributes,omitempty"`
+	Flags       uint16            `json:"flags,omitempty"` // ADSSYMBOLFLAG_*
+	Fields      []StructField     `json:"fields,omitempty"`
+
+	details bool // Comment, Attributes and Flags are known
 }
 
-// SymbolRegistry holds cached symbol information
+// SymbolRegistry holds cached symbol information. Symbol names are
+// case-insensitive like in TwinCAT, but the registry keeps the casing
+// of the name that was set first.
 type SymbolRegistry struct {
 	symbols map[string]*SymbolInfo
-	mu      sync.RWMutex
+	// folded maps the lower case names to the keys of symbols
+	folded map[string]string
+	mu     sync.RWMutex
 }
 
 // NewSymbolRegistry creates a new symbol registry
 func NewSymbolRegistry() *SymbolRegistry {
 	return &SymbolRegistry{
 		symbols: make(map[string]*SymbolInfo),
+		folded:  make(map[string]string),
 	}
 }
 
-// Get retrieves a symbol from the registry
+// Get retrieves a symbol from the registry. The name is matched
+// case-insensitively.
 func (r *SymbolRegistry) Get(name string) (*SymbolInfo, bool) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
-	info, ok := r.symbols[name]
-	return info, ok
+	if info, ok := r.symbols[name]; ok {
+		return info, true
+	}
+	key, ok := r.folded[strings.ToLower(name)]
+	if !ok {
+		return nil, false
+	}
+	return r.symbols[key], true
 }
 
-// Set adds or updates a symbol in the registry
+// Set adds or updates a symbol in the registry. A symbol which differs
+// only in case is updated and keeps its name.
 func (r *SymbolRegistry) Set(name string, info *SymbolInfo) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	lower := strings.ToLower(name)
+	if key, ok := r.folded[lower]; ok {
+		name = key
+	}
 	r.symbols[name] = info
+	r.folded[lower] = name
+}
+
+// Clear removes all symbols
+func (r *SymbolRegistry) Clear() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.symbols = make(map[string]*SymbolInfo)
+	r.folded = make(map[string]string)
 }
 
 // GetAll returns all symbols
@@ -89,50 +160,294 @@ func (r *SymbolRegistry) Count() int {
 	return len(r.symbols)
 }
 
+// Find returns the symbols matching the pattern sorted by name.
+// A pattern with any of the glob characters '*' or '?' is matched
+// with path.Match, e.g. "MAIN.Axes.*". Otherwise the pattern matches
+// the symbol with that name and all of its sub items. Brackets are
+// always matched literally since they are part of array element
+// names like "MAIN.aAxes[1]".
+func (r *SymbolRegistry) Find(pattern string) []*SymbolInfo {
+	return r.find(pattern, false)
+}
+
+// FindFold is like Find but matches case-insensitively.
+func (r *SymbolRegistry) FindFold(pattern string) []*SymbolInfo {
+	return r.find(pattern, true)
+}
+
+// globBrackets escapes brackets for path.Match.
+var globBrackets = strings.NewReplacer("[", `\[`, "]", `\]`)
+
+func (r *SymbolRegistry) find(pattern string, fold bool) []*SymbolInfo {
+	if fold {
+		pattern = strings.ToLower(pattern)
+	}
+	glob := strings.ContainsAny(pattern, "*?")
+	if glob {
+		pattern = globBrackets.Replace(pattern)
+	}
+
+	r.mu.RLock()
+	var result []*SymbolInfo
+	for name, info := range r.symbols {
+		if fold {
+			name = strings.ToLower(name)
+		}
+		var ok bool
+		if glob {
+			// a malformed pattern matches nothing
+			ok, _ = path.Match(pattern, name)
+		} else {
+			ok = name == pattern || strings.HasPrefix(name, pattern+".")
+		}
+		if ok {
+			result = append(result, info)
+		}
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
 // NewSession creates a new ADS session with the specified target
 func (c *Client) NewSession(targetAddr, senderAddr ams.Addr) *Session {
+	return NewSessionWithClient(c, targetAddr, senderAddr)
+}
+
+// NewSessionWithClient creates a new ADS session which sends its
+// requests with client, e.g. a fake for testing code without a PLC.
+func NewSessionWithClient(client ADSClient, targetAddr, senderAddr ams.Addr) *Session {
 	return &Session{
-		client:     c,
+		client:     client,
 		targetAddr: targetAddr,
 		senderAddr: senderAddr,
 		registry:   NewSymbolRegistry(),
+		types:      NewTypeRegistry(),
+		layouts:    NewTypeRegistry(),
+		enums:      NewTypeRegistry(),
+		connection: clientConnections(client),
 	}
 }
 
-// LoadSymbolTable loads the entire symbol table from the PLC using ADS native upload
-// This is the most efficient way to load all symbols at once
-func (s *Session) LoadSymbolTable(ctx context.Context) error {
-	// First, try to get upload info (0xF00C ADSIGRP_SYM_UPLOADINFO2)
-	// This tells us the size of the symbol table
-	infoReq := ams.NewReadRequest(
+// clientConnections returns the number of connections the client has
+// established, or zero if it cannot tell.
+func clientConnections(client ADSClient) uint32 {
+	if c, ok := client.(*Client); ok {
+		return atomic.LoadUint32(&c.connections)
+	}
+	return 0
+}
+
+// ErrSymbolVersionChanged is returned by LoadSymbolsFromJSON when the
+// symbol table of the PLC changed since the symbols were exported.
+var ErrSymbolVersionChanged = errors.New("symbol version changed")
+
+// readSymbolVersion reads the symbol table version of the PLC.
+// The PLC increments it when the symbol table changes.
+func (s *Session) readSymbolVersion(ctx context.Context) (uint8, error) {
+	req := ams.NewReadRequest(
+		s.targetAddr,
+		s.senderAddr,
+		0xF008, // ADSIGRP_SYM_VERSION
+		0x0,
+		1,
+	)
+	resp, err := s.client.Read(ctx, req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get symbol version: %w", err)
+	}
+	if len(resp.Data) < 1 {
+		return 0, fmt.Errorf("failed to get symbol version: empty response")
+	}
+	return resp.Data[0], nil
+}
+
+// SymbolUploadInfo describes the symbol and data type tables of the PLC
+type SymbolUploadInfo struct {
+	SymbolCount    uint32 // Number of symbols
+	SymbolLength   uint32 // Size of the symbol table in bytes
+	DataTypeCount  uint32 // Number of data types
+	DataTypeLength uint32 // Size of the data type table in bytes
+	MaxDynSymbols  uint32 // Maximum number of dynamic symbols
+	UsedDynSymbols uint32 // Number of used dynamic symbols
+	Version        uint8  // Symbol table version, changes on download
+}
+
+// GetSymbolUploadInfo reads the sizes and the version of the symbol
+// table. Tools can compare the version to decide whether the symbol
+// table has to be loaded again. The version is 0 if the target does
+// not support reading it.
+func (s *Session) GetSymbolUploadInfo(ctx context.Context) (SymbolUploadInfo, error) {
+	info, err := s.readSymbolUploadInfo(ctx)
+	if err != nil {
+		return SymbolUploadInfo{}, err
+	}
+	version, err := s.readSymbolVersion(ctx)
+	switch {
+	case err == nil:
+		info.Version = version
+	case !errors.Is(err, ams.ADSError(ams.DeviceServiceNotSupported)):
+		return SymbolUploadInfo{}, err
+	}
+	return info, nil
+}
+
+// readSymbolUploadInfo reads the sizes of the symbol table without the
+// version.
+func (s *Session) readSymbolUploadInfo(ctx context.Context) (SymbolUploadInfo, error) {
+	req := ams.NewReadRequest(
 		s.targetAddr,
 		s.senderAddr,
 		0xF00C, // ADSIGRP_SYM_UPLOADINFO2
 		0x0,
 		0x30, // 48 bytes for upload info structure
 	)
-
-	infoResp, err := s.client.Read(ctx, infoReq)
+	resp, err := s.client.Read(ctx, req)
 	if err != nil {
-		return fmt.Errorf("failed to get symbol upload info: %w", err)
+		return SymbolUploadInfo{}, fmt.Errorf("failed to get symbol upload info: %w", err)
+	}
+	if len(resp.Data) < 8 {
+		return SymbolUploadInfo{}, fmt.Errorf("invalid symbol upload info (length: %d)", len(resp.Data))
 	}
 
-	// Check if there are any symbols
-	if len(infoResp.Data) >= 4 {
-		symbolCount := binary.LittleEndian.Uint32(infoResp.Data[0:4])
-		// If no symbols, return early
-		if symbolCount == 0 {
-			return nil
+	// older runtimes only return the symbol count and length
+	var fields [6]uint32
+	for i := range fields {
+		if len(resp.Data) < 4*i+4 {
+			break
 		}
+		fields[i] = binary.LittleEndian.Uint32(resp.Data[4*i:])
+	}
+	return SymbolUploadInfo{
+		SymbolCount:    fields[0],
+		SymbolLength:   fields[1],
+		DataTypeCount:  fields[2],
+		DataTypeLength: fields[3],
+		MaxDynSymbols:  fields[4],
+		UsedDynSymbols: fields[5],
+	}, nil
+}
+
+// LoadOptions selects the symbols which LoadSymbolTableFiltered stores
+// in the registry.
+type LoadOptions struct {
+	SkipReferences  bool                   // skip REFERENCE TO symbols
+	SkipPointers    bool                   // skip POINTER TO symbols
+	ExcludePrefixes []string               // skip symbols whose name starts with one of them
+	Filter          func(*SymbolInfo) bool // if not nil only symbols for which it returns true are kept
+
+	// Visit is called for each kept symbol instead of storing it in
+	// the registry if it is not nil, e.g. to store the symbols elsewhere.
+	// Loading stops if it returns an error.
+	Visit func(*SymbolInfo) error
+}
+
+// keep returns true if the symbol is stored in the registry
+func (o LoadOptions) keep(info *SymbolInfo) bool {
+	if o.SkipReferences && info.IsReference() {
+		return false
+	}
+	if o.SkipPointers && info.IsPointer() {
+		return false
+	}
+	for _, p := range o.ExcludePrefixes {
+		if strings.HasPrefix(info.Name, p) {
+			return false
+		}
+	}
+	return o.Filter == nil || o.Filter(info)
+}
+
+// IsReference returns true if the symbol is a REFERENCE TO.
+func (i *SymbolInfo) IsReference() bool {
+	return i.Flags&symbolFlagReferenceTo != 0 || strings.HasPrefix(i.DataType, "REFERENCE TO")
+}
+
+// IsReadOnly returns true if the PLC does not allow writing the symbol,
+// e.g. a VAR CONSTANT.
+func (i *SymbolInfo) IsReadOnly() bool {
+	return i.Flags&symbolFlagReadOnly != 0
+}
+
+// IsPersistent returns true if the symbol is a VAR PERSISTENT.
+func (i *SymbolInfo) IsPersistent() bool {
+	return i.Flags&symbolFlagPersistent != 0
+}
+
+// IsStatic returns true if the symbol is a VAR_STAT.
+func (i *SymbolInfo) IsStatic() bool {
+	return i.Flags&symbolFlagStatic != 0
+}
+
+// IsTComInterfacePtr returns true if the symbol is an interface pointer
+// to a TcCOM object.
+func (i *SymbolInfo) IsTComInterfacePtr() bool {
+	return i.Flags&symbolFlagTComInterfacePtr != 0
+}
+
+// IsPointer returns true if the symbol is a POINTER TO.
+func (i *SymbolInfo) IsPointer() bool {
+	return strings.HasPrefix(i.DataType, "POINTER TO")
+}
+
+// LoadSymbolTable loads the entire symbol table from the PLC using ADS native upload
+// This is the most efficient way to load all symbols at once
+func (s *Session) LoadSymbolTable(ctx context.Context) error {
+	return s.LoadSymbolTableFiltered(ctx, LoadOptions{})
+}
+
+// LoadSymbolTableFiltered is like LoadSymbolTable but only stores the
+// symbols selected by opts, e.g. to keep the registry small for an HMI.
+func (s *Session) LoadSymbolTableFiltered(ctx context.Context, opts LoadOptions) error {
+	// The upload info tells us the size of the symbol table
+	info, err := s.readSymbolUploadInfo(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Remember the version for ExportSymbolsToJSON. Not every target
+	// supports it, which only disables the check of LoadSymbolsFromJSON.
+	version, err := s.readSymbolVersion(ctx)
+	if err != nil {
+		s.logf("session: loading symbol table without version: %s", err)
+	}
+	s.mu.Lock()
+	s.symbolVersion = version
+	s.mu.Unlock()
+
+	// If no symbols, return early
+	if info.SymbolCount == 0 {
+		return nil
+	}
+
+	s.mu.RLock()
+	limit := s.maxSymbolTable
+	s.mu.RUnlock()
+	if limit == 0 {
+		limit = defaultMaxSymbolTable
+	}
+	if info.SymbolLength > limit {
+		return fmt.Errorf("%w: %d bytes declared, limit %d", ErrSymbolTableTooLarge, info.SymbolLength, limit)
 	}
 
 	// Now upload the actual symbol table (0xF00B ADSIGRP_SYM_UPLOAD)
+	// with the length from the upload info if the PLC reported one
+	length := info.SymbolLength
+	if length == 0 {
+		length = maxReadLength
+		if length > limit {
+			length = limit
+		}
+	}
 	req := ams.NewReadRequest(
 		s.targetAddr,
 		s.senderAddr,
 		0xF00B, // ADSIGRP_SYM_UPLOAD
 		0x0,
-		0xFFFFFF, // Request large buffer for symbol table
+		length,
 	)
 
 	resp, err := s.client.Read(ctx, req)
@@ -140,72 +455,140 @@ func (s *Session) LoadSymbolTable(ctx context.Context) error {
 		return fmt.Errorf("failed to upload symbol table: %w", err)
 	}
 
-	// Parse the symbol table
-	offset := 0
-	symbolCount := 0
+	if uint64(len(resp.Data)) > uint64(limit) {
+		return fmt.Errorf("%w: got %d bytes, limit %d", ErrSymbolTableTooLarge, len(resp.Data), limit)
+	}
 
-	for offset < len(resp.Data) {
-		// Check if we have enough data for the header
-		if offset+30 > len(resp.Data) {
-			break
+	// Parse the symbol table
+	err = ParseSymbolTableStream(bytes.NewReader(resp.Data), func(info *SymbolInfo) error {
+		if !opts.keep(info) {
+			return nil
 		}
-
-		// Parse symbol entry structure
-		entryLength := binary.LittleEndian.Uint32(resp.Data[offset : offset+4])
-		if entryLength == 0 || offset+int(entryLength) > len(resp.Data) {
-			break
+		if opts.Visit != nil {
+			return opts.Visit(info)
 		}
+		s.registry.Set(info.Name, info)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to parse symbol table: %w", err)
+	}
+	return nil
+}
 
-		indexGroup := binary.LittleEndian.Uint32(resp.Data[offset+4 : offset+8])
-		indexOffset := binary.LittleEndian.Uint32(resp.Data[offset+8 : offset+12])
-		size := binary.LittleEndian.Uint32(resp.Data[offset+12 : offset+16])
-		nameLength := binary.LittleEndian.Uint16(resp.Data[offset+24 : offset+26])
-		typeLength := binary.LittleEndian.Uint16(resp.Data[offset+26 : offset+28])
-		commentLength := binary.LittleEndian.Uint16(resp.Data[offset+28 : offset+30])
+// ErrSymbolTableTooLarge is returned by LoadSymbolTable when the PLC
+// declares or returns a symbol table larger than the limit set with
+// SetMaxSymbolTableSize.
+var ErrSymbolTableTooLarge = errors.New("symbol table too large")
+
+// defaultMaxSymbolTable is the default limit of the symbol table size.
+const defaultMaxSymbolTable = 32 << 20
+
+// SetMaxSymbolTableSize sets the largest symbol table in bytes which
+// LoadSymbolTable uploads, e.g. to limit the memory used for untrusted
+// devices. A value of zero restores the default of 32 MiB.
+func (s *Session) SetMaxSymbolTableSize(n uint32) {
+	s.mu.Lock()
+	s.maxSymbolTable = n
+	s.mu.Unlock()
+}
 
-		// Extract name
-		nameStart := offset + 30
-		nameEnd := nameStart + int(nameLength)
-		if nameEnd > len(resp.Data) {
-			break
+// maxSymbolEntryLength limits the memory used for a corrupt entry length
+const maxSymbolEntryLength = 1 << 20
+
+// ParseSymbolTableStream parses the entries of an uploaded symbol table
+// one at a time and calls emit for each symbol. Only a single entry is
+// held in memory so that callers can filter or store the symbols of
+// huge tables incrementally. Parsing stops at the end of r, at an entry
+// with length zero, or when emit returns an error, which is returned.
+func ParseSymbolTableStream(r io.Reader, emit func(*SymbolInfo) error) error {
+	var entry []byte
+	for {
+		var length [4]byte
+		if _, err := io.ReadFull(r, length[:]); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
 		}
-		name := nullTerminatedString(resp.Data[nameStart:nameEnd])
-
-		// Extract type
-		typeStart := nameEnd + 1 // Skip null terminator
-		typeEnd := typeStart + int(typeLength)
-		if typeEnd > len(resp.Data) {
-			break
+		entryLength := binary.LittleEndian.Uint32(length[:])
+		if entryLength == 0 {
+			return nil
+		}
+		if entryLength < 30 || entryLength > maxSymbolEntryLength {
+			return fmt.Errorf("invalid symbol entry length %d", entryLength)
 		}
-		dataType := nullTerminatedString(resp.Data[typeStart:typeEnd])
 
-		// Extract comment (optional)
-		var comment string
-		if commentLength > 0 {
-			commentStart := typeEnd + 1
-			commentEnd := commentStart + int(commentLength)
-			if commentEnd <= len(resp.Data) {
-				comment = nullTerminatedString(resp.Data[commentStart:commentEnd])
+		if cap(entry) < int(entryLength) {
+			entry = make([]byte, entryLength)
+		}
+		entry = entry[:entryLength]
+		copy(entry, length[:])
+		if _, err := io.ReadFull(r, entry[4:]); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
 			}
+			return err
 		}
 
-		// Store in registry
-		info := &SymbolInfo{
-			Name:        name,
-			DataType:    dataType,
-			Size:        size,
-			IndexGroup:  indexGroup,
-			IndexOffset: indexOffset,
-			Comment:     comment,
+		info, err := parseSymbolEntry(entry)
+		if err != nil {
+			return err
+		}
+		if err := emit(info); err != nil {
+			return err
 		}
-		s.registry.Set(name, info)
-		symbolCount++
+	}
+}
 
-		// Move to next entry
-		offset += int(entryLength)
+// parseSymbolEntry parses a single entry of the symbol table
+func parseSymbolEntry(entry []byte) (*SymbolInfo, error) {
+	indexGroup := binary.LittleEndian.Uint32(entry[4:8])
+	indexOffset := binary.LittleEndian.Uint32(entry[8:12])
+	size := binary.LittleEndian.Uint32(entry[12:16])
+	flags := binary.LittleEndian.Uint16(entry[20:22])
+	nameLength := binary.LittleEndian.Uint16(entry[24:26])
+	typeLength := binary.LittleEndian.Uint16(entry[26:28])
+	commentLength := binary.LittleEndian.Uint16(entry[28:30])
+
+	// Extract name
+	nameStart := 30
+	nameEnd := nameStart + int(nameLength)
+	if nameEnd > len(entry) {
+		return nil, fmt.Errorf("truncated symbol entry")
 	}
+	name := nullTerminatedString(entry[nameStart:nameEnd])
 
-	return nil
+	// Extract type
+	typeStart := nameEnd + 1 // Skip null terminator
+	typeEnd := typeStart + int(typeLength)
+	if typeEnd > len(entry) {
+		return nil, fmt.Errorf("truncated symbol entry %s", name)
+	}
+	dataType := nullTerminatedString(entry[typeStart:typeEnd])
+
+	// Extract comment (optional)
+	var comment string
+	commentStart := typeEnd + 1
+	commentEnd := commentStart + int(commentLength)
+	if commentLength > 0 && commentEnd <= len(entry) {
+		comment = nullTerminatedString(entry[commentStart:commentEnd])
+	}
+
+	// Extract the pragma attributes which follow the comment
+	attributes := parseSymbolAttributes(entry, commentEnd+1)
+
+	return &SymbolInfo{
+		Name:        name,
+		DataType:    dataType,
+		Size:        size,
+		IndexGroup:  indexGroup,
+		IndexOffset: indexOffset,
+		Comment:     comment,
+		Attributes:  attributes,
+		Flags:       flags,
+		details:     true,
+	}, nil
 }
 
 // GetSymbol retrieves symbol information, using cache if available
@@ -214,6 +597,15 @@ func (s *Session) GetSymbol(ctx context.Context, name string) (*SymbolInfo, erro
 	if info, ok := s.registry.Get(name); ok {
 		return info, nil
 	}
+	if s.parent != nil {
+		if info, ok := s.parent.registry.Get(name); ok {
+			// the handles of the parent are not ours
+			own := *info
+			own.Handle = 0
+			s.registry.Set(name, &own)
+			return &own, nil
+		}
+	}
 
 	// Not in cache, fetch from PLC
 	symbol, err := s.client.GetSymbol(ctx, s.targetAddr, s.senderAddr, name)
@@ -223,20 +615,109 @@ func (s *Session) GetSymbol(ctx context.Context, name string) (*SymbolInfo, erro
 
 	// Create info and cache it
 	info := &SymbolInfo{
-		Name:     symbol.Name,
-		DataType: symbol.DataType,
-		Size:     symbol.Size,
-		Fields:   symbol.Fields,
+		Name:        symbol.Name,
+		DataType:    symbol.DataType,
+		Size:        symbol.Size,
+		IndexGroup:  symbol.IndexGroup,
+		IndexOffset: symbol.IndexOffset,
+		Fields:      symbol.Fields,
 	}
 	s.registry.Set(name, info)
 
 	return info, nil
 }
 
+// GetSymbolComment returns the comment of a symbol. Unless the symbol
+// table is loaded, the comment is requested with the symbol info of
+// the single symbol and cached.
+func (s *Session) GetSymbolComment(ctx context.Context, name string) (string, error) {
+	info, err := s.symbolDetails(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	return info.Comment, nil
+}
+
+// GetSymbolAttributes is like GetSymbolComment for the pragma attributes
+// of a symbol.
+func (s *Session) GetSymbolAttributes(ctx context.Context, name string) (map[string]string, error) {
+	info, err := s.symbolDetails(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return info.Attributes, nil
+}
+
+// symbolDetails returns the symbol info with the comment, attributes
+// and flags, using cache if available.
+func (s *Session) symbolDetails(ctx context.Context, name string) (*SymbolInfo, error) {
+	cached, ok := s.registry.Get(name)
+	if ok && cached.details {
+		return cached, nil
+	}
+
+	info, err := readSymbolInfo(ctx, s.client, s.targetAddr, s.senderAddr, name)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		// keep the handle and the fields of the cached symbol
+		updated := *cached
+		updated.Comment = info.Comment
+		updated.Attributes = info.Attributes
+		updated.Flags = info.Flags
+		updated.details = true
+		info = &updated
+	}
+	s.registry.Set(name, info)
+	return info, nil
+}
+
+// dropStaleHandles removes the cached handles if the client connected
+// again since they were requested. The PLC released them when the old
+// connection was lost.
+func (s *Session) dropStaleHandles() {
+	conn := clientConnections(s.client)
+	s.mu.Lock()
+	stale := s.connection != conn
+	s.connection = conn
+	s.mu.Unlock()
+	if !stale {
+		return
+	}
+
+	for name, info := range s.registry.GetAll() {
+		if info.Handle == 0 {
+			continue
+		}
+		dropped := *info
+		dropped.Handle = 0
+		s.registry.Set(name, &dropped)
+		s.handles.remove(name)
+	}
+}
+
 // getOrCreateHandle gets a symbol handle, using cache if available
 func (s *Session) getOrCreateHandle(ctx context.Context, name string) (uint32, error) {
+	s.dropStaleHandles()
+
 	// Check if we have it in registry with handle
 	if info, ok := s.registry.Get(name); ok && info.Handle != 0 {
+		s.handles.touch(name)
+		return info.Handle, nil
+	}
+
+	// Concurrent callers for the same symbol share one request
+	return s.handleGroup.do(name, func() (uint32, error) {
+		return s.createHandle(ctx, name)
+	})
+}
+
+// createHandle gets a new symbol handle from the PLC and caches it
+func (s *Session) createHandle(ctx context.Context, name string) (uint32, error) {
+	// A previous request may have completed in the meantime
+	if info, ok := s.registry.Get(name); ok && info.Handle != 0 {
+		s.handles.touch(name)
 		return info.Handle, nil
 	}
 
@@ -246,10 +727,17 @@ func (s *Session) getOrCreateHandle(ctx context.Context, name string) (uint32, e
 		return 0, err
 	}
 
-	// Update cache
+	s.storeHandle(ctx, name, handle)
+	return handle, nil
+}
+
+// storeHandle caches a new handle of a symbol
+func (s *Session) storeHandle(ctx context.Context, name string, handle uint32) {
 	if info, ok := s.registry.Get(name); ok {
-		info.Handle = handle
-		s.registry.Set(name, info)
+		// copy since concurrent readers may hold info
+		updated := *info
+		updated.Handle = handle
+		s.registry.Set(name, &updated)
 	} else {
 		// Create minimal info with handle
 		s.registry.Set(name, &SymbolInfo{
@@ -258,7 +746,42 @@ func (s *Session) getOrCreateHandle(ctx context.Context, name string) (uint32, e
 		})
 	}
 
-	return handle, nil
+	// Release the least recently used handles above the limit
+	for _, evicted := range s.handles.add(name) {
+		s.evictHandle(ctx, evicted)
+	}
+}
+
+// evictHandle removes the cached handle of a symbol and releases it
+func (s *Session) evictHandle(ctx context.Context, name string) {
+	info, ok := s.registry.Get(name)
+	if !ok || info.Handle == 0 {
+		return
+	}
+	evicted := *info
+	evicted.Handle = 0
+	s.registry.Set(name, &evicted)
+
+	if err := s.releaseHandle(ctx, info.Handle); err != nil {
+		s.logf("session: failed to release handle of %s: %s", name, err)
+	}
+}
+
+// logf logs with the logger of the client.
+func (s *Session) logf(format string, args ...interface{}) {
+	if c, ok := s.client.(*Client); ok {
+		c.logf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
+
+// SetMaxHandles limits the number of symbol handles the session keeps
+// open on the PLC. When a new handle exceeds the limit the least recently
+// used handle is released. A value of zero or less removes the limit.
+// The limit is applied when the next handle is created.
+func (s *Session) SetMaxHandles(n int) {
+	s.handles.setMax(n)
 }
 
 // Read reads a variable value from the PLC (cached handle)
@@ -269,52 +792,320 @@ func (s *Session) Read(ctx context.Context, name string) ([]byte, *SymbolInfo, e
 		return nil, nil, fmt.Errorf("failed to get symbol info: %w", err)
 	}
 
-	// Get or create handle
+	// Read the value
+	var resp *ams.ReadResponse
+	err = s.withHandle(ctx, name, func(handle uint32) error {
+		req := ams.NewReadRequest(
+			s.targetAddr,
+			s.senderAddr,
+			0xF005, // ADSIGRP_SYM_VALBYHND
+			handle,
+			info.Size,
+		)
+		resp, err = s.client.Read(ctx, req)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := checkReadLength(resp.Data, info.Size); err != nil {
+		return nil, nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+
+	return resp.Data, info, nil
+}
+
+// withHandle calls fn with the handle of the symbol. If the handle is
+// invalid, e.g. because another goroutine evicted it while fn was
+// using it, fn is called once more with a new handle.
+func (s *Session) withHandle(ctx context.Context, name string, fn func(handle uint32) error) error {
 	handle, err := s.getOrCreateHandle(ctx, name)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to get handle: %w", err)
+		return fmt.Errorf("failed to get handle: %w", err)
+	}
+	err = fn(handle)
+	if !errors.Is(err, ams.ADSError(ams.DeviceInvalidHandle)) {
+		return err
+	}
+
+	s.dropHandle(name, handle)
+	handle, err = s.getOrCreateHandle(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to get handle: %w", err)
+	}
+	return fn(handle)
+}
+
+// dropHandle removes an invalid handle of a symbol from the cache
+// unless it was replaced already. The PLC does not know the handle,
+// so it is not released.
+func (s *Session) dropHandle(name string, handle uint32) {
+	info, ok := s.registry.Get(name)
+	if !ok || info.Handle != handle {
+		return
+	}
+	dropped := *info
+	dropped.Handle = 0
+	s.registry.Set(name, &dropped)
+	s.handles.remove(name)
+	if c, ok := s.client.(*Client); ok {
+		c.releaseCachedHandle(s.targetAddr, handle)
+	}
+}
+
+// ErrEmptyRead is returned when the PLC reports success for a read of
+// a variable but returns no data.
+var ErrEmptyRead = errors.New("empty read")
+
+// ErrShortRead is returned when the PLC returns fewer bytes than the
+// size of the variable.
+var ErrShortRead = errors.New("short read")
+
+// checkReadLength checks that data has the size of the variable
+func checkReadLength(data []byte, size uint32) error {
+	switch {
+	case uint32(len(data)) == size:
+		return nil
+	case len(data) == 0:
+		return fmt.Errorf("%w: got 0 bytes, want %d", ErrEmptyRead, size)
+	case uint32(len(data)) < size:
+		return fmt.Errorf("%w: got %d bytes, want %d", ErrShortRead, len(data), size)
+	default:
+		return fmt.Errorf("got %d bytes, want %d", len(data), size)
+	}
+}
+
+// ReadByName reads a variable by its name in a single request without
+// acquiring a handle. This is faster than Read for one-shot reads of
+// variables which are not read again. If the PLC does not support
+// reading by name it falls back to Read and uses Read from then on.
+func (s *Session) ReadByName(ctx context.Context, name string) ([]byte, error) {
+	s.mu.RLock()
+	unsupported := s.noValueByName
+	s.mu.RUnlock()
+
+	if !unsupported {
+		// the PLC returns the actual size of the variable
+		length := uint32(0xFFFF)
+		if info, ok := s.registry.Get(name); ok && info.Size > 0 {
+			length = info.Size
+		}
+
+		req := ams.NewReadWriteRequest(
+			s.targetAddr,
+			s.senderAddr,
+			ams.IdxReadSymValueByName, // ADSIGRP_SYM_VALBYNAME
+			0,
+			length,
+			append([]byte(name), 0),
+		)
+		resp, err := s.client.ReadWrite(ctx, req)
+		var adsErr ams.ADSError
+		switch {
+		case err == nil:
+			return resp.Data, nil
+		case !errors.As(err, &adsErr), adsErr == ams.DeviceSymbolNotFound:
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		case adsErr == ams.DeviceServiceNotSupported:
+			s.mu.Lock()
+			s.noValueByName = true
+			s.mu.Unlock()
+		}
+	}
+
+	data, _, err := s.Read(ctx, name)
+	return data, err
+}
+
+// ReadPartial reads length bytes at offset within a variable. The range
+// is read directly from the index group and offset of the symbol. If
+// they are unknown the whole variable is read and the range is returned.
+func (s *Session) ReadPartial(ctx context.Context, name string, offset, length uint32) ([]byte, error) {
+	info, err := s.GetSymbol(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get symbol info: %w", err)
+	}
+	if uint64(offset)+uint64(length) > uint64(info.Size) {
+		return nil, fmt.Errorf("range %d+%d exceeds size %d of %s", offset, length, info.Size, name)
+	}
+
+	if info.IndexGroup == 0 {
+		data, _, err := s.Read(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if int(offset+length) > len(data) {
+			return nil, fmt.Errorf("failed to read %s: response too short", name)
+		}
+		return data[offset : offset+length], nil
 	}
 
-	// Read the value
 	req := ams.NewReadRequest(
 		s.targetAddr,
 		s.senderAddr,
-		0xF005, // ADSIGRP_SYM_VALBYHND
-		handle,
-		info.Size,
+		info.IndexGroup,
+		info.IndexOffset+offset,
+		length,
 	)
 	resp, err := s.client.Read(ctx, req)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to read %s: %w", name, err)
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	if err := checkReadLength(resp.Data, length); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
 	}
+	return resp.Data, nil
+}
 
-	return resp.Data, info, nil
+// maxReadLength is the largest length of a single read request
+// that ADS routers accept.
+const maxReadLength = 0xFFFFFF
+
+// defaultReadChunkSize is the default chunk size of ReadLarge.
+const defaultReadChunkSize = 1 << 20
+
+// SetReadChunkSize sets the number of bytes ReadLarge reads per request.
+// Variables up to this size are read with a single request. A value of
+// zero restores the default of 1 MiB. Values above the 16 MiB request
+// limit of the router are capped.
+func (s *Session) SetReadChunkSize(n uint32) {
+	if n > maxReadLength {
+		n = maxReadLength
+	}
+	s.mu.Lock()
+	s.readChunkSize = n
+	s.mu.Unlock()
 }
 
-// Write writes a variable value to the PLC (cached handle)
-func (s *Session) Write(ctx context.Context, name string, data []byte) error {
-	// Get or create handle
-	handle, err := s.getOrCreateHandle(ctx, name)
+// ReadLarge reads a variable which may be too large for a single request,
+// e.g. a trace buffer. Variables larger than the chunk size are read in
+// chunks from the index group and offset of the symbol and concatenated.
+// It returns an error if the PLC returns less data than the symbol size.
+func (s *Session) ReadLarge(ctx context.Context, name string) ([]byte, *SymbolInfo, error) {
+	info, err := s.GetSymbol(ctx, name)
 	if err != nil {
-		return fmt.Errorf("failed to get handle: %w", err)
+		return nil, nil, fmt.Errorf("failed to get symbol info: %w", err)
 	}
 
-	// Write the value
-	req := ams.NewWriteRequest(
-		s.targetAddr,
-		s.senderAddr,
-		0xF005, // ADSIGRP_SYM_VALBYHND
-		handle,
-		data,
-	)
-	_, err = s.client.Write(ctx, req)
+	s.mu.RLock()
+	chunk := s.readChunkSize
+	s.mu.RUnlock()
+	if chunk == 0 {
+		chunk = defaultReadChunkSize
+	}
+
+	if info.Size <= chunk {
+		data, info, err := s.Read(ctx, name)
+		if err != nil {
+			return nil, nil, err
+		}
+		if uint32(len(data)) < info.Size {
+			return nil, nil, fmt.Errorf("failed to read %s: got %d of %d bytes", name, len(data), info.Size)
+		}
+		return data, info, nil
+	}
+
+	// handles cannot be read at an offset
+	if info.IndexGroup == 0 {
+		return nil, nil, fmt.Errorf("failed to read %s: index group unknown, cannot read %d bytes in chunks", name, info.Size)
+	}
+	size := uint64(info.Size)
+	if uint64(info.IndexOffset)+size > math.MaxUint32+1 {
+		return nil, nil, fmt.Errorf("failed to read %s: %d bytes at offset %d exceed the index offset range", name, info.Size, info.IndexOffset)
+	}
+
+	// the size comes from the PLC, so the buffer grows as data arrives
+	var data []byte
+	for offset := uint64(0); offset < size; offset += uint64(chunk) {
+		n := uint32(size - offset)
+		if n > chunk {
+			n = chunk
+		}
+		req := ams.NewReadRequest(
+			s.targetAddr,
+			s.senderAddr,
+			info.IndexGroup,
+			info.IndexOffset+uint32(offset),
+			n,
+		)
+		resp, err := s.client.Read(ctx, req)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read %s at offset %d: %w", name, offset, err)
+		}
+		if uint32(len(resp.Data)) != n {
+			return nil, nil, fmt.Errorf("failed to read %s at offset %d: got %d of %d bytes", name, offset, len(resp.Data), n)
+		}
+		data = append(data, resp.Data...)
+	}
+	return data, info, nil
+}
+
+// ReadStruct reads a struct variable from the PLC and returns
+// its fields with populated values
+func (s *Session) ReadStruct(ctx context.Context, name string) ([]StructField, error) {
+	data, info, err := s.Read(ctx, name)
 	if err != nil {
-		return fmt.Errorf("failed to write %s: %w", name, err)
+		return nil, err
+	}
+	return s.decodeStruct(ctx, info.DataType, data)
+}
+
+// decodeStruct decodes the raw data of a struct into a copy of its fields
+func (s *Session) decodeStruct(ctx context.Context, dataType string, data []byte) ([]StructField, error) {
+	fields, err := s.resolveType(ctx, dataType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get data type info: %w", err)
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("%s is not a struct", dataType)
 	}
 
+	fields = append([]StructField(nil), fields...)
+	if err := s.PopulateFieldValues(ctx, fields, data); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// ErrReadOnly is returned when writing a symbol which the PLC marked
+// read-only.
+var ErrReadOnly = errors.New("symbol is read-only")
+
+// checkWritable returns ErrReadOnly if the cached info of the symbol
+// is read-only. Symbols which are not cached are not looked up.
+func (s *Session) checkWritable(name string) error {
+	if info, ok := s.registry.Get(name); ok && info.IsReadOnly() {
+		return fmt.Errorf("failed to write %s: %w", name, ErrReadOnly)
+	}
 	return nil
 }
 
+// Write writes a variable value to the PLC (cached handle). It returns
+// ErrReadOnly without a request if the cached symbol is read-only.
+func (s *Session) Write(ctx context.Context, name string, data []byte) error {
+	if err := s.checkWritable(name); err != nil {
+		return err
+	}
+
+	// Write the value
+	return s.withHandle(ctx, name, func(handle uint32) error {
+		req := ams.NewWriteRequest(
+			s.targetAddr,
+			s.senderAddr,
+			0xF005, // ADSIGRP_SYM_VALBYHND
+			handle,
+			data,
+		)
+		if _, err := s.client.Write(ctx, req); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+		return nil
+	})
+}
+
 // WriteNestedField writes a value to a nested field within a struct
 func (s *Session) WriteNestedField(ctx context.Context, rootVar string, fieldPath []string, fieldData []byte) error {
 	// Get symbol info
@@ -322,6 +1113,9 @@ func (s *Session) WriteNestedField(ctx context.Context, rootVar string, fieldPat
 	if err != nil {
 		return fmt.Errorf("failed to get symbol info: %w", err)
 	}
+	if err := s.checkWritable(rootVar); err != nil {
+		return err
+	}
 
 	// Get or create handle
 	handle, err := s.getOrCreateHandle(ctx, rootVar)
@@ -344,7 +1138,7 @@ func (s *Session) WriteNestedField(ctx context.Context, rootVar string, fieldPat
 
 	// Load fields if needed
 	if len(info.Fields) == 0 {
-		fields, err := s.client.GetDataTypeInfo(ctx, s.targetAddr, s.senderAddr, info.DataType)
+		fields, err := s.resolveType(ctx, info.DataType)
 		if err != nil {
 			return fmt.Errorf("failed to get data type info: %w", err)
 		}
@@ -353,9 +1147,9 @@ func (s *Session) WriteNestedField(ctx context.Context, rootVar string, fieldPat
 	}
 
 	// Find field and update data
-	field, absoluteOffset, err := FindFieldByPathWithOffset(info.Fields, fieldPath, 0)
+	field, absoluteOffset, err := findField(ctx, info.Fields, fieldPath, s.resolveType)
 	if err != nil {
-		return fmt.Errorf("field not found: %w", err)
+		return err
 	}
 
 	fieldEnd := int(absoluteOffset) + int(field.Size)
@@ -376,8 +1170,171 @@ func (s *Session) WriteNestedField(ctx context.Context, rootVar string, fieldPat
 	return err
 }
 
-// ReleaseHandle releases a symbol handle
+// WriteFields writes several nested fields of a struct with a single
+// read-modify-write. The keys of updates are dot separated field paths
+// like "Pos.X" and the data must have the size of the field. All paths
+// are checked before the struct is read so that nothing is written if
+// one of them is invalid.
+func (s *Session) WriteFields(ctx context.Context, rootVar string, updates map[string][]byte) error {
+	info, err := s.GetSymbol(ctx, rootVar)
+	if err != nil {
+		return fmt.Errorf("failed to get symbol info: %w", err)
+	}
+
+	// sort the paths for deterministic errors
+	paths := make([]string, 0, len(updates))
+	for p := range updates {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	offsets := make([]uint32, len(paths))
+	for i, p := range paths {
+		field, offset, err := s.resolveFieldPath(ctx, info.DataType, strings.Split(p, "."))
+		if err != nil {
+			return fmt.Errorf("failed to find field %s: %w", p, err)
+		}
+		if len(updates[p]) != int(field.Size) {
+			return fmt.Errorf("field data size mismatch for %s: got %d bytes, want %d", p, len(updates[p]), field.Size)
+		}
+		if offset+field.Size > info.Size {
+			return fmt.Errorf("field %s exceeds %s", p, rootVar)
+		}
+		offsets[i] = offset
+	}
+
+	data, _, err := s.Read(ctx, rootVar)
+	if err != nil {
+		return err
+	}
+	if len(data) < int(info.Size) {
+		return fmt.Errorf("short read of %s: got %d bytes, want %d", rootVar, len(data), info.Size)
+	}
+	for i, p := range paths {
+		copy(data[offsets[i]:], updates[p])
+	}
+	return s.Write(ctx, rootVar, data)
+}
+
+// WriteFieldDirect writes a value to a nested field within a struct without
+// reading and writing the whole struct. The data is written to the index
+// group and offset of the symbol at the offset of the field. Use
+// WriteNestedField for servers which only allow writing whole symbols.
+func (s *Session) WriteFieldDirect(ctx context.Context, rootVar string, fieldPath []string, data []byte) error {
+	info, err := s.GetSymbol(ctx, rootVar)
+	if err != nil {
+		return fmt.Errorf("failed to get symbol info: %w", err)
+	}
+	if info.IndexGroup == 0 {
+		return fmt.Errorf("index group of %s unknown", rootVar)
+	}
+	if err := s.checkWritable(rootVar); err != nil {
+		return err
+	}
+
+	field, offset, err := s.resolveFieldPath(ctx, info.DataType, fieldPath)
+	if err != nil {
+		return err
+	}
+	if len(data) != int(field.Size) {
+		return fmt.Errorf("field data size mismatch: got %d want %d", len(data), field.Size)
+	}
+	if offset+field.Size > info.Size {
+		return fmt.Errorf("field %s exceeds size %d of %s", strings.Join(fieldPath, "."), info.Size, rootVar)
+	}
+
+	req := ams.NewWriteRequest(
+		s.targetAddr,
+		s.senderAddr,
+		info.IndexGroup,
+		info.IndexOffset+offset,
+		data,
+	)
+	if _, err := s.client.Write(ctx, req); err != nil {
+		return fmt.Errorf("failed to write %s: %w", rootVar, err)
+	}
+	return nil
+}
+
+// resolveFieldPath returns the field at the path within a data type and
+// its offset from the start of the data type. The data types along the
+// path are resolved as needed.
+func (s *Session) resolveFieldPath(ctx context.Context, dataType string, fieldPath []string) (*StructField, uint32, error) {
+	if len(fieldPath) == 0 {
+		return nil, 0, fmt.Errorf("empty field path")
+	}
+
+	fields, err := s.resolveType(ctx, dataType)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get data type info: %w", err)
+	}
+	return findField(ctx, fields, fieldPath, s.resolveType)
+}
+
+// FindFieldByPath finds a field by path like the FindFieldByPath function
+// and returns its offset within fields. Nested data types which are not
+// loaded are resolved with the data type cache of the session.
+func (s *Session) FindFieldByPath(ctx context.Context, fields []StructField, path []string) (*StructField, uint32, error) {
+	return findField(ctx, fields, path, s.resolveType)
+}
+
+// FieldOffset returns the offset from the start of rootVar, the size and
+// the data type of the nested field at fieldPath, e.g. for reads and writes
+// at the index group and offset of rootVar. The data types on the path
+// are loaded as needed and kept in the data type cache of the session.
+func (s *Session) FieldOffset(ctx context.Context, rootVar string, fieldPath []string) (offset uint32, size uint32, dataType string, err error) {
+	info, err := s.GetSymbol(ctx, rootVar)
+	if err != nil {
+		return 0, 0, "", fmt.Errorf("failed to get symbol info: %w", err)
+	}
+	field, offset, err := s.resolveFieldPath(ctx, info.DataType, fieldPath)
+	if err != nil {
+		return 0, 0, "", err
+	}
+	return offset, field.Size, field.DataType, nil
+}
+
+// FindNestedField finds a field by path like the FindNestedField function
+// and returns its data within parentData. Nested data types which are not
+// loaded are resolved with the data type cache of the session.
+func (s *Session) FindNestedField(ctx context.Context, fields []StructField, fieldPath []string, parentData []byte) (*StructField, []byte, error) {
+	return findNestedField(ctx, fields, fieldPath, parentData, s.resolveType)
+}
+
+// PopulateFieldValues recursively populates field values from raw data
+// using the cached data types of the session
+func (s *Session) PopulateFieldValues(ctx context.Context, fields []StructField, data []byte) error {
+	return populateFieldValues(ctx, fields, data, s.resolveType)
+}
+
+// ReleaseHandle releases a symbol handle and removes it from the
+// cached symbols. With the CacheHandles option of the client the
+// handle is only released on the PLC when no other session holds it.
 func (s *Session) ReleaseHandle(ctx context.Context, handle uint32) error {
+	if err := s.releaseHandle(ctx, handle); err != nil {
+		return err
+	}
+
+	// The handle is no longer valid
+	for name, info := range s.registry.GetAll() {
+		if info.Handle != handle {
+			continue
+		}
+		released := *info
+		released.Handle = 0
+		s.registry.Set(name, &released)
+		s.handles.remove(name)
+	}
+	return nil
+}
+
+// releaseHandle releases a symbol handle on the PLC unless another
+// session still holds it from the handle cache of the client.
+func (s *Session) releaseHandle(ctx context.Context, handle uint32) error {
+	if c, ok := s.client.(*Client); ok && !c.releaseCachedHandle(s.targetAddr, handle) {
+		return nil
+	}
+
 	// Use ADSIGRP_SYM_RELEASEHND (0xF006)
 	data := make([]byte, 4)
 	binary.LittleEndian.PutUint32(data, handle)
@@ -389,27 +1346,53 @@ func (s *Session) ReleaseHandle(ctx context.Context, handle uint32) error {
 		0,
 		data,
 	)
-	_, err := s.client.Write(ctx, req)
-	return err
+	if _, err := s.client.Write(ctx, req); err != nil {
+		return fmt.Errorf("failed to release handle %d: %w", handle, err)
+	}
+	return nil
 }
 
-// Close releases all cached handles
+// Close releases all cached handles. Calling it again only releases the
+// handles created since.
 func (s *Session) Close(ctx context.Context) error {
-	allSymbols := s.registry.GetAll()
+	s.closeMu.Lock()
+	defer s.closeMu.Unlock()
 
+	// a single pass since ReleaseHandle looks up the symbols of the handle
 	var firstErr error
-	for _, info := range allSymbols {
-		if info.Handle != 0 {
-			if err := s.ReleaseHandle(ctx, info.Handle); err != nil && firstErr == nil {
+	released := make(map[uint32]error)
+	for name, info := range s.registry.GetAll() {
+		if info.Handle == 0 {
+			continue
+		}
+		err, ok := released[info.Handle]
+		if !ok {
+			err = s.releaseHandle(ctx, info.Handle)
+			released[info.Handle] = err
+			if err != nil && firstErr == nil {
 				firstErr = err
 			}
 		}
+		if err != nil {
+			continue
+		}
+		closed := *info
+		closed.Handle = 0
+		s.registry.Set(name, &closed)
+		s.handles.remove(name)
 	}
 
 	return firstErr
 }
 
+// symbolFile is the JSON format of ExportSymbolsToJSON
+type symbolFile struct {
+	Version uint8         `json:"version"`
+	Symbols []*SymbolInfo `json:"symbols"`
+}
+
 // ExportSymbolsToJSON exports the symbol registry to a JSON file
+// together with the symbol version of the PLC
 func (s *Session) ExportSymbolsToJSON(filename string) error {
 	allSymbols := s.registry.GetAll()
 
@@ -418,8 +1401,15 @@ func (s *Session) ExportSymbolsToJSON(filename string) error {
 	for _, info := range allSymbols {
 		symbols = append(symbols, info)
 	}
+	sort.Slice(symbols, func(i, j int) bool {
+		return symbols[i].Name < symbols[j].Name
+	})
+
+	s.mu.RLock()
+	version := s.symbolVersion
+	s.mu.RUnlock()
 
-	data, err := json.MarshalIndent(symbols, "", "  ")
+	data, err := json.MarshalIndent(symbolFile{Version: version, Symbols: symbols}, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal symbols: %w", err)
 	}
@@ -431,6 +1421,65 @@ func (s *Session) ExportSymbolsToJSON(filename string) error {
 	return nil
 }
 
+// LoadSymbolsFromJSON loads the symbol registry from a file written by
+// ExportSymbolsToJSON. It returns ErrSymbolVersionChanged and leaves the
+// registry unchanged when the symbol version of the PLC differs from the
+// one in the file. The symbol table must then be loaded with
+// LoadSymbolTable. Files of older versions, which are a plain array of
+// symbols, and targets without a symbol version are loaded without the
+// check. Handles are not restored since they are only valid for the
+// connection they were created on.
+func (s *Session) LoadSymbolsFromJSON(filename string) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+
+	// older exports are a plain array of symbols without a version
+	var f symbolFile
+	legacy := bytes.HasPrefix(bytes.TrimSpace(data), []byte("["))
+	if legacy {
+		err = json.Unmarshal(data, &f.Symbols)
+	} else {
+		err = json.Unmarshal(data, &f)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal symbols: %w", err)
+	}
+
+	var version uint8
+	if !legacy {
+		version, err = s.readSymbolVersion(context.Background())
+		switch {
+		case errors.Is(err, ams.ADSError(ams.DeviceServiceNotSupported)):
+			s.logf("session: loading symbols without version check: %s", err)
+		case err != nil:
+			return err
+		case version != f.Version:
+			return fmt.Errorf("%w: got %d want %d", ErrSymbolVersionChanged, version, f.Version)
+		}
+	}
+
+	for _, info := range f.Symbols {
+		if info == nil {
+			continue
+		}
+		info.Handle = 0
+		s.registry.Set(info.Name, info)
+	}
+
+	s.mu.Lock()
+	s.symbolVersion = version
+	s.mu.Unlock()
+	return nil
+}
+
+// FindSymbols returns the cached symbols matching the pattern.
+// See SymbolRegistry.Find for the pattern syntax.
+func (s *Session) FindSymbols(pattern string) []*SymbolInfo {
+	return s.registry.Find(pattern)
+}
+
 // GetSymbolCount returns the number of cached symbols
 func (s *Session) GetSymbolCount() int {
 	return s.registry.Count()
@@ -485,6 +1534,62 @@ func (s *Session) RemoveSymbolNotification(ctx context.Context, handle uint32) e
 	return s.notificationMgr.Unsubscribe(ctx, handle)
 }
 
+// Flags of an ADS symbol entry
+const (
+	symbolFlagPersistent       = 0x0001 // ADSSYMBOLFLAG_PERSISTENT
+	symbolFlagReferenceTo      = 0x0004 // ADSSYMBOLFLAG_REFERENCETO
+	symbolFlagTypeGUID         = 0x0008 // ADSSYMBOLFLAG_TYPEGUID
+	symbolFlagTComInterfacePtr = 0x0010 // ADSSYMBOLFLAG_TCCOMIFACEPTR
+	symbolFlagReadOnly         = 0x0020 // ADSSYMBOLFLAG_READONLY
+	symbolFlagAttributes       = 0x1000 // ADSSYMBOLFLAG_ATTRIBUTES
+	symbolFlagStatic           = 0x2000 // ADSSYMBOLFLAG_STATIC
+)
+
+// parseSymbolAttributes parses the TwinCAT pragma attributes, e.g.
+// {attribute 'DisplayMinValue' := '0'}, of a symbol entry. pos is the
+// position after the comment. Attributes without a value map to "".
+// It returns nil if the entry has no attributes.
+func parseSymbolAttributes(entry []byte, pos int) map[string]string {
+	if len(entry) < 24 {
+		return nil
+	}
+	flags := binary.LittleEndian.Uint16(entry[20:22])
+	arrayDim := binary.LittleEndian.Uint16(entry[22:24])
+	if flags&symbolFlagAttributes == 0 {
+		return nil
+	}
+
+	// skip the array bounds and the type guid
+	pos += int(arrayDim) * 8
+	if flags&symbolFlagTypeGUID != 0 {
+		pos += 16
+	}
+	if pos+2 > len(entry) {
+		return nil
+	}
+	count := int(binary.LittleEndian.Uint16(entry[pos:]))
+	pos += 2
+
+	// each attribute is [nameLength][valueLength][name\0][value\0]
+	attributes := make(map[string]string, count)
+	for i := 0; i < count; i++ {
+		if pos+2 > len(entry) {
+			break
+		}
+		nameLength, valueLength := int(entry[pos]), int(entry[pos+1])
+		nameStart := pos + 2
+		valueStart := nameStart + nameLength + 1
+		valueEnd := valueStart + valueLength
+		if valueEnd > len(entry) {
+			break
+		}
+		name := nullTerminatedString(entry[nameStart : nameStart+nameLength])
+		attributes[name] = nullTerminatedString(entry[valueStart:valueEnd])
+		pos = valueEnd + 1
+	}
+	return attributes
+}
+
 // nullTerminatedString extracts a null-terminated string from a byte slice
 func nullTerminatedString(data []byte) string {
 	for i, b := range data {
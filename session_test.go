@@ -0,0 +1,1066 @@
+package goads
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/iotest"
+
+	"github.com/mrpasztoradam/goads/ams"
+	"github.com/mrpasztoradam/goads/goadstest"
+	"github.com/pascaldekloe/goe/verify"
+)
+
+func TestLoadSymbolsFromJSON(t *testing.T) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+
+	var version uint8 = 3
+	srv.HandleRead(0xF008, func(req *ams.ReadRequest) ([]byte, uint32) {
+		return []byte{version}, ams.NoError
+	})
+
+	c := &Client{Addr: srv.Addr()}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	sender := ams.MustParseAddr("10.0.0.1.1.1:32000")
+	filename := filepath.Join(t.TempDir(), "symbols.json")
+
+	s := c.NewSession(srv.AMSAddr(), sender)
+	s.symbolVersion = version
+	s.registry.Set("MAIN.nValue", &SymbolInfo{
+		Name:        "MAIN.nValue",
+		DataType:    "INT",
+		Size:        2,
+		IndexGroup:  0x4040,
+		IndexOffset: 0x10,
+		Handle:      7,
+	})
+	if err := s.ExportSymbolsToJSON(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("same version", func(t *testing.T) {
+		s := c.NewSession(srv.AMSAddr(), sender)
+		if err := s.LoadSymbolsFromJSON(filename); err != nil {
+			t.Fatal(err)
+		}
+		info, _ := s.registry.Get("MAIN.nValue")
+		verify.Values(t, "symbol", info, &SymbolInfo{
+			Name:        "MAIN.nValue",
+			DataType:    "INT",
+			Size:        2,
+			IndexGroup:  0x4040,
+			IndexOffset: 0x10,
+		})
+	})
+
+	t.Run("changed version", func(t *testing.T) {
+		version = 4
+		s := c.NewSession(srv.AMSAddr(), sender)
+		err := s.LoadSymbolsFromJSON(filename)
+		if !errors.Is(err, ErrSymbolVersionChanged) {
+			t.Fatalf("got error %v want %v", err, ErrSymbolVersionChanged)
+		}
+		verify.Values(t, "count", s.GetSymbolCount(), 0)
+	})
+
+	t.Run("array without version", func(t *testing.T) {
+		legacy := filepath.Join(t.TempDir(), "legacy.json")
+		data := `[{"name": "MAIN.nValue", "dataType": "INT", "size": 2}]`
+		if err := os.WriteFile(legacy, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		s := c.NewSession(srv.AMSAddr(), sender)
+		if err := s.LoadSymbolsFromJSON(legacy); err != nil {
+			t.Fatal(err)
+		}
+		info, _ := s.registry.Get("MAIN.nValue")
+		verify.Values(t, "symbol", info, &SymbolInfo{Name: "MAIN.nValue", DataType: "INT", Size: 2})
+	})
+}
+
+func TestSymbolRegistryFind(t *testing.T) {
+	r := NewSymbolRegistry()
+	for _, name := range []string{
+		"MAIN.Axes",
+		"MAIN.Axes.X",
+		"MAIN.Axes.Y",
+		"MAIN.Axes.Y.Pos",
+		"MAIN.AxesCount",
+		"MAIN.aAxes[1]",
+		"MAIN.aAxes[1].Pos",
+		"MAIN.aAxes[2]",
+		"GVL.nValue",
+	} {
+		r.Set(name, &SymbolInfo{Name: name})
+	}
+
+	tests := []struct {
+		pattern string
+		fold    bool
+		want    []string
+	}{
+		{"MAIN.Axes", false, []string{"MAIN.Axes", "MAIN.Axes.X", "MAIN.Axes.Y", "MAIN.Axes.Y.Pos"}},
+		{"MAIN.Axes.*", false, []string{"MAIN.Axes.X", "MAIN.Axes.Y", "MAIN.Axes.Y.Pos"}},
+		{"MAIN.Axes.?", false, []string{"MAIN.Axes.X", "MAIN.Axes.Y"}},
+		{"main.axes.*", false, nil},
+		{"main.axes.*", true, []string{"MAIN.Axes.X", "MAIN.Axes.Y", "MAIN.Axes.Y.Pos"}},
+		{"gvl", true, []string{"GVL.nValue"}},
+		{"MAIN.[", false, nil},
+		{"MAIN.aAxes[1]", false, []string{"MAIN.aAxes[1]", "MAIN.aAxes[1].Pos"}},
+		{"MAIN.aAxes[1].*", false, []string{"MAIN.aAxes[1].Pos"}},
+		{"MAIN.aAxes[?]", false, []string{"MAIN.aAxes[1]", "MAIN.aAxes[2]"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			var infos []*SymbolInfo
+			if tt.fold {
+				infos = r.FindFold(tt.pattern)
+			} else {
+				infos = r.Find(tt.pattern)
+			}
+			var got []string
+			for _, info := range infos {
+				got = append(got, info.Name)
+			}
+			verify.Values(t, "names", got, tt.want)
+		})
+	}
+}
+
+func TestExportSymbolsToJSONOrder(t *testing.T) {
+	s := (&Client{}).NewSession(ams.Addr{}, ams.Addr{})
+	for _, name := range []string{"MAIN.c", "GVL.b", "MAIN.a", "GVL.a"} {
+		s.registry.Set(name, &SymbolInfo{Name: name})
+	}
+
+	dir := t.TempDir()
+	var files [][]byte
+	for i := 0; i < 3; i++ {
+		filename := filepath.Join(dir, "symbols.json")
+		if err := s.ExportSymbolsToJSON(filename); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, data)
+	}
+	for i := 1; i < len(files); i++ {
+		if !bytes.Equal(files[0], files[i]) {
+			t.Fatalf("export %d differs:\n%s\n%s", i, files[0], files[i])
+		}
+	}
+
+	var f symbolFile
+	if err := json.Unmarshal(files[0], &f); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, info := range f.Symbols {
+		names = append(names, info.Name)
+	}
+	verify.Values(t, "names", names, []string{"GVL.a", "GVL.b", "MAIN.a", "MAIN.c"})
+}
+
+func TestSymbolRegistryFold(t *testing.T) {
+	r := NewSymbolRegistry()
+	r.Set("MAIN.Value", &SymbolInfo{Name: "MAIN.Value", Size: 2})
+	r.Set("main.value", &SymbolInfo{Name: "MAIN.Value", Size: 4})
+
+	for _, name := range []string{"MAIN.Value", "main.value", "Main.VALUE"} {
+		info, ok := r.Get(name)
+		if !ok {
+			t.Fatalf("%s not found", name)
+		}
+		verify.Values(t, name, info.Size, uint32(4))
+	}
+	if _, ok := r.Get("MAIN.Other"); ok {
+		t.Error("found unknown symbol")
+	}
+
+	var names []string
+	for name := range r.GetAll() {
+		names = append(names, name)
+	}
+	verify.Values(t, "names", names, []string{"MAIN.Value"})
+}
+
+func TestReadFold(t *testing.T) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+
+	srv.HandleRead(0xF005, func(req *ams.ReadRequest) ([]byte, uint32) {
+		if req.IndexOffset != 7 {
+			return nil, ams.DeviceInvalidHandle
+		}
+		return []byte{0x2A, 0x00}, ams.NoError
+	})
+
+	c := &Client{Addr: srv.Addr()}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	s := c.NewSession(srv.AMSAddr(), ams.MustParseAddr("10.0.0.1.1.1:32000"))
+	s.registry.Set("MAIN.value", &SymbolInfo{Name: "MAIN.value", DataType: "INT", Size: 2, Handle: 7})
+
+	data, info, err := s.Read(context.Background(), "main.VALUE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "data", data, []byte{0x2A, 0x00})
+	verify.Values(t, "name", info.Name, "MAIN.value")
+}
+
+func TestReadLength(t *testing.T) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+
+	srv.HandleRead(0xF005, func(req *ams.ReadRequest) ([]byte, uint32) {
+		// the handle is one more than the number of bytes returned
+		return make([]byte, req.IndexOffset-1), ams.NoError
+	})
+
+	c := &Client{Addr: srv.Addr()}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	s := c.NewSession(srv.AMSAddr(), ams.MustParseAddr("10.0.0.1.1.1:32000"))
+
+	tests := []struct {
+		name    string
+		size    uint32
+		handle  uint32
+		wantErr error
+	}{
+		{"MAIN.nFull", 4, 5, nil},
+		{"MAIN.nShort", 4, 3, ErrShortRead},
+		{"MAIN.nEmpty", 4, 1, ErrEmptyRead},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s.registry.Set(tt.name, &SymbolInfo{Name: tt.name, DataType: "DINT", Size: tt.size, Handle: tt.handle})
+			_, _, err := s.Read(context.Background(), tt.name)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReadPartial(t *testing.T) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+
+	memory := make([]byte, 64)
+	for i := range memory {
+		memory[i] = byte(i)
+	}
+	srv.HandleRead(0x4040, func(req *ams.ReadRequest) ([]byte, uint32) {
+		end := req.IndexOffset + req.Length
+		if end > uint32(len(memory)) {
+			end = uint32(len(memory))
+		}
+		return memory[req.IndexOffset:end], ams.NoError
+	})
+	srv.HandleReadWrite(ams.IdxGetSymHandleByName, func(req *ams.ReadWriteRequest) ([]byte, uint32) {
+		return []byte{1, 0, 0, 0}, ams.NoError
+	})
+	srv.HandleRead(0xF005, func(req *ams.ReadRequest) ([]byte, uint32) {
+		return memory[0x10 : 0x10+req.Length], ams.NoError
+	})
+
+	c := &Client{Addr: srv.Addr()}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	s := c.NewSession(srv.AMSAddr(), ams.MustParseAddr("10.0.0.1.1.1:32000"))
+	s.registry.Set("MAIN.stBig", &SymbolInfo{Name: "MAIN.stBig", DataType: "ST_Big", Size: 16, IndexGroup: 0x4040, IndexOffset: 0x10})
+	s.registry.Set("MAIN.stNoAddr", &SymbolInfo{Name: "MAIN.stNoAddr", DataType: "ST_Big", Size: 16})
+	s.registry.Set("MAIN.stEnd", &SymbolInfo{Name: "MAIN.stEnd", DataType: "ST_Big", Size: 16, IndexGroup: 0x4040, IndexOffset: 0x38})
+
+	ctx := context.Background()
+	for _, name := range []string{"MAIN.stBig", "MAIN.stNoAddr"} {
+		data, err := s.ReadPartial(ctx, name, 4, 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		verify.Values(t, name, data, []byte{0x14, 0x15, 0x16, 0x17})
+	}
+
+	if _, err := s.ReadPartial(ctx, "MAIN.stBig", 14, 4); err == nil {
+		t.Fatal("want error for range beyond symbol size")
+	}
+	if _, err := s.ReadPartial(ctx, "MAIN.stEnd", 4, 8); !errors.Is(err, ErrShortRead) {
+		t.Fatalf("got error %v, want ErrShortRead", err)
+	}
+}
+
+func TestReadLarge(t *testing.T) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+
+	memory := make([]byte, 100)
+	for i := range memory {
+		memory[i] = byte(i)
+	}
+	var lengths []uint32
+	srv.HandleRead(0x4040, func(req *ams.ReadRequest) ([]byte, uint32) {
+		lengths = append(lengths, req.Length)
+		end := req.IndexOffset + req.Length
+		if end > uint32(len(memory)) {
+			end = uint32(len(memory))
+		}
+		return memory[req.IndexOffset:end], ams.NoError
+	})
+	srv.HandleReadWrite(ams.IdxGetSymHandleByName, func(req *ams.ReadWriteRequest) ([]byte, uint32) {
+		return []byte{1, 0, 0, 0}, ams.NoError
+	})
+	srv.HandleRead(0xF005, func(req *ams.ReadRequest) ([]byte, uint32) {
+		return memory[:req.Length], ams.NoError
+	})
+
+	c := &Client{Addr: srv.Addr()}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	s := c.NewSession(srv.AMSAddr(), ams.MustParseAddr("10.0.0.1.1.1:32000"))
+	s.SetReadChunkSize(16)
+	s.registry.Set("MAIN.aTrace", &SymbolInfo{Name: "MAIN.aTrace", DataType: "ARRAY [0..39] OF BYTE", Size: 40, IndexGroup: 0x4040, IndexOffset: 0x10})
+	s.registry.Set("MAIN.aSmall", &SymbolInfo{Name: "MAIN.aSmall", DataType: "ARRAY [0..7] OF BYTE", Size: 8})
+	s.registry.Set("MAIN.aNoAddr", &SymbolInfo{Name: "MAIN.aNoAddr", DataType: "ARRAY [0..39] OF BYTE", Size: 40})
+	s.registry.Set("MAIN.aShort", &SymbolInfo{Name: "MAIN.aShort", DataType: "ARRAY [0..39] OF BYTE", Size: 40, IndexGroup: 0x4040, IndexOffset: 0x50})
+	s.registry.Set("MAIN.aHuge", &SymbolInfo{Name: "MAIN.aHuge", DataType: "ARRAY [0..4294967294] OF BYTE", Size: 0xFFFFFFFF, IndexGroup: 0x4040})
+	s.registry.Set("MAIN.aWrap", &SymbolInfo{Name: "MAIN.aWrap", DataType: "ARRAY [0..39] OF BYTE", Size: 40, IndexGroup: 0x4040, IndexOffset: 0xFFFFFFF0})
+
+	ctx := context.Background()
+	data, _, err := s.ReadLarge(ctx, "MAIN.aTrace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "data", data, memory[0x10:0x38])
+	verify.Values(t, "chunks", lengths, []uint32{16, 16, 8})
+
+	data, _, err = s.ReadLarge(ctx, "MAIN.aSmall")
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "small", data, memory[:8])
+
+	if _, _, err := s.ReadLarge(ctx, "MAIN.aNoAddr"); err == nil {
+		t.Error("want error for symbol without index group")
+	}
+	if _, _, err := s.ReadLarge(ctx, "MAIN.aShort"); err == nil {
+		t.Error("want error for short read")
+	}
+	// the size is not allocated up front
+	if _, _, err := s.ReadLarge(ctx, "MAIN.aHuge"); err == nil {
+		t.Error("want error for short read of huge symbol")
+	}
+	lengths = nil
+	if _, _, err := s.ReadLarge(ctx, "MAIN.aWrap"); err == nil {
+		t.Error("want error for index offset overflow")
+	}
+	verify.Values(t, "wrap requests", lengths, []uint32(nil))
+}
+
+func TestReadByName(t *testing.T) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+
+	var byName, byHandle int
+	srv.HandleReadWrite(ams.IdxReadSymValueByName, func(req *ams.ReadWriteRequest) ([]byte, uint32) {
+		byName++
+		switch string(req.Data) {
+		case "MAIN.nCount\x00":
+			return []byte{42, 0}, ams.NoError
+		case "MAIN.nOther\x00":
+			return nil, ams.DeviceServiceNotSupported
+		}
+		return nil, ams.DeviceSymbolNotFound
+	})
+	srv.HandleReadWrite(ams.IdxGetSymHandleByName, func(req *ams.ReadWriteRequest) ([]byte, uint32) {
+		return []byte{1, 0, 0, 0}, ams.NoError
+	})
+	srv.HandleRead(0xF005, func(req *ams.ReadRequest) ([]byte, uint32) {
+		byHandle++
+		return []byte{7, 0}, ams.NoError
+	})
+
+	c := &Client{Addr: srv.Addr()}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	s := c.NewSession(srv.AMSAddr(), ams.MustParseAddr("10.0.0.1.1.1:32000"))
+	s.registry.Set("MAIN.nOther", &SymbolInfo{Name: "MAIN.nOther", DataType: "INT", Size: 2})
+
+	ctx := context.Background()
+	data, err := s.ReadByName(ctx, "MAIN.nCount")
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "by name", data, []byte{42, 0})
+
+	if _, err := s.ReadByName(ctx, "MAIN.unknown"); err == nil {
+		t.Error("want error for unknown symbol")
+	}
+
+	// falls back to the handle and does not try by name again
+	for i := 0; i < 2; i++ {
+		data, err = s.ReadByName(ctx, "MAIN.nOther")
+		if err != nil {
+			t.Fatal(err)
+		}
+		verify.Values(t, "fallback", data, []byte{7, 0})
+	}
+	verify.Values(t, "requests by name", byName, 3)
+	verify.Values(t, "requests by handle", byHandle, 2)
+}
+
+// BenchmarkReadOneShot compares a read with a new handle to a read by name.
+func BenchmarkReadOneShot(b *testing.B) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+	srv.HandleReadWrite(ams.IdxReadSymValueByName, func(req *ams.ReadWriteRequest) ([]byte, uint32) {
+		return make([]byte, 8), ams.NoError
+	})
+	srv.HandleReadWrite(ams.IdxGetSymHandleByName, func(req *ams.ReadWriteRequest) ([]byte, uint32) {
+		return []byte{1, 0, 0, 0}, ams.NoError
+	})
+	srv.HandleRead(0xF005, func(req *ams.ReadRequest) ([]byte, uint32) {
+		return make([]byte, req.Length), ams.NoError
+	})
+
+	c := &Client{Addr: srv.Addr()}
+	if err := c.Dial(context.Background()); err != nil {
+		b.Fatal(err)
+	}
+	defer c.Close()
+
+	ctx := context.Background()
+	sender := ams.MustParseAddr("10.0.0.1.1.1:32000")
+	info := &SymbolInfo{Name: "MAIN.fValue", DataType: "LREAL", Size: 8}
+
+	b.Run("handle", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			s := c.NewSession(srv.AMSAddr(), sender)
+			s.registry.Set(info.Name, info)
+			if _, _, err := s.Read(ctx, info.Name); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("name", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			s := c.NewSession(srv.AMSAddr(), sender)
+			s.registry.Set(info.Name, info)
+			if _, err := s.ReadByName(ctx, info.Name); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestWriteFieldDirect(t *testing.T) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+
+	var writes []*ams.WriteRequest
+	srv.HandleWrite(0x4040, func(req *ams.WriteRequest) uint32 {
+		writes = append(writes, req)
+		return ams.NoError
+	})
+
+	c := &Client{Addr: srv.Addr()}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	s := c.NewSession(srv.AMSAddr(), ams.MustParseAddr("10.0.0.1.1.1:32000"))
+	s.registry.Set("MAIN.stOuter", &SymbolInfo{Name: "MAIN.stOuter", DataType: "ST_Outer", Size: 12, IndexGroup: 0x4040, IndexOffset: 0x100})
+	s.types.Set("ST_Outer", &DataTypeInfo{Name: "ST_Outer", Fields: []StructField{
+		{Name: "a", DataType: "INT", Offset: 0, Size: 2},
+		{Name: "stInner", DataType: "ST_Inner", Offset: 4, Size: 8},
+	}})
+	s.types.Set("ST_Inner", &DataTypeInfo{Name: "ST_Inner", Fields: []StructField{
+		{Name: "x", DataType: "DINT", Offset: 0, Size: 4},
+		{Name: "y", DataType: "DINT", Offset: 4, Size: 4},
+	}})
+
+	ctx := context.Background()
+	if err := s.WriteFieldDirect(ctx, "MAIN.stOuter", []string{"stInner", "y"}, []byte{1, 2, 3, 4}); err != nil {
+		t.Fatal(err)
+	}
+	if len(writes) != 1 {
+		t.Fatalf("got %d writes want 1", len(writes))
+	}
+	verify.Values(t, "offset", writes[0].IndexOffset, uint32(0x108))
+	verify.Values(t, "data", writes[0].Data, []byte{1, 2, 3, 4})
+
+	if err := s.WriteFieldDirect(ctx, "MAIN.stOuter", []string{"a"}, []byte{1}); err == nil {
+		t.Fatal("want error for size mismatch")
+	}
+	if err := s.WriteFieldDirect(ctx, "MAIN.stOuter", []string{"stInner", "z"}, []byte{1}); err == nil {
+		t.Fatal("want error for unknown field")
+	}
+}
+
+func TestWriteFields(t *testing.T) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+
+	reads := 0
+	srv.HandleRead(0xF005, func(req *ams.ReadRequest) ([]byte, uint32) {
+		reads++
+		return []byte{9, 9, 9, 9, 9, 9, 9, 9, 9, 9, 9, 9}, ams.NoError
+	})
+	var writes [][]byte
+	srv.HandleWrite(0xF005, func(req *ams.WriteRequest) uint32 {
+		writes = append(writes, req.Data)
+		return ams.NoError
+	})
+
+	c := &Client{Addr: srv.Addr()}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	s := c.NewSession(srv.AMSAddr(), ams.MustParseAddr("10.0.0.1.1.1:32000"))
+	s.registry.Set("MAIN.stOuter", &SymbolInfo{Name: "MAIN.stOuter", DataType: "ST_Outer", Size: 12, Handle: 1})
+	s.types.Set("ST_Outer", &DataTypeInfo{Name: "ST_Outer", Fields: []StructField{
+		{Name: "a", DataType: "INT", Offset: 0, Size: 2},
+		{Name: "stInner", DataType: "ST_Inner", Offset: 4, Size: 8},
+	}})
+	s.types.Set("ST_Inner", &DataTypeInfo{Name: "ST_Inner", Fields: []StructField{
+		{Name: "x", DataType: "DINT", Offset: 0, Size: 4},
+		{Name: "y", DataType: "DINT", Offset: 4, Size: 4},
+	}})
+
+	ctx := context.Background()
+	err := s.WriteFields(ctx, "MAIN.stOuter", map[string][]byte{
+		"a":         {1, 0},
+		"stInner.y": {2, 0, 0, 0},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "reads", reads, 1)
+	verify.Values(t, "writes", writes, [][]byte{{1, 0, 9, 9, 9, 9, 9, 9, 2, 0, 0, 0}})
+
+	tests := []struct {
+		name    string
+		updates map[string][]byte
+	}{
+		{"size mismatch", map[string][]byte{"a": {1, 0}, "stInner.x": {1}}},
+		{"unknown field", map[string][]byte{"a": {1, 0}, "stInner.z": {1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := s.WriteFields(ctx, "MAIN.stOuter", tt.updates); err == nil {
+				t.Fatal("got no error")
+			}
+			verify.Values(t, "writes", len(writes), 1)
+		})
+	}
+}
+
+// symbolEntry encodes an ADS symbol entry with the attributes
+// in the order of attrs as name, value pairs.
+func symbolEntry(name, dataType, comment string, group, offset, size uint32, attrs ...string) []byte {
+	b := make([]byte, 30)
+	binary.LittleEndian.PutUint32(b[4:8], group)
+	binary.LittleEndian.PutUint32(b[8:12], offset)
+	binary.LittleEndian.PutUint32(b[12:16], size)
+	binary.LittleEndian.PutUint16(b[24:26], uint16(len(name)))
+	binary.LittleEndian.PutUint16(b[26:28], uint16(len(dataType)))
+	binary.LittleEndian.PutUint16(b[28:30], uint16(len(comment)))
+	b = append(b, name+"\x00"+dataType+"\x00"+comment+"\x00"...)
+	if len(attrs) > 0 {
+		binary.LittleEndian.PutUint16(b[20:22], symbolFlagAttributes)
+		b = append(b, byte(len(attrs)/2), 0)
+		for i := 0; i < len(attrs); i += 2 {
+			b = append(b, byte(len(attrs[i])), byte(len(attrs[i+1])))
+			b = append(b, attrs[i]+"\x00"+attrs[i+1]+"\x00"...)
+		}
+	}
+	binary.LittleEndian.PutUint32(b[0:4], uint32(len(b)))
+	return b
+}
+
+func TestLoadSymbolTable(t *testing.T) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+
+	var table []byte
+	table = append(table, symbolEntry("MAIN.fTemp", "LREAL", "temperature", 0x4040, 0x10, 8,
+		"DisplayMinValue", "-40",
+		"DisplayMaxValue", "125",
+		"Unit", "°C",
+		"hide", "",
+	)...)
+	table = append(table, symbolEntry("MAIN.nCount", "INT", "", 0x4040, 0x18, 2)...)
+
+	srv.HandleRead(0xF008, func(req *ams.ReadRequest) ([]byte, uint32) {
+		return []byte{1}, ams.NoError
+	})
+	srv.HandleRead(0xF00C, func(req *ams.ReadRequest) ([]byte, uint32) {
+		info := make([]byte, 0x30)
+		binary.LittleEndian.PutUint32(info[0:4], 2)
+		binary.LittleEndian.PutUint32(info[4:8], uint32(len(table)))
+		return info, ams.NoError
+	})
+	srv.HandleRead(0xF00B, func(req *ams.ReadRequest) ([]byte, uint32) {
+		return table, ams.NoError
+	})
+
+	c := &Client{Addr: srv.Addr()}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	s := c.NewSession(srv.AMSAddr(), ams.MustParseAddr("10.0.0.1.1.1:32000"))
+	if err := s.LoadSymbolTable(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	temp, _ := s.registry.Get("MAIN.fTemp")
+	verify.Values(t, "fTemp", temp, &SymbolInfo{
+		Name:        "MAIN.fTemp",
+		DataType:    "LREAL",
+		Size:        8,
+		IndexGroup:  0x4040,
+		IndexOffset: 0x10,
+		Comment:     "temperature",
+		Attributes: map[string]string{
+			"DisplayMinValue": "-40",
+			"DisplayMaxValue": "125",
+			"Unit":            "°C",
+			"hide":            "",
+		},
+		Flags:   symbolFlagAttributes,
+		details: true,
+	})
+	count, _ := s.registry.Get("MAIN.nCount")
+	verify.Values(t, "nCount", count, &SymbolInfo{
+		Name:        "MAIN.nCount",
+		DataType:    "INT",
+		Size:        2,
+		IndexGroup:  0x4040,
+		IndexOffset: 0x18,
+		details:     true,
+	})
+}
+
+func TestLoadSymbolTableLimit(t *testing.T) {
+	table := symbolEntry("MAIN.nCount", "INT", "", 0x4040, 0x18, 2)
+	size := uint32(len(table))
+
+	tests := []struct {
+		name     string
+		declared uint32 // symbol length of the upload info
+		limit    uint32
+		uploads  []uint32 // requested lengths
+		wantErr  bool
+	}{
+		{"within limit", size, size, []uint32{size}, false},
+		{"default limit", size, 0, []uint32{size}, false},
+		{"declared too large", size, size - 1, nil, true},
+		{"undeclared", 0, size, []uint32{size}, false},
+		{"returned too large", 0, size - 1, []uint32{size - 1}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := goadstest.NewServer()
+			defer srv.Close()
+			srv.HandleRead(0xF008, func(req *ams.ReadRequest) ([]byte, uint32) {
+				return []byte{1}, ams.NoError
+			})
+			srv.HandleRead(0xF00C, func(req *ams.ReadRequest) ([]byte, uint32) {
+				info := make([]byte, 0x30)
+				binary.LittleEndian.PutUint32(info[0:4], 1)
+				binary.LittleEndian.PutUint32(info[4:8], tt.declared)
+				return info, ams.NoError
+			})
+			var uploads []uint32
+			srv.HandleRead(0xF00B, func(req *ams.ReadRequest) ([]byte, uint32) {
+				uploads = append(uploads, req.Length)
+				return table, ams.NoError
+			})
+
+			c := &Client{Addr: srv.Addr()}
+			if err := c.Dial(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+
+			s := c.NewSession(srv.AMSAddr(), ams.MustParseAddr("10.0.0.1.1.1:32000"))
+			s.SetMaxSymbolTableSize(tt.limit)
+			err := s.LoadSymbolTable(context.Background())
+			if got, want := err != nil, tt.wantErr; got != want {
+				t.Fatalf("got error %v want error %v", err, want)
+			}
+			if err != nil && !errors.Is(err, ErrSymbolTableTooLarge) {
+				t.Errorf("got %v want ErrSymbolTableTooLarge", err)
+			}
+			verify.Values(t, "uploads", uploads, tt.uploads)
+			verify.Values(t, "symbols", s.registry.Count() == 1, !tt.wantErr)
+		})
+	}
+}
+
+func TestGetSymbolComment(t *testing.T) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+
+	requests := 0
+	srv.HandleReadWrite(0xF009, func(req *ams.ReadWriteRequest) ([]byte, uint32) {
+		requests++
+		if string(req.Data) != "MAIN.fTemp\x00" {
+			return nil, ams.DeviceSymbolNotFound
+		}
+		return symbolEntry("MAIN.fTemp", "LREAL", "temperature", 0x4040, 0x10, 8, "Unit", "°C"), ams.NoError
+	})
+
+	c := &Client{Addr: srv.Addr()}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	s := c.NewSession(srv.AMSAddr(), ams.MustParseAddr("10.0.0.1.1.1:32000"))
+	s.registry.Set("MAIN.fTemp", &SymbolInfo{Name: "MAIN.fTemp", DataType: "LREAL", Size: 8, Handle: 5})
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		comment, err := s.GetSymbolComment(ctx, "MAIN.fTemp")
+		if err != nil {
+			t.Fatal(err)
+		}
+		verify.Values(t, "comment", comment, "temperature")
+	}
+	attrs, err := s.GetSymbolAttributes(ctx, "MAIN.fTemp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "attributes", attrs, map[string]string{"Unit": "°C"})
+	verify.Values(t, "requests", requests, 1)
+
+	info, _ := s.registry.Get("MAIN.fTemp")
+	verify.Values(t, "handle", info.Handle, uint32(5))
+
+	if _, err := s.GetSymbolComment(ctx, "MAIN.missing"); !errors.Is(err, ErrSymbolNotFound) {
+		t.Errorf("got error %v want %v", err, ErrSymbolNotFound)
+	}
+}
+
+func TestLoadSymbolTableFiltered(t *testing.T) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+
+	ref := symbolEntry("MAIN.refValue", "INT", "", 0x4040, 0x20, 8)
+	binary.LittleEndian.PutUint16(ref[20:22], symbolFlagReferenceTo)
+	var table []byte
+	table = append(table, symbolEntry("MAIN.nCount", "INT", "", 0x4040, 0x18, 2)...)
+	table = append(table, symbolEntry("MAIN.pValue", "POINTER TO INT", "", 0x4040, 0x1a, 8)...)
+	table = append(table, ref...)
+	table = append(table, symbolEntry("MAIN.fbTimer.ET", "TIME", "", 0x4040, 0x28, 4)...)
+	table = append(table, symbolEntry("MAIN.sName", "STRING(10)", "", 0x4040, 0x2c, 11)...)
+
+	srv.HandleRead(0xF008, func(req *ams.ReadRequest) ([]byte, uint32) {
+		return []byte{1}, ams.NoError
+	})
+	srv.HandleRead(0xF00C, func(req *ams.ReadRequest) ([]byte, uint32) {
+		info := make([]byte, 0x30)
+		binary.LittleEndian.PutUint32(info[0:4], 5)
+		binary.LittleEndian.PutUint32(info[4:8], uint32(len(table)))
+		return info, ams.NoError
+	})
+	srv.HandleRead(0xF00B, func(req *ams.ReadRequest) ([]byte, uint32) {
+		return table, ams.NoError
+	})
+
+	c := &Client{Addr: srv.Addr()}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	tests := []struct {
+		name string
+		opts LoadOptions
+		want []string
+	}{
+		{"all", LoadOptions{}, []string{"MAIN.fbTimer.ET", "MAIN.nCount", "MAIN.pValue", "MAIN.refValue", "MAIN.sName"}},
+		{"references", LoadOptions{SkipReferences: true}, []string{"MAIN.fbTimer.ET", "MAIN.nCount", "MAIN.pValue", "MAIN.sName"}},
+		{"pointers", LoadOptions{SkipPointers: true}, []string{"MAIN.fbTimer.ET", "MAIN.nCount", "MAIN.refValue", "MAIN.sName"}},
+		{"prefix", LoadOptions{ExcludePrefixes: []string{"MAIN.fb"}}, []string{"MAIN.nCount", "MAIN.pValue", "MAIN.refValue", "MAIN.sName"}},
+		{"filter", LoadOptions{Filter: func(info *SymbolInfo) bool { return info.DataType == "INT" }}, []string{"MAIN.nCount", "MAIN.refValue"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := c.NewSession(srv.AMSAddr(), ams.MustParseAddr("10.0.0.1.1.1:32000"))
+			if err := s.LoadSymbolTableFiltered(context.Background(), tt.opts); err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, info := range s.FindSymbols("*") {
+				names = append(names, info.Name)
+			}
+			verify.Values(t, "symbols", names, tt.want)
+		})
+	}
+
+	// visited symbols are not stored
+	s := c.NewSession(srv.AMSAddr(), ams.MustParseAddr("10.0.0.1.1.1:32000"))
+	var visited []string
+	err := s.LoadSymbolTableFiltered(context.Background(), LoadOptions{
+		SkipPointers: true,
+		Visit: func(info *SymbolInfo) error {
+			visited = append(visited, info.Name)
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "visited", visited, []string{"MAIN.nCount", "MAIN.refValue", "MAIN.fbTimer.ET", "MAIN.sName"})
+	verify.Values(t, "stored", len(s.FindSymbols("*")), 0)
+}
+
+func TestParseSymbolTableStream(t *testing.T) {
+	var table []byte
+	table = append(table, symbolEntry("MAIN.nCount", "INT", "counter", 0x4040, 0x18, 2, "Unit", "pcs")...)
+	table = append(table, symbolEntry("MAIN.sName", "STRING(10)", "", 0x4040, 0x1a, 11)...)
+
+	want := []*SymbolInfo{
+		{Name: "MAIN.nCount", DataType: "INT", Size: 2, IndexGroup: 0x4040, IndexOffset: 0x18, Comment: "counter", Attributes: map[string]string{"Unit": "pcs"}, Flags: symbolFlagAttributes, details: true},
+		{Name: "MAIN.sName", DataType: "STRING(10)", Size: 11, IndexGroup: 0x4040, IndexOffset: 0x1a, details: true},
+	}
+
+	tests := []struct {
+		name    string
+		r       io.Reader
+		want    []*SymbolInfo
+		wantErr bool
+	}{
+		{"table", bytes.NewReader(table), want, false},
+		{"byte by byte", iotest.OneByteReader(bytes.NewReader(table)), want, false},
+		{"padding", bytes.NewReader(append(table, 0, 0, 0, 0, 0, 0)), want, false},
+		{"truncated", bytes.NewReader(table[:len(table)-3]), want[:1], true},
+		{"invalid length", bytes.NewReader([]byte{1, 0, 0, 0}), nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []*SymbolInfo
+			err := ParseSymbolTableStream(tt.r, func(info *SymbolInfo) error {
+				got = append(got, info)
+				return nil
+			})
+			if got, want := err != nil, tt.wantErr; got != want {
+				t.Fatalf("got error %v want error %v", err, want)
+			}
+			verify.Values(t, "symbols", got, tt.want)
+		})
+	}
+
+	stop := errors.New("stop")
+	n := 0
+	err := ParseSymbolTableStream(bytes.NewReader(table), func(*SymbolInfo) error {
+		n++
+		return stop
+	})
+	if err != stop || n != 1 {
+		t.Fatalf("got error %v after %d symbols want %v after 1", err, n, stop)
+	}
+}
+
+func TestLoadSymbolTableWithoutVersion(t *testing.T) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+
+	table := symbolEntry("MAIN.nCount", "INT", "", 0x4040, 0x18, 2)
+	srv.HandleRead(0xF00C, func(req *ams.ReadRequest) ([]byte, uint32) {
+		info := make([]byte, 0x30)
+		binary.LittleEndian.PutUint32(info[0:4], 1)
+		binary.LittleEndian.PutUint32(info[4:8], uint32(len(table)))
+		return info, ams.NoError
+	})
+	srv.HandleRead(0xF00B, func(req *ams.ReadRequest) ([]byte, uint32) {
+		return table, ams.NoError
+	})
+
+	c := &Client{Addr: srv.Addr()}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	s := c.NewSession(srv.AMSAddr(), ams.MustParseAddr("10.0.0.1.1.1:32000"))
+	if err := s.LoadSymbolTable(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "count", s.GetSymbolCount(), 1)
+	verify.Values(t, "version", s.symbolVersion, uint8(0))
+
+	// the export of such a target can be loaded again
+	filename := filepath.Join(t.TempDir(), "symbols.json")
+	if err := s.ExportSymbolsToJSON(filename); err != nil {
+		t.Fatal(err)
+	}
+	s = c.NewSession(srv.AMSAddr(), ams.MustParseAddr("10.0.0.1.1.1:32000"))
+	if err := s.LoadSymbolsFromJSON(filename); err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "loaded count", s.GetSymbolCount(), 1)
+}
+
+func TestGetSymbolUploadInfo(t *testing.T) {
+	full := make([]byte, 0x30)
+	for i := 0; i < 6; i++ {
+		binary.LittleEndian.PutUint32(full[4*i:], uint32(i+1))
+	}
+
+	tests := []struct {
+		name      string
+		data      []byte
+		noVersion bool
+		want      SymbolUploadInfo
+		wantErr   bool
+	}{
+		{"full", full, false, SymbolUploadInfo{1, 2, 3, 4, 5, 6, 7}, false},
+		{"symbols only", full[:8], false, SymbolUploadInfo{SymbolCount: 1, SymbolLength: 2, Version: 7}, false},
+		{"too short", full[:4], false, SymbolUploadInfo{}, true},
+		{"without version", full, true, SymbolUploadInfo{1, 2, 3, 4, 5, 6, 0}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := goadstest.NewServer()
+			defer srv.Close()
+			if !tt.noVersion {
+				srv.HandleRead(0xF008, func(req *ams.ReadRequest) ([]byte, uint32) {
+					return []byte{7}, ams.NoError
+				})
+			}
+			srv.HandleRead(0xF00C, func(req *ams.ReadRequest) ([]byte, uint32) {
+				return tt.data, ams.NoError
+			})
+
+			c := &Client{Addr: srv.Addr()}
+			if err := c.Dial(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+
+			s := c.NewSession(srv.AMSAddr(), ams.MustParseAddr("10.0.0.1.1.1:32000"))
+			got, err := s.GetSymbolUploadInfo(context.Background())
+			if got, want := err != nil, tt.wantErr; got != want {
+				t.Fatalf("got error %v want error %v", err, want)
+			}
+			verify.Values(t, "", got, tt.want)
+		})
+	}
+}
+
+// fakeClient answers reads with the values by index offset and handle
+// requests with the handles by name. The other methods panic.
+type fakeClient struct {
+	ADSClient
+	values  map[uint32][]byte
+	handles map[string]uint32
+}
+
+func (c *fakeClient) GetSymHandleByName(ctx context.Context, targetID, senderID ams.Addr, name string) (uint32, error) {
+	h, ok := c.handles[name]
+	if !ok {
+		return 0, ams.ADSError(ams.DeviceSymbolNotFound)
+	}
+	return h, nil
+}
+
+func (c *fakeClient) Read(ctx context.Context, r *ams.ReadRequest) (*ams.ReadResponse, error) {
+	v, ok := c.values[r.IndexOffset]
+	if !ok {
+		return &ams.ReadResponse{Result: ams.DeviceInvalidHandle}, ams.ADSError(ams.DeviceInvalidHandle)
+	}
+	return &ams.ReadResponse{Data: v}, nil
+}
+
+func TestSessionWithFakeClient(t *testing.T) {
+	// the handle of MAIN.m stays invalid
+	client := &fakeClient{values: map[uint32][]byte{7: {42, 0}}, handles: map[string]uint32{"MAIN.m": 8}}
+	s := NewSessionWithClient(client, ams.MustParseAddr("127.0.0.1.1.1:851"), ams.MustParseAddr("10.0.0.1.1.1:32000"))
+	s.registry.Set("MAIN.n", &SymbolInfo{Name: "MAIN.n", DataType: "INT", Size: 2, Handle: 7})
+	s.registry.Set("MAIN.m", &SymbolInfo{Name: "MAIN.m", DataType: "INT", Size: 2, Handle: 8})
+
+	data, _, err := s.Read(context.Background(), "MAIN.n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "data", data, []byte{42, 0})
+
+	if _, _, err := s.Read(context.Background(), "MAIN.m"); !errors.Is(err, ams.ADSError(ams.DeviceInvalidHandle)) {
+		t.Fatalf("got error %v want %v", err, ams.ADSError(ams.DeviceInvalidHandle))
+	}
+}
+
+func TestSymbolFlags(t *testing.T) {
+	entry := symbolEntry("GVL.nMax", "INT", "", 0x4040, 0x10, 2)
+	binary.LittleEndian.PutUint16(entry[20:22], symbolFlagReadOnly|symbolFlagStatic)
+	info, err := parseSymbolEntry(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verify.Values(t, "read-only", info.IsReadOnly(), true)
+	verify.Values(t, "static", info.IsStatic(), true)
+	verify.Values(t, "persistent", info.IsPersistent(), false)
+	verify.Values(t, "interface pointer", info.IsTComInterfacePtr(), false)
+	verify.Values(t, "reference", info.IsReference(), false)
+}
+
+func TestWriteReadOnly(t *testing.T) {
+	s := (&Client{}).NewSession(ams.Addr{}, ams.Addr{})
+	s.registry.Set("GVL.nMax", &SymbolInfo{Name: "GVL.nMax", DataType: "INT", Size: 2, IndexGroup: 0x4040, Handle: 1, Flags: symbolFlagReadOnly})
+
+	ctx := context.Background()
+	if err := s.Write(ctx, "GVL.nMax", []byte{1, 0}); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Write: got %v want ErrReadOnly", err)
+	}
+	if err := s.WriteFieldDirect(ctx, "GVL.nMax", []string{"x"}, []byte{1}); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("WriteFieldDirect: got %v want ErrReadOnly", err)
+	}
+	results, err := s.WriteBatch(ctx, map[string][]byte{"GVL.nMax": {1, 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := results["GVL.nMax"].Error; !errors.Is(err, ErrReadOnly) {
+		t.Errorf("WriteBatch: got %v want ErrReadOnly", err)
+	}
+}
@@ -0,0 +1,36 @@
+package goads
+
+import "sync/atomic"
+
+// Stats contains the counters of a client since it was created.
+type Stats struct {
+	RequestsSent      uint64 // requests written to the connection
+	ResponsesReceived uint64 // responses received including late ones
+	Timeouts          uint64 // requests without a response in time
+	DecodeErrors      uint64 // received packets which could not be decoded
+	BytesSent         uint64 // including the AMS/TCP header
+	BytesReceived     uint64 // including the AMS/TCP header
+}
+
+// clientStats are the counters of a client. They are updated
+// atomically and must be 64-bit aligned.
+type clientStats struct {
+	requestsSent      uint64
+	responsesReceived uint64
+	timeouts          uint64
+	decodeErrors      uint64
+	bytesSent         uint64
+	bytesReceived     uint64
+}
+
+// Stats returns a snapshot of the counters of the client.
+func (c *Client) Stats() Stats {
+	return Stats{
+		RequestsSent:      atomic.LoadUint64(&c.stats.requestsSent),
+		ResponsesReceived: atomic.LoadUint64(&c.stats.responsesReceived),
+		Timeouts:          atomic.LoadUint64(&c.stats.timeouts),
+		DecodeErrors:      atomic.LoadUint64(&c.stats.decodeErrors),
+		BytesSent:         atomic.LoadUint64(&c.stats.bytesSent),
+		BytesReceived:     atomic.LoadUint64(&c.stats.bytesReceived),
+	}
+}
@@ -0,0 +1,96 @@
+package goads
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// defaultStringLen is the length of STRING and WSTRING without a length
+const defaultStringLen = 80
+
+// stringCapacity returns the maximum number of characters of a STRING(n)
+// or WSTRING(n) type and whether it is a WSTRING.
+func stringCapacity(dataType string) (n int, wide bool, err error) {
+	s := dataType
+	if strings.HasPrefix(s, "WSTRING") {
+		wide = true
+		s = s[1:]
+	}
+	if s == "STRING" {
+		return defaultStringLen, wide, nil
+	}
+	if !strings.HasPrefix(s, "STRING(") || !strings.HasSuffix(s, ")") {
+		return 0, false, fmt.Errorf("invalid string type: %s", dataType)
+	}
+	n, err = strconv.Atoi(s[len("STRING(") : len(s)-1])
+	if err != nil || n <= 0 {
+		return 0, false, fmt.Errorf("invalid string type: %s", dataType)
+	}
+	return n, wide, nil
+}
+
+// ReadString reads a STRING or WSTRING variable up to the null terminator.
+func (s *Session) ReadString(ctx context.Context, name string) (string, error) {
+	info, err := s.GetSymbol(ctx, name)
+	if err != nil {
+		return "", fmt.Errorf("failed to get symbol info: %w", err)
+	}
+	_, wide, err := stringCapacity(info.DataType)
+	if err != nil {
+		return "", fmt.Errorf("%s is not a string", name)
+	}
+
+	data, _, err := s.Read(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	if !wide {
+		return nullTerminatedString(data), nil
+	}
+
+	u := make([]uint16, 0, len(data)/2)
+	for i := 0; i+1 < len(data); i += 2 {
+		c := ByteOrder.Uint16(data[i:])
+		if c == 0 {
+			break
+		}
+		u = append(u, c)
+	}
+	return string(utf16.Decode(u)), nil
+}
+
+// WriteString writes a STRING or WSTRING variable. The value is null
+// terminated and padded with zeros to the declared length. It
+// returns an error if the value is longer than the declared length.
+func (s *Session) WriteString(ctx context.Context, name, value string) error {
+	info, err := s.GetSymbol(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to get symbol info: %w", err)
+	}
+	n, wide, err := stringCapacity(info.DataType)
+	if err != nil {
+		return fmt.Errorf("%s is not a string", name)
+	}
+
+	var data []byte
+	if wide {
+		u := utf16.Encode([]rune(value))
+		if len(u) > n {
+			return fmt.Errorf("string too long for %s: %d characters", info.DataType, len(u))
+		}
+		data = make([]byte, 2*(n+1))
+		for i, c := range u {
+			ByteOrder.PutUint16(data[2*i:], c)
+		}
+	} else {
+		if len(value) > n {
+			return fmt.Errorf("string too long for %s: %d bytes", info.DataType, len(value))
+		}
+		data = make([]byte, n+1)
+		copy(data, value)
+	}
+	return s.Write(ctx, name, data)
+}
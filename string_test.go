@@ -0,0 +1,106 @@
+package goads
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mrpasztoradam/goads/ams"
+	"github.com/mrpasztoradam/goads/goadstest"
+	"github.com/pascaldekloe/goe/verify"
+)
+
+func TestStringCapacity(t *testing.T) {
+	tests := []struct {
+		dataType string
+		n        int
+		wide     bool
+		wantErr  bool
+	}{
+		{"STRING", 80, false, false},
+		{"STRING(10)", 10, false, false},
+		{"WSTRING", 80, true, false},
+		{"WSTRING(255)", 255, true, false},
+		{"STRING(0)", 0, false, true},
+		{"STRING(x)", 0, false, true},
+		{"INT", 0, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dataType, func(t *testing.T) {
+			n, wide, err := stringCapacity(tt.dataType)
+			if got, want := err != nil, tt.wantErr; got != want {
+				t.Fatalf("got error %v want error %v", err, want)
+			}
+			verify.Values(t, "n", n, tt.n)
+			verify.Values(t, "wide", wide, tt.wide)
+		})
+	}
+}
+
+func TestReadWriteString(t *testing.T) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+
+	// the handle is the index of the symbol
+	names := []string{"MAIN.sName", "MAIN.wsName", "MAIN.nCount"}
+	values := make([][]byte, len(names))
+	srv.HandleReadWrite(ams.IdxGetSymHandleByName, func(req *ams.ReadWriteRequest) ([]byte, uint32) {
+		for i, name := range names {
+			if name == string(req.Data) {
+				return []byte{byte(i), 0, 0, 0}, ams.NoError
+			}
+		}
+		return nil, ams.DeviceSymbolNotFound
+	})
+	srv.HandleRead(0xF005, func(req *ams.ReadRequest) ([]byte, uint32) {
+		return values[req.IndexOffset], ams.NoError
+	})
+	srv.HandleWrite(0xF005, func(req *ams.WriteRequest) uint32 {
+		values[req.IndexOffset] = req.Data
+		return ams.NoError
+	})
+
+	c := &Client{Addr: srv.Addr()}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	s := c.NewSession(srv.AMSAddr(), ams.MustParseAddr("10.0.0.1.1.1:32000"))
+	s.registry.Set("MAIN.sName", &SymbolInfo{Name: "MAIN.sName", DataType: "STRING(5)", Size: 6})
+	s.registry.Set("MAIN.wsName", &SymbolInfo{Name: "MAIN.wsName", DataType: "WSTRING(3)", Size: 8})
+	s.registry.Set("MAIN.nCount", &SymbolInfo{Name: "MAIN.nCount", DataType: "INT", Size: 2})
+
+	ctx := context.Background()
+	if err := s.WriteString(ctx, "MAIN.sName", "abc"); err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "STRING data", values[0], []byte("abc\x00\x00\x00"))
+	got, err := s.ReadString(ctx, "MAIN.sName")
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "STRING", got, "abc")
+
+	if err := s.WriteString(ctx, "MAIN.wsName", "äö"); err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "WSTRING data", values[1], []byte{0xe4, 0, 0xf6, 0, 0, 0, 0, 0})
+	got, err = s.ReadString(ctx, "MAIN.wsName")
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "WSTRING", got, "äö")
+
+	if err := s.WriteString(ctx, "MAIN.sName", "abcdef"); err == nil {
+		t.Error("want error for too long STRING")
+	}
+	if err := s.WriteString(ctx, "MAIN.wsName", "abcd"); err == nil {
+		t.Error("want error for too long WSTRING")
+	}
+	if _, err := s.ReadString(ctx, "MAIN.nCount"); err == nil {
+		t.Error("want error for INT")
+	}
+	if err := s.WriteString(ctx, "MAIN.nCount", "1"); err == nil {
+		t.Error("want error for INT")
+	}
+}
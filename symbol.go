@@ -3,11 +3,21 @@ package goads
 import (
 	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/mrpasztoradam/goads/ams"
 )
 
+// ErrSymbolNotFound is returned when the PLC does not know a symbol
+var ErrSymbolNotFound = errors.New("symbol not found")
+
+// ErrDataTypeInfoUnsupported is returned by GetDataTypeInfo when the
+// target does not support the data type upload, e.g. minimal runtimes.
+var ErrDataTypeInfoUnsupported = errors.New("data type info not supported")
+
 // StructField represents a field within a struct
 type StructField struct {
 	Name     string        `json:"name"`
@@ -20,10 +30,12 @@ type StructField struct {
 
 // Symbol represents a PLC symbol
 type Symbol struct {
-	Name     string        `json:"name"`
-	DataType string        `json:"type"`
-	Size     uint32        `json:"size"`
-	Fields   []StructField `json:"fields,omitempty"`
+	Name        string        `json:"name"`
+	DataType    string        `json:"type"`
+	Size        uint32        `json:"size"`
+	IndexGroup  uint32        `json:"indexGroup"`
+	IndexOffset uint32        `json:"indexOffset"`
+	Fields      []StructField `json:"fields,omitempty"`
 }
 
 // GetSymbol retrieves full symbol information including data type and fields
@@ -42,12 +54,22 @@ func (c *Client) GetSymbol(ctx context.Context, targetAddr, senderAddr ams.Addr,
 		nameBytes,
 	)
 	resp, err := c.ReadWrite(ctx, req)
+	if errors.Is(err, ams.ADSError(ams.DeviceSymbolNotFound)) {
+		return nil, fmt.Errorf("%w: %s", ErrSymbolNotFound, name)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get symbol info: %w", err)
 	}
 
-	if len(resp.Data) < 32 {
-		return nil, fmt.Errorf("invalid symbol info response (length: %d)", len(resp.Data))
+	return parseSymbol(name, resp.Data)
+}
+
+// parseSymbol parses the symbol entry returned by ADSIGRP_SYM_INFOBYNAMEEX.
+// All lengths are checked against data so that a corrupt response is an
+// error.
+func parseSymbol(name string, data []byte) (*Symbol, error) {
+	if len(data) < 32 {
+		return nil, fmt.Errorf("invalid symbol info response (length: %d)", len(data))
 	}
 
 	// Parse ADS symbol entry structure:
@@ -64,39 +86,35 @@ func (c *Client) GetSymbol(ctx context.Context, targetAddr, senderAddr ams.Addr,
 	// Offset 30+nameLength: type (variable)
 	// Offset 30+nameLength+typeLength: comment (variable)
 
-	size := binary.LittleEndian.Uint32(resp.Data[12:16])
-	nameLength := binary.LittleEndian.Uint16(resp.Data[24:26])
-	typeLength := binary.LittleEndian.Uint16(resp.Data[26:28])
+	entryLength := binary.LittleEndian.Uint32(data[0:4])
+	if int64(entryLength) > int64(len(data)) {
+		return nil, fmt.Errorf("truncated symbol info (entry length: %d, got: %d)", entryLength, len(data))
+	}
 
-	// Extract type name
-	typeStart := 30 + int(nameLength) + 1 // +1 to skip null terminator after name
-	typeEnd := typeStart + int(typeLength)
+	indexGroup := binary.LittleEndian.Uint32(data[4:8])
+	indexOffset := binary.LittleEndian.Uint32(data[8:12])
+	size := binary.LittleEndian.Uint32(data[12:16])
+	nameLength := binary.LittleEndian.Uint16(data[24:26])
+	typeLength := binary.LittleEndian.Uint16(data[26:28])
 
-	var dataType string
-	if typeEnd <= len(resp.Data) {
-		dataType = string(resp.Data[typeStart:typeEnd])
-		// Remove null terminator if present
-		if idx := 0; idx < len(dataType) && dataType[idx] == 0 {
-			dataType = dataType[:idx]
-		} else {
-			for i := 0; i < len(dataType); i++ {
-				if dataType[i] == 0 {
-					dataType = dataType[:i]
-					break
-				}
-			}
-		}
-	} else {
-		dataType = "UNKNOWN"
+	// the name and the type are followed by a null terminator
+	nameEnd := 30 + int(nameLength)
+	if nameEnd >= len(data) {
+		return nil, fmt.Errorf("invalid symbol info: name length %d exceeds response (length: %d)", nameLength, len(data))
 	}
-
-	symbol := &Symbol{
-		Name:     name,
-		DataType: dataType,
-		Size:     size,
+	typeStart := nameEnd + 1
+	typeEnd := typeStart + int(typeLength)
+	if typeEnd >= len(data) {
+		return nil, fmt.Errorf("invalid symbol info: type length %d exceeds response (length: %d)", typeLength, len(data))
 	}
 
-	return symbol, nil
+	return &Symbol{
+		Name:        name,
+		DataType:    nullTerminatedString(data[typeStart:typeEnd]),
+		Size:        size,
+		IndexGroup:  indexGroup,
+		IndexOffset: indexOffset,
+	}, nil
 }
 
 // GetDataTypeInfo retrieves the field information for a data type
@@ -115,6 +133,9 @@ func (c *Client) GetDataTypeInfo(ctx context.Context, targetAddr, senderAddr ams
 		typeBytes,
 	)
 	resp, err := c.ReadWrite(ctx, req)
+	if errors.Is(err, ams.ADSError(ams.DeviceServiceNotSupported)) {
+		return nil, fmt.Errorf("failed to get data type info: %w", ErrDataTypeInfoUnsupported)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get data type info: %w", err)
 	}
@@ -142,6 +163,11 @@ func (c *Client) GetDataTypeInfo(ctx context.Context, targetAddr, senderAddr ams
 		return nil, fmt.Errorf("response too short for data type info")
 	}
 
+	entryLength := binary.LittleEndian.Uint32(resp.Data[0:4])
+	if int(entryLength) > len(resp.Data) {
+		return nil, fmt.Errorf("truncated data type info (entry length: %d, got: %d)", entryLength, len(resp.Data))
+	}
+
 	subItems := binary.LittleEndian.Uint16(resp.Data[40:42])
 	if subItems == 0 {
 		return nil, nil // No fields (primitive type)
@@ -162,6 +188,12 @@ func (c *Client) GetDataTypeInfo(ctx context.Context, targetAddr, senderAddr ams
 			break
 		}
 
+		// Each sub-item starts with its own entryLength
+		fieldEntryLength := binary.LittleEndian.Uint32(resp.Data[offset : offset+4])
+		if fieldEntryLength < 42 || offset+int(fieldEntryLength) > len(resp.Data) {
+			return nil, fmt.Errorf("invalid data type info for field %d (entry length: %d)", i, fieldEntryLength)
+		}
+
 		// Parse sub-item structure (same as parent)
 		fieldSize := binary.LittleEndian.Uint32(resp.Data[offset+16 : offset+20])
 		fieldOffset := binary.LittleEndian.Uint32(resp.Data[offset+20 : offset+24])
@@ -204,87 +236,168 @@ func (c *Client) GetDataTypeInfo(ctx context.Context, targetAddr, senderAddr ams
 		})
 
 		// Move to next sub-item using entryLength from header
-		entryLength := binary.LittleEndian.Uint32(resp.Data[offset : offset+4])
-		offset += int(entryLength)
+		offset += int(fieldEntryLength)
 	}
 
 	return fields, nil
 }
 
-// FindFieldByPath recursively finds a field by path in the struct hierarchy
+// FindFieldByPath recursively finds a field by path in the struct hierarchy.
+// Path elements can index array fields, e.g. "aItems[2]". Nested fields
+// must be loaded, use Session.FindFieldByPath to resolve them as needed.
 func FindFieldByPath(fields []StructField, path []string) (*StructField, error) {
-	if len(path) == 0 {
-		return nil, fmt.Errorf("empty path")
-	}
+	field, _, err := findField(context.Background(), fields, path, nil)
+	return field, err
+}
 
-	for i := range fields {
-		if fields[i].Name == path[0] {
-			if len(path) == 1 {
-				return &fields[i], nil
-			}
-			// Recurse into nested fields
-			return FindFieldByPath(fields[i].Fields, path[1:])
-		}
+// FindFieldByPathWithOffset recursively finds a field and calculates its absolute offset from the root
+func FindFieldByPathWithOffset(fields []StructField, path []string, baseOffset uint32) (*StructField, uint32, error) {
+	field, offset, err := findField(context.Background(), fields, path, nil)
+	if err != nil {
+		return nil, 0, err
 	}
+	return field, baseOffset + offset, nil
+}
 
-	return nil, fmt.Errorf("field %s not found", path[0])
+// FindNestedField recursively searches for a field by path (e.g., ["stTest", "sTest"])
+// and returns the field and its data within parentData
+func FindNestedField(fields []StructField, fieldPath []string, parentData []byte) (*StructField, []byte, error) {
+	return findNestedField(context.Background(), fields, fieldPath, parentData, nil)
 }
 
-// FindFieldByPathWithOffset recursively finds a field and calculates its absolute offset from the root
-func FindFieldByPathWithOffset(fields []StructField, path []string, baseOffset uint32) (*StructField, uint32, error) {
+func findNestedField(ctx context.Context, fields []StructField, fieldPath []string, parentData []byte, resolve typeResolver) (*StructField, []byte, error) {
+	field, offset, err := findField(ctx, fields, fieldPath, resolve)
+	if err != nil {
+		return nil, nil, err
+	}
+	fieldEnd := int(offset) + int(field.Size)
+	if fieldEnd > len(parentData) {
+		return nil, nil, fmt.Errorf("field %s: data out of range", strings.Join(fieldPath, "."))
+	}
+	return field, parentData[offset:fieldEnd], nil
+}
+
+// findField finds the field at path and its offset from the start of
+// fields. Nested fields which are not loaded are resolved with resolve
+// if it is not nil. Errors contain the full path.
+func findField(ctx context.Context, fields []StructField, path []string, resolve typeResolver) (*StructField, uint32, error) {
 	if len(path) == 0 {
-		return nil, 0, fmt.Errorf("empty path")
+		return nil, 0, fmt.Errorf("empty field path")
 	}
+	fullPath := strings.Join(path, ".")
+
+	var field *StructField
+	var offset uint32
+	for i, elem := range path {
+		if i > 0 {
+			// descend into the previous field
+			if len(field.Fields) == 0 && resolve != nil && !IsPrimitiveType(field.DataType) {
+				resolved, err := resolve(ctx, field.DataType)
+				if err != nil {
+					return nil, 0, fmt.Errorf("field %s: failed to resolve %s: %w", fullPath, strings.Join(path[:i], "."), err)
+				}
+				field.Fields = resolved
+			}
+			if len(field.Fields) == 0 {
+				return nil, 0, fmt.Errorf("field %s: %s is not a struct", fullPath, strings.Join(path[:i], "."))
+			}
+			fields = field.Fields
+		}
+
+		name, index, err := parseFieldIndex(elem)
+		if err != nil {
+			return nil, 0, fmt.Errorf("field %s: %w", fullPath, err)
+		}
 
-	for i := range fields {
-		if fields[i].Name == path[0] {
-			currentOffset := baseOffset + fields[i].Offset
-			if len(path) == 1 {
-				return &fields[i], currentOffset, nil
+		field = nil
+		for j := range fields {
+			if fields[j].Name == name {
+				field = &fields[j]
+				break
 			}
-			// Recurse into nested fields with updated base offset
-			return FindFieldByPathWithOffset(fields[i].Fields, path[1:], currentOffset)
 		}
-	}
+		if field == nil {
+			return nil, 0, fmt.Errorf("field %s not found: no field %s in %s", fullPath, name, parentPath(path[:i]))
+		}
+
+		// copy the field so that resolving its fields
+		// does not change the caller's or a cached slice
+		if i < len(path)-1 || index != nil {
+			f := *field
+			field = &f
+		}
 
-	return nil, 0, fmt.Errorf("field %s not found", path[0])
+		if index != nil {
+			elemField, err := arrayElement(field, elem, index)
+			if err != nil {
+				return nil, 0, fmt.Errorf("field %s: %w", fullPath, err)
+			}
+			field = elemField
+		}
+		offset += field.Offset
+	}
+	return field, offset, nil
 }
 
-// FindNestedField recursively searches for a field by path (e.g., ["stTest", "sTest"])
-func FindNestedField(fields []StructField, fieldPath []string, parentData []byte) (*StructField, []byte, error) {
-	if len(fieldPath) == 0 {
-		return nil, nil, fmt.Errorf("empty field path")
+// parentPath formats the path of the parent of a field for errors
+func parentPath(path []string) string {
+	if len(path) == 0 {
+		return "root"
 	}
+	return strings.Join(path, ".")
+}
 
-	// Find the field in the current level
-	var targetField *StructField
-	for i := range fields {
-		if fields[i].Name == fieldPath[0] {
-			targetField = &fields[i]
-			break
+// parseFieldIndex splits a path element like "aItems[1,2]" into
+// the field name and the indexes. The indexes are nil if there are none.
+func parseFieldIndex(elem string) (string, []int, error) {
+	open := strings.Index(elem, "[")
+	if open < 0 {
+		return elem, nil, nil
+	}
+	if !strings.HasSuffix(elem, "]") {
+		return "", nil, fmt.Errorf("invalid index in %s", elem)
+	}
+	var index []int
+	for _, s := range strings.Split(elem[open+1:len(elem)-1], ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid index in %s", elem)
 		}
+		index = append(index, n)
 	}
+	return elem[:open], index, nil
+}
 
-	if targetField == nil {
-		return nil, nil, fmt.Errorf("field %s not found", fieldPath[0])
+// arrayElement returns the element of an array field at the index.
+// The offset of the element is relative to the parent of the array.
+func arrayElement(field *StructField, name string, index []int) (*StructField, error) {
+	if !IsArrayType(field.DataType) {
+		return nil, fmt.Errorf("%s is not an array", field.Name)
 	}
-
-	// Extract the data for this field
-	fieldEnd := int(targetField.Offset) + int(targetField.Size)
-	if fieldEnd > len(parentData) {
-		return nil, nil, fmt.Errorf("field data out of range")
+	arr, err := ParseArrayType(field.DataType)
+	if err != nil {
+		return nil, err
 	}
-	fieldData := parentData[targetField.Offset:fieldEnd]
-
-	// If this is the final field in the path, return it
-	if len(fieldPath) == 1 {
-		return targetField, fieldData, nil
+	if len(index) != len(arr.Dims) {
+		return nil, fmt.Errorf("%s needs %d indexes", field.Name, len(arr.Dims))
 	}
 
-	// Otherwise, recurse into nested fields
-	if len(targetField.Fields) == 0 {
-		return nil, nil, fmt.Errorf("field %s is not a struct", fieldPath[0])
+	n := 0
+	for i, d := range arr.Dims {
+		if index[i] < d.Lower || index[i] > d.Upper {
+			return nil, fmt.Errorf("index %d of %s out of range [%d..%d]", index[i], field.Name, d.Lower, d.Upper)
+		}
+		n = n*d.Len() + index[i] - d.Lower
 	}
 
-	return FindNestedField(targetField.Fields, fieldPath[1:], fieldData)
+	elemSize, err := arr.elementSize(field.Size)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", field.Name, err)
+	}
+	return &StructField{
+		Name:     name,
+		DataType: arr.ElementType,
+		Offset:   field.Offset + uint32(n)*elemSize,
+		Size:     elemSize,
+	}, nil
 }
@@ -0,0 +1,19 @@
+//go:build go1.18
+// +build go1.18
+
+package goads
+
+import "testing"
+
+func FuzzParseSymbol(f *testing.F) {
+	f.Add(symbolEntry("MAIN.nValue", "DINT", "", 0x4040, 8, 4))
+	f.Add(symbolEntry("MAIN.stValue", "ST_Value", "comment", 0x4040, 0, 32, "unit", "mm"))
+	f.Add(make([]byte, 32))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		symbol, err := parseSymbol("MAIN.nValue", data)
+		if err == nil && len(symbol.DataType) > len(data) {
+			t.Errorf("type %q longer than response of %d bytes", symbol.DataType, len(data))
+		}
+	})
+}
@@ -0,0 +1,288 @@
+package goads
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mrpasztoradam/goads/ams"
+	"github.com/mrpasztoradam/goads/goadstest"
+	"github.com/pascaldekloe/goe/verify"
+)
+
+// dataTypeEntry encodes an ADS data type entry with the sub items.
+func dataTypeEntry(name, dataType string, size, offset uint32, subItems ...[]byte) []byte {
+	b := make([]byte, 42)
+	binary.LittleEndian.PutUint32(b[16:20], size)
+	binary.LittleEndian.PutUint32(b[20:24], offset)
+	binary.LittleEndian.PutUint16(b[32:34], uint16(len(name)))
+	binary.LittleEndian.PutUint16(b[34:36], uint16(len(dataType)))
+	binary.LittleEndian.PutUint16(b[40:42], uint16(len(subItems)))
+	b = append(b, name...)
+	b = append(b, 0)
+	b = append(b, dataType...)
+	b = append(b, 0, 0) // empty comment
+	for _, item := range subItems {
+		b = append(b, item...)
+	}
+	binary.LittleEndian.PutUint32(b[0:4], uint32(len(b)))
+	return b
+}
+
+func TestParseSymbol(t *testing.T) {
+	valid := symbolEntry("MAIN.nValue", "DINT", "", 0x4040, 8, 4)
+	corrupt := func(offset int, v uint16) []byte {
+		b := append([]byte(nil), valid...)
+		binary.LittleEndian.PutUint16(b[offset:], v)
+		return b
+	}
+
+	tests := []struct {
+		name     string
+		data     []byte
+		wantType string
+		wantErr  bool
+	}{
+		{"valid", valid, "DINT", false},
+		{"short", valid[:31], "", true},
+		{"truncated", valid[:len(valid)-4], "", true},
+		{"name length", corrupt(24, 0xFFFF), "", true},
+		{"name to end", corrupt(24, uint16(len(valid)-30)), "", true},
+		{"type length", corrupt(26, 0xFFFF), "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			symbol, err := parseSymbol("MAIN.nValue", tt.data)
+			if got, want := err != nil, tt.wantErr; got != want {
+				t.Fatalf("got error %v want error %v", err, want)
+			}
+			if err == nil {
+				verify.Values(t, "type", symbol.DataType, tt.wantType)
+			}
+		})
+	}
+}
+
+func TestGetDataTypeInfo(t *testing.T) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+
+	// large enough to span multiple TCP segments
+	var items [][]byte
+	var want []StructField
+	for i := 0; i < 100; i++ {
+		name := fmt.Sprintf("nValue%d", i)
+		items = append(items, dataTypeEntry(name, "INT", 2, uint32(2*i)))
+		want = append(want, StructField{Name: name, DataType: "INT", Offset: uint32(2 * i), Size: 2})
+	}
+	large := dataTypeEntry("ST_Large", "", 200, 0, items...)
+
+	truncated := dataTypeEntry("ST_Small", "", 2, 0, dataTypeEntry("a", "INT", 2, 0))
+	truncated = truncated[:len(truncated)-4]
+
+	srv.HandleReadWrite(0xF011, func(req *ams.ReadWriteRequest) ([]byte, uint32) {
+		switch string(req.Data) {
+		case "ST_Large\x00":
+			return large, ams.NoError
+		case "ST_Small\x00":
+			return truncated, ams.NoError
+		default:
+			return nil, ams.DeviceSymbolNotFound
+		}
+	})
+
+	c := &Client{Addr: srv.Addr()}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	sender := ams.MustParseAddr("10.0.0.1.1.1:32000")
+
+	fields, err := c.GetDataTypeInfo(context.Background(), srv.AMSAddr(), sender, "ST_Large")
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "fields", fields, want)
+
+	if _, err := c.GetDataTypeInfo(context.Background(), srv.AMSAddr(), sender, "ST_Small"); err == nil {
+		t.Fatal("want error for truncated data type info")
+	}
+}
+
+func TestDataTypeInfoUnsupported(t *testing.T) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+
+	// the server does not know 0xF011
+	srv.HandleRead(0xF005, func(req *ams.ReadRequest) ([]byte, uint32) {
+		return []byte{1, 0, 2, 0, 0, 0}, ams.NoError
+	})
+
+	c := &Client{Addr: srv.Addr()}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	s := c.NewSession(srv.AMSAddr(), ams.MustParseAddr("10.0.0.1.1.1:32000"))
+	_, err := c.GetDataTypeInfo(context.Background(), srv.AMSAddr(), s.senderAddr, "ST_Pair")
+	if !errors.Is(err, ErrDataTypeInfoUnsupported) {
+		t.Fatalf("got error %v want %v", err, ErrDataTypeInfoUnsupported)
+	}
+
+	s.registry.Set("MAIN.stPair", &SymbolInfo{Name: "MAIN.stPair", DataType: "ST_Pair", Size: 6, Handle: 1})
+	if _, err := s.ReadStruct(context.Background(), "MAIN.stPair"); !errors.Is(err, ErrDataTypeInfoUnsupported) {
+		t.Fatalf("got error %v without layout want %v", err, ErrDataTypeInfoUnsupported)
+	}
+
+	s.SetTypeLayout("ST_Pair", []StructField{
+		{Name: "a", DataType: "INT", Offset: 0, Size: 2},
+		{Name: "b", DataType: "DINT", Offset: 2, Size: 4},
+	})
+	fields, err := s.ReadStruct(context.Background(), "MAIN.stPair")
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "fields", StructToMap(fields), map[string]interface{}{"a": int16(1), "b": int32(2)})
+}
+
+func TestFindFieldByPath(t *testing.T) {
+	// two sibling structs with a field of the same name
+	fields := []StructField{
+		{Name: "stA", DataType: "ST_Value", Offset: 0, Size: 4, Fields: []StructField{
+			{Name: "value", DataType: "DINT", Offset: 0, Size: 4},
+		}},
+		{Name: "stB", DataType: "ST_Value", Offset: 4, Size: 4, Fields: []StructField{
+			{Name: "value", DataType: "DINT", Offset: 0, Size: 4},
+		}},
+		{Name: "aCounts", DataType: "ARRAY [1..3] OF INT", Offset: 8, Size: 6},
+		{Name: "aGrid", DataType: "ARRAY [0..1,0..2] OF BYTE", Offset: 14, Size: 6},
+		{Name: "aHuge", DataType: "ARRAY [0..65535,0..65535] OF INT", Offset: 20, Size: 2},
+		{Name: "aShort", DataType: "ARRAY [0..3] OF INT", Offset: 20, Size: 2},
+	}
+
+	tests := []struct {
+		path       []string
+		name       string
+		offset     uint32
+		size       uint32
+		wantErrMsg string
+	}{
+		{path: []string{"stA", "value"}, name: "value", offset: 0, size: 4},
+		{path: []string{"stB", "value"}, name: "value", offset: 4, size: 4},
+		{path: []string{"aCounts[1]"}, name: "aCounts[1]", offset: 8, size: 2},
+		{path: []string{"aCounts[3]"}, name: "aCounts[3]", offset: 12, size: 2},
+		{path: []string{"aGrid[1,1]"}, name: "aGrid[1,1]", offset: 18, size: 1},
+		{path: []string{"value"}, wantErrMsg: "field value not found: no field value in root"},
+		{path: []string{"stB", "valu"}, wantErrMsg: "field stB.valu not found: no field valu in stB"},
+		{path: []string{"stA", "value", "x"}, wantErrMsg: "field stA.value.x: stA.value is not a struct"},
+		{path: []string{"aCounts[4]"}, wantErrMsg: "field aCounts[4]: index 4 of aCounts out of range [1..3]"},
+		{path: []string{"aGrid[1]"}, wantErrMsg: "field aGrid[1]: aGrid needs 2 indexes"},
+		{path: []string{"stA[0]"}, wantErrMsg: "field stA[0]: stA is not an array"},
+		{path: []string{"aHuge[1,1]"}, wantErrMsg: "field aHuge[1,1]: too many array elements in ARRAY [0..65535,0..65535] OF INT"},
+		{path: []string{"aShort[1]"}, wantErrMsg: "field aShort[1]: aShort: invalid array length 4 for 2 bytes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.path), func(t *testing.T) {
+			f, offset, err := FindFieldByPathWithOffset(fields, tt.path, 0)
+			if tt.wantErrMsg != "" {
+				if err == nil {
+					t.Fatal("want error")
+				}
+				verify.Values(t, "error", err.Error(), tt.wantErrMsg)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			verify.Values(t, "name", f.Name, tt.name)
+			verify.Values(t, "offset", offset, tt.offset)
+			verify.Values(t, "size", f.Size, tt.size)
+		})
+	}
+}
+
+func TestSessionFindNestedField(t *testing.T) {
+	s := (&Client{}).NewSession(ams.MustParseAddr("127.0.0.1.1.1:851"), ams.MustParseAddr("10.0.0.1.1.1:32000"))
+	s.types.Set("ST_Item", &DataTypeInfo{Name: "ST_Item", Fields: []StructField{
+		{Name: "id", DataType: "INT", Offset: 0, Size: 2},
+		{Name: "value", DataType: "INT", Offset: 2, Size: 2},
+	}})
+	s.types.Set("ST_Order", &DataTypeInfo{Name: "ST_Order", Fields: []StructField{
+		{Name: "nCount", DataType: "INT", Offset: 0, Size: 2},
+		{Name: "aItems", DataType: "ARRAY [0..2] OF ST_Item", Offset: 2, Size: 12},
+	}})
+
+	// the root fields do not have their nested fields loaded
+	fields := []StructField{
+		{Name: "stOrder", DataType: "ST_Order", Offset: 0, Size: 14},
+	}
+	data := []byte{3, 0, 1, 0, 10, 0, 2, 0, 20, 0, 3, 0, 30, 0}
+
+	f, b, err := s.FindNestedField(context.Background(), fields, []string{"stOrder", "aItems[1]", "value"}, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "field", f.Name, "value")
+	verify.Values(t, "data", b, []byte{20, 0})
+
+	_, offset, err := s.FindFieldByPath(context.Background(), fields, []string{"stOrder", "aItems[2]", "id"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "offset", offset, uint32(10))
+
+	// the cached types are not modified
+	cached, _ := s.types.Get("ST_Order")
+	verify.Values(t, "cached fields", cached.Fields[1].Fields, []StructField(nil))
+
+	// without a resolver the nested fields are missing
+	if _, _, err := FindNestedField(fields, []string{"stOrder", "nCount"}, data); err == nil {
+		t.Error("want error for unresolved struct")
+	}
+}
+
+func TestFieldOffset(t *testing.T) {
+	s := (&Client{}).NewSession(ams.MustParseAddr("127.0.0.1.1.1:851"), ams.MustParseAddr("10.0.0.1.1.1:32000"))
+	s.registry.Set("MAIN.stOrder", &SymbolInfo{Name: "MAIN.stOrder", DataType: "ST_Order", Size: 14})
+	s.types.Set("ST_Item", &DataTypeInfo{Name: "ST_Item", Fields: []StructField{
+		{Name: "id", DataType: "INT", Offset: 0, Size: 2},
+		{Name: "value", DataType: "REAL", Offset: 2, Size: 4},
+	}})
+	s.types.Set("ST_Order", &DataTypeInfo{Name: "ST_Order", Fields: []StructField{
+		{Name: "nCount", DataType: "INT", Offset: 0, Size: 2},
+		{Name: "aItems", DataType: "ARRAY [1..2] OF ST_Item", Offset: 2, Size: 12},
+	}})
+
+	tests := []struct {
+		path     []string
+		offset   uint32
+		size     uint32
+		dataType string
+		wantErr  bool
+	}{
+		{[]string{"nCount"}, 0, 2, "INT", false},
+		{[]string{"aItems"}, 2, 12, "ARRAY [1..2] OF ST_Item", false},
+		{[]string{"aItems[2]"}, 8, 6, "ST_Item", false},
+		{[]string{"aItems[2]", "value"}, 10, 4, "REAL", false},
+		{[]string{"aItems[3]"}, 0, 0, "", true},
+		{[]string{"nCount", "x"}, 0, 0, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.path, "."), func(t *testing.T) {
+			offset, size, dataType, err := s.FieldOffset(context.Background(), "MAIN.stOrder", tt.path)
+			if got, want := err != nil, tt.wantErr; got != want {
+				t.Fatalf("got error %v want error %v", err, want)
+			}
+			verify.Values(t, "offset", offset, tt.offset)
+			verify.Values(t, "size", size, tt.size)
+			verify.Values(t, "data type", dataType, tt.dataType)
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package goads
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// symbolVersionShutdown limits the time to delete the subscription of
+// WatchSymbolVersion after its context is cancelled.
+const symbolVersionShutdown = 5 * time.Second
+
+// InvalidateSymbols drops the cached symbols, data types and handles,
+// e.g. after a new program was downloaded to the PLC. The handles are
+// not released on the PLC since a download invalidates them anyway.
+// Type layouts set with SetTypeLayout and enum types set with
+// SetEnumType are kept.
+func (s *Session) InvalidateSymbols() {
+	s.registry.Clear()
+	s.types.Clear()
+	s.handles.clear()
+	if c, ok := s.client.(*Client); ok {
+		c.invalidateTarget(s.targetAddr)
+	}
+}
+
+// WatchSymbolVersion subscribes a notification on the symbol version of
+// the PLC, which changes when a program is downloaded. On a change the
+// session calls InvalidateSymbols and then callback with the old and
+// the new version, e.g. to load the symbol table again. The callback
+// runs in its own goroutine and may send requests. The subscription is
+// deleted when ctx is cancelled.
+func (s *Session) WatchSymbolVersion(ctx context.Context, callback func(oldV, newV uint32)) error {
+	version, err := s.readSymbolVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	nm := s.NewNotificationManager()
+	if err := nm.Start(); err != nil {
+		return err
+	}
+
+	// the receive loop must not block, so only the latest version
+	// is kept until the watcher takes it
+	changes := make(chan uint8, 1)
+	attribs := NotificationAttribs{TransMode: TransModeServerOnChange}
+	_, err = nm.SubscribeRaw(ctx,
+		0xF008, // ADSIGRP_SYM_VERSION
+		0x0,
+		1,
+		attribs,
+		func(sample NotificationSample) {
+			if len(sample.Data) < 1 {
+				return
+			}
+			select {
+			case <-changes:
+			default:
+			}
+			changes <- sample.Data[0]
+		},
+	)
+	if err != nil {
+		nm.Stop()
+		return fmt.Errorf("failed to subscribe symbol version: %w", err)
+	}
+
+	go func() {
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), symbolVersionShutdown)
+			defer cancel()
+			nm.Shutdown(shutdownCtx, symbolVersionShutdown)
+		}()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case v := <-changes:
+				if v == version {
+					continue
+				}
+				s.InvalidateSymbols()
+				if callback != nil {
+					callback(uint32(version), uint32(v))
+				}
+				version = v
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,98 @@
+package goads
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mrpasztoradam/goads/ams"
+	"github.com/mrpasztoradam/goads/goadstest"
+	"github.com/pascaldekloe/goe/verify"
+)
+
+func TestWatchSymbolVersion(t *testing.T) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+	srv.HandleRead(0xF008, func(r *ams.ReadRequest) ([]byte, uint32) {
+		return []byte{1}, ams.NoError
+	})
+	deleted := make(chan uint32, 1)
+	srv.Handle(ams.CmdADSDeleteDeviceNotification, func(req goadstest.Packet) goadstest.Packet {
+		h := req.Header()
+		deleted <- req.(*ams.DeleteDeviceNotificationRequest).NotificationHandle
+		return ams.NewDeleteDeviceNotificationResponse(h.Sender, h.Target, ams.NoError)
+	})
+
+	c := &Client{Addr: srv.Addr()}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	s := c.NewSession(srv.AMSAddr(), ams.MustParseAddr("10.0.0.1.1.1:32000"))
+	s.registry.Set("MAIN.x", &SymbolInfo{Name: "MAIN.x", DataType: "INT", Size: 2, Handle: 7})
+	s.types.Set("ST_Foo", &DataTypeInfo{Name: "ST_Foo"})
+
+	type change struct{ old, new uint32 }
+	changes := make(chan change, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err := s.WatchSymbolVersion(ctx, func(oldV, newV uint32) {
+		changes <- change{oldV, newV}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	notify := func(version byte) (change, bool) {
+		stamp := ams.NotificationStamp{Samples: []ams.NotificationSample{{Handle: 1, Size: 1, Data: []byte{version}}}}
+		if err := srv.Notify(stamp); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case ch := <-changes:
+			return ch, true
+		case <-time.After(100 * time.Millisecond):
+			return change{}, false
+		}
+	}
+
+	// the initial notification has the current version
+	if _, ok := notify(1); ok {
+		t.Fatal("callback called without a version change")
+	}
+	verify.Values(t, "symbols before change", s.registry.Count(), 1)
+
+	ch, ok := notify(2)
+	if !ok {
+		t.Fatal("callback not called on version change")
+	}
+	verify.Values(t, "change", ch, change{1, 2})
+	verify.Values(t, "symbols", s.registry.Count(), 0)
+	verify.Values(t, "types", s.types.Count(), 0)
+
+	cancel()
+	select {
+	case h := <-deleted:
+		verify.Values(t, "deleted handle", h, uint32(1))
+	case <-time.After(time.Second):
+		t.Fatal("subscription not deleted")
+	}
+}
+
+func TestInvalidateSymbolsKeepsUserTypes(t *testing.T) {
+	s := NewSessionWithClient(&fakeClient{}, ams.MustParseAddr("127.0.0.1.1.1:851"), ams.MustParseAddr("10.0.0.1.1.1:32000"))
+	s.SetEnumType("E_State", "", map[string]int64{"eIdle": 0, "eRunning": 2})
+	s.SetTypeLayout("ST_Pos", []StructField{{Name: "X", DataType: "INT", Size: 2}})
+	s.types.Set("ST_Foo", &DataTypeInfo{Name: "ST_Foo"})
+
+	s.InvalidateSymbols()
+	verify.Values(t, "types", s.types.Count(), 0)
+	data, err := s.EncodeValue(context.Background(), "eRunning", "E_State", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "enum", data, []byte{2, 0})
+	if _, ok := s.layouts.Get("ST_Pos"); !ok {
+		t.Error("layout dropped")
+	}
+}
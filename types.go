@@ -0,0 +1,135 @@
+package goads
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// DataTypeInfo contains cached information about a PLC data type.
+// Enums have the values of their members by name and a base type.
+type DataTypeInfo struct {
+	Name     string           `json:"name"`
+	Fields   []StructField    `json:"fields,omitempty"`
+	BaseType string           `json:"baseType,omitempty"`
+	Enum     map[string]int64 `json:"enum,omitempty"`
+}
+
+// IsComposite returns true if the data type has sub items
+func (t *DataTypeInfo) IsComposite() bool {
+	return len(t.Fields) > 0
+}
+
+// TypeRegistry holds cached data type information
+type TypeRegistry struct {
+	types map[string]*DataTypeInfo
+	mu    sync.RWMutex
+}
+
+// NewTypeRegistry creates a new data type registry
+func NewTypeRegistry() *TypeRegistry {
+	return &TypeRegistry{
+		types: make(map[string]*DataTypeInfo),
+	}
+}
+
+// Get retrieves a data type from the registry
+func (r *TypeRegistry) Get(name string) (*DataTypeInfo, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	info, ok := r.types[name]
+	return info, ok
+}
+
+// Set adds or updates a data type in the registry
+func (r *TypeRegistry) Set(name string, info *DataTypeInfo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.types[name] = info
+}
+
+// Clear removes all data types
+func (r *TypeRegistry) Clear() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.types = make(map[string]*DataTypeInfo)
+}
+
+// Count returns the number of cached data types
+func (r *TypeRegistry) Count() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.types)
+}
+
+// typeResolver returns the fields of a data type.
+// Primitive types have no fields.
+type typeResolver func(ctx context.Context, typeName string) ([]StructField, error)
+
+// primitiveTypes are the IEC 61131-3 types that DecodeFieldValue handles
+var primitiveTypes = map[string]bool{
+	"BOOL":  true,
+	"SINT":  true,
+	"USINT": true,
+	"BYTE":  true,
+	"INT":   true,
+	"UINT":  true,
+	"WORD":  true,
+	"DINT":  true,
+	"UDINT": true,
+	"DWORD": true,
+	"LINT":  true,
+	"ULINT": true,
+	"LWORD": true,
+	"REAL":  true,
+	"LREAL": true,
+	"LTIME": true,
+	"LDT":   true,
+	"LDATE": true,
+	"LTOD":  true,
+}
+
+// IsPrimitiveType returns true if the data type is a built-in type
+// which can never have sub items.
+func IsPrimitiveType(dataType string) bool {
+	if primitiveTypes[dataType] {
+		return true
+	}
+	return strings.HasPrefix(dataType, "STRING") || strings.HasPrefix(dataType, "WSTRING")
+}
+
+// SetTypeLayout sets the fields of a data type which are used when the
+// PLC does not support the data type upload, i.e. GetDataTypeInfo fails
+// with ErrDataTypeInfoUnsupported. The offsets are relative to the start
+// of the type and can be computed with AlignFields. Nested struct types
+// need their own layout.
+func (s *Session) SetTypeLayout(typeName string, fields []StructField) {
+	s.layouts.Set(typeName, &DataTypeInfo{Name: typeName, Fields: fields})
+}
+
+// resolveType gets the fields of a data type, using cache if available
+func (s *Session) resolveType(ctx context.Context, typeName string) ([]StructField, error) {
+	if info, ok := s.types.Get(typeName); ok {
+		return info.Fields, nil
+	}
+
+	fields, err := s.client.GetDataTypeInfo(ctx, s.targetAddr, s.senderAddr, typeName)
+	if errors.Is(err, ErrDataTypeInfoUnsupported) {
+		layout, ok := s.layouts.Get(typeName)
+		if !ok {
+			return nil, fmt.Errorf("no field layout for %s: %w", typeName, err)
+		}
+		fields, err = layout.Fields, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.types.Set(typeName, &DataTypeInfo{
+		Name:   typeName,
+		Fields: fields,
+	})
+	return fields, nil
+}
@@ -0,0 +1,99 @@
+package goads
+
+import (
+	"fmt"
+	"math"
+)
+
+// Value is a decoded PLC value which knows its data type. The typed
+// accessors convert between compatible Go types and report whether
+// the conversion was possible without loss.
+type Value struct {
+	DataType string      // PLC data type, e.g. "INT"
+	Raw      []byte      // Data as read from the PLC
+	Decoded  interface{} // Result of DecodeFieldValue
+}
+
+// DecodeValue decodes data of the PLC data type like DecodeFieldValue
+// and returns it together with its data type and the raw bytes.
+func DecodeValue(data []byte, dataType string) Value {
+	return Value{
+		DataType: dataType,
+		Raw:      data,
+		Decoded:  DecodeFieldValue(data, dataType),
+	}
+}
+
+// Int64 returns the value as int64 if it is an integer which fits.
+func (v Value) Int64() (int64, bool) {
+	switch x := v.Decoded.(type) {
+	case int8:
+		return int64(x), true
+	case int16:
+		return int64(x), true
+	case int32:
+		return int64(x), true
+	case int64:
+		return x, true
+	case uint8:
+		return int64(x), true
+	case uint16:
+		return int64(x), true
+	case uint32:
+		return int64(x), true
+	case uint64:
+		if x > math.MaxInt64 {
+			return 0, false
+		}
+		return int64(x), true
+	}
+	return 0, false
+}
+
+// Uint64 returns the value as uint64 if it is a non-negative integer.
+func (v Value) Uint64() (uint64, bool) {
+	if x, ok := v.Decoded.(uint64); ok {
+		return x, true
+	}
+	n, ok := v.Int64()
+	if !ok || n < 0 {
+		return 0, false
+	}
+	return uint64(n), true
+}
+
+// Float64 returns the value as float64 if it is a number. Integers
+// are converted and may lose precision above 2^53.
+func (v Value) Float64() (float64, bool) {
+	switch x := v.Decoded.(type) {
+	case float32:
+		return float64(x), true
+	case float64:
+		return x, true
+	case uint64:
+		return float64(x), true
+	}
+	n, ok := v.Int64()
+	return float64(n), ok
+}
+
+// Bool returns the value if it is a BOOL.
+func (v Value) Bool() (bool, bool) {
+	b, ok := v.Decoded.(bool)
+	return b, ok
+}
+
+// AsString returns the value if it is a string.
+func (v Value) AsString() (string, bool) {
+	s, ok := v.Decoded.(string)
+	if !ok || !IsPrimitiveType(v.DataType) {
+		// unknown types are decoded as hex strings
+		return "", false
+	}
+	return s, true
+}
+
+// String formats the decoded value like fmt.Sprint, e.g. for logging.
+func (v Value) String() string {
+	return fmt.Sprint(v.Decoded)
+}
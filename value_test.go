@@ -0,0 +1,55 @@
+package goads
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/pascaldekloe/goe/verify"
+)
+
+func TestValue(t *testing.T) {
+	tests := []struct {
+		dataType string
+		data     []byte
+
+		i64     int64
+		i64OK   bool
+		u64     uint64
+		u64OK   bool
+		f64     float64
+		f64OK   bool
+		b       bool
+		bOK     bool
+		str     string
+		strOK   bool
+		decoded interface{}
+	}{
+		{dataType: "INT", data: []byte{0xff, 0xff}, i64: -1, i64OK: true, f64: -1, f64OK: true, decoded: int16(-1)},
+		{dataType: "UDINT", data: []byte{1, 0, 0, 0}, i64: 1, i64OK: true, u64: 1, u64OK: true, f64: 1, f64OK: true, decoded: uint32(1)},
+		{dataType: "ULINT", data: []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, u64: 1<<64 - 1, u64OK: true, f64: 1 << 64, f64OK: true, decoded: uint64(1<<64 - 1)},
+		{dataType: "REAL", data: []byte{0, 0, 0xc0, 0x3f}, f64: 1.5, f64OK: true, decoded: float32(1.5)},
+		{dataType: "BOOL", data: []byte{1}, b: true, bOK: true, decoded: true},
+		{dataType: "STRING(10)", data: []byte("abc\x00"), str: "abc", strOK: true, decoded: "abc"},
+		{dataType: "ST_Unknown", data: []byte{0xab}, decoded: "AB"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dataType, func(t *testing.T) {
+			v := DecodeValue(tt.data, tt.dataType)
+			verify.Values(t, "data type", v.DataType, tt.dataType)
+			verify.Values(t, "raw", v.Raw, tt.data)
+			verify.Values(t, "decoded", v.Decoded, tt.decoded)
+
+			i64, ok := v.Int64()
+			verify.Values(t, "Int64", []interface{}{i64, ok}, []interface{}{tt.i64, tt.i64OK})
+			u64, ok := v.Uint64()
+			verify.Values(t, "Uint64", []interface{}{u64, ok}, []interface{}{tt.u64, tt.u64OK})
+			f64, ok := v.Float64()
+			verify.Values(t, "Float64", []interface{}{f64, ok}, []interface{}{tt.f64, tt.f64OK})
+			b, ok := v.Bool()
+			verify.Values(t, "Bool", []interface{}{b, ok}, []interface{}{tt.b, tt.bOK})
+			str, ok := v.AsString()
+			verify.Values(t, "AsString", []interface{}{str, ok}, []interface{}{tt.str, tt.strOK})
+			verify.Values(t, "String", v.String(), fmt.Sprint(tt.decoded))
+		})
+	}
+}
@@ -23,19 +23,12 @@ func (c *Client) ReadVariable(ctx context.Context, targetAddr, senderAddr ams.Ad
 	}
 
 	// Read the value using the symbol size
-	req := ams.NewReadRequest(
-		targetAddr,
-		senderAddr,
-		0xF005, // ADSIGRP_SYM_VALBYHND
-		handle,
-		symbol.Size,
-	)
-	resp, err := c.Read(ctx, req)
+	data, err := c.ReadByHandle(ctx, targetAddr, senderAddr, handle, symbol.Size)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to read %s: %w", name, err)
 	}
 
-	return resp.Data, symbol, nil
+	return data, symbol, nil
 }
 
 // WriteVariable writes a variable value to the PLC
@@ -48,18 +41,44 @@ func (c *Client) WriteVariable(ctx context.Context, targetAddr, senderAddr ams.A
 	}
 
 	// Write the value
-	req := ams.NewWriteRequest(
+	if err := c.WriteByHandle(ctx, targetAddr, senderAddr, handle, data); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// ReadByHandle reads size bytes of the variable with the handle, e.g.
+// from GetSymHandleByName, without looking up its symbol. Callers which
+// manage handles themselves use it to skip the session caches.
+func (c *Client) ReadByHandle(ctx context.Context, targetAddr, senderAddr ams.Addr, handle, size uint32) ([]byte, error) {
+	req := ams.NewReadRequest(
 		targetAddr,
 		senderAddr,
 		0xF005, // ADSIGRP_SYM_VALBYHND
 		handle,
-		data,
+		size,
 	)
-	_, err = c.Write(ctx, req)
+	resp, err := c.Read(ctx, req)
 	if err != nil {
-		return fmt.Errorf("failed to write %s: %w", name, err)
+		return nil, err
 	}
+	return resp.Data, nil
+}
 
+// WriteByHandle writes data to the variable with the handle, e.g. from
+// GetSymHandleByName, without looking up its symbol.
+func (c *Client) WriteByHandle(ctx context.Context, targetAddr, senderAddr ams.Addr, handle uint32, data []byte) error {
+	req := ams.NewWriteRequest(
+		targetAddr,
+		senderAddr,
+		0xF005, // ADSIGRP_SYM_VALBYHND
+		handle,
+		data,
+	)
+	if _, err := c.Write(ctx, req); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -99,9 +118,11 @@ func (c *Client) WriteNestedField(ctx context.Context, targetAddr, senderAddr am
 	}
 
 	// Find the target field and calculate absolute offset
-	field, absoluteOffset, err := FindFieldByPathWithOffset(symbol.Fields, fieldPath, 0)
+	field, absoluteOffset, err := findField(ctx, symbol.Fields, fieldPath, func(ctx context.Context, typeName string) ([]StructField, error) {
+		return c.GetDataTypeInfo(ctx, targetAddr, senderAddr, typeName)
+	})
 	if err != nil {
-		return fmt.Errorf("field not found: %w", err)
+		return err
 	}
 
 	// Update the field data in the struct bytes using absolute offset
@@ -132,6 +153,14 @@ func (c *Client) WriteNestedField(ctx context.Context, targetAddr, senderAddr am
 
 // PopulateFieldValues recursively populates field values from raw data
 func PopulateFieldValues(c *Client, ctx context.Context, targetAddr, senderAddr ams.Addr, fields []StructField, data []byte) error {
+	return populateFieldValues(ctx, fields, data, func(ctx context.Context, typeName string) ([]StructField, error) {
+		return c.GetDataTypeInfo(ctx, targetAddr, senderAddr, typeName)
+	})
+}
+
+// populateFieldValues populates field values and uses resolve to find out
+// whether a field is a struct. Primitive types are never resolved.
+func populateFieldValues(ctx context.Context, fields []StructField, data []byte, resolve typeResolver) error {
 	for i := range fields {
 		fieldEnd := int(fields[i].Offset) + int(fields[i].Size)
 		if fieldEnd > len(data) {
@@ -139,12 +168,28 @@ func PopulateFieldValues(c *Client, ctx context.Context, targetAddr, senderAddr
 		}
 		fieldData := data[fields[i].Offset:fieldEnd]
 
+		// Arrays of structs become a slice of field maps
+		if arr, err := ParseArrayType(fields[i].DataType); err == nil && !IsPrimitiveType(arr.ElementType) {
+			if items, err := decodeStructArray(ctx, arr, fieldData, resolve); err == nil {
+				fields[i].Value = items
+				continue
+			}
+		}
+
 		// Check if this field is a struct itself
-		if fields[i].Size > 8 {
-			nestedFields, err := c.GetDataTypeInfo(ctx, targetAddr, senderAddr, fields[i].DataType)
-			if err == nil && len(nestedFields) > 0 {
-				// It's a nested struct - populate its fields recursively
-				if err := PopulateFieldValues(c, ctx, targetAddr, senderAddr, nestedFields, fieldData); err != nil {
+		if !IsPrimitiveType(fields[i].DataType) {
+			nestedFields := fields[i].Fields
+			if len(nestedFields) == 0 {
+				resolved, err := resolve(ctx, fields[i].DataType)
+				if err == nil {
+					nestedFields = resolved
+				}
+			}
+			if len(nestedFields) > 0 {
+				// It's a nested struct - populate a copy of its fields recursively
+				// since the resolved fields may be shared through a cache.
+				nestedFields = append([]StructField(nil), nestedFields...)
+				if err := populateFieldValues(ctx, nestedFields, fieldData, resolve); err != nil {
 					return err
 				}
 				fields[i].Fields = nestedFields
@@ -157,3 +202,45 @@ func PopulateFieldValues(c *Client, ctx context.Context, targetAddr, senderAddr
 	}
 	return nil
 }
+
+// decodeStructArray decodes the elements of an array of structs into
+// field maps. Multi-dimensional arrays are flattened in index order.
+// The stride is the size of the data divided by the number of elements
+// which includes the padding of the elements.
+func decodeStructArray(ctx context.Context, arr *ArrayType, data []byte, resolve typeResolver) ([]map[string]interface{}, error) {
+	fields, err := resolve(ctx, arr.ElementType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get data type info: %w", err)
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("%s is not a struct", arr.ElementType)
+	}
+
+	stride, err := arr.elementSize(uint32(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	items := make([]map[string]interface{}, arr.Len())
+	for i := range items {
+		elem := append([]StructField(nil), fields...)
+		if err := populateFieldValues(ctx, elem, data[uint32(i)*stride:uint32(i+1)*stride], resolve); err != nil {
+			return nil, err
+		}
+		items[i] = StructToMap(elem)
+	}
+	return items, nil
+}
+
+// StructToMap converts populated fields to a nested map by field name.
+// Fields with sub fields become nested maps and all other fields their Value.
+func StructToMap(fields []StructField) map[string]interface{} {
+	m := make(map[string]interface{}, len(fields))
+	for _, f := range fields {
+		if len(f.Fields) > 0 {
+			m[f.Name] = StructToMap(f.Fields)
+			continue
+		}
+		m[f.Name] = f.Value
+	}
+	return m
+}
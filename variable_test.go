@@ -0,0 +1,143 @@
+package goads
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/mrpasztoradam/goads/ams"
+	"github.com/mrpasztoradam/goads/goadstest"
+	"github.com/pascaldekloe/goe/verify"
+)
+
+func TestPopulateFieldValues(t *testing.T) {
+	types := NewTypeRegistry()
+	types.Set("ST_Small", &DataTypeInfo{
+		Name: "ST_Small",
+		Fields: []StructField{
+			{Name: "a", DataType: "INT", Offset: 0, Size: 2},
+			{Name: "b", DataType: "UINT", Offset: 2, Size: 2},
+		},
+	})
+
+	var resolved []string
+	resolve := func(ctx context.Context, typeName string) ([]StructField, error) {
+		resolved = append(resolved, typeName)
+		info, ok := types.Get(typeName)
+		if !ok {
+			return nil, fmt.Errorf("unknown type %s", typeName)
+		}
+		return info.Fields, nil
+	}
+
+	data := make([]byte, 12)
+	binary.LittleEndian.PutUint64(data[0:8], math.Float64bits(1.5))
+	binary.LittleEndian.PutUint16(data[8:10], uint16(0xfffe)) // -2
+	binary.LittleEndian.PutUint16(data[10:12], 7)
+
+	fields := []StructField{
+		{Name: "rValue", DataType: "LREAL", Offset: 0, Size: 8},
+		{Name: "stSmall", DataType: "ST_Small", Offset: 8, Size: 4},
+	}
+	if err := populateFieldValues(context.Background(), fields, data, resolve); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []StructField{
+		{Name: "rValue", DataType: "LREAL", Offset: 0, Size: 8, Value: float64(1.5)},
+		{Name: "stSmall", DataType: "ST_Small", Offset: 8, Size: 4, Fields: []StructField{
+			{Name: "a", DataType: "INT", Offset: 0, Size: 2, Value: int16(-2)},
+			{Name: "b", DataType: "UINT", Offset: 2, Size: 2, Value: uint16(7)},
+		}},
+	}
+	verify.Values(t, "fields", fields, want)
+	verify.Values(t, "resolved", resolved, []string{"ST_Small"})
+
+	// the cached type must not be modified
+	info, _ := types.Get("ST_Small")
+	verify.Values(t, "cached value", info.Fields[0].Value, nil)
+}
+
+func TestStructToMap(t *testing.T) {
+	fields := []StructField{
+		{Name: "bEnable", DataType: "BOOL", Value: true},
+		{Name: "stPos", DataType: "ST_Pos", Fields: []StructField{
+			{Name: "x", DataType: "INT", Value: int16(-2)},
+			{Name: "y", DataType: "INT"},
+		}},
+	}
+	verify.Values(t, "", StructToMap(fields), map[string]interface{}{
+		"bEnable": true,
+		"stPos": map[string]interface{}{
+			"x": int16(-2),
+			"y": nil,
+		},
+	})
+}
+
+func TestDecodeStructArrayLength(t *testing.T) {
+	resolve := func(ctx context.Context, typeName string) ([]StructField, error) {
+		return []StructField{{Name: "n", DataType: "SINT", Size: 1}}, nil
+	}
+	tests := map[string][]ArrayDim{
+		"more elements than bytes": {{0, 9}},
+		"empty":                    {{1, 0}},
+		"overflow":                 {{0, math.MaxInt32}, {0, math.MaxInt32}, {0, 1}},
+	}
+	for name, dims := range tests {
+		t.Run(name, func(t *testing.T) {
+			arr := &ArrayType{Dims: dims, ElementType: "ST_N"}
+			if _, err := decodeStructArray(context.Background(), arr, make([]byte, 8), resolve); err == nil {
+				t.Error("got no error")
+			}
+		})
+	}
+}
+
+func TestReadWriteByHandle(t *testing.T) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+
+	values := map[uint32][]byte{7: {1, 2, 3, 4}}
+	srv.HandleRead(0xF005, func(req *ams.ReadRequest) ([]byte, uint32) {
+		v, ok := values[req.IndexOffset]
+		if !ok {
+			return nil, ams.DeviceInvalidHandle
+		}
+		return v[:req.Length], ams.NoError
+	})
+	srv.HandleWrite(0xF005, func(req *ams.WriteRequest) uint32 {
+		if _, ok := values[req.IndexOffset]; !ok {
+			return ams.DeviceInvalidHandle
+		}
+		values[req.IndexOffset] = req.Data
+		return ams.NoError
+	})
+
+	c := &Client{Addr: srv.Addr()}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ctx := context.Background()
+	sender := ams.MustParseAddr("10.0.0.1.1.1:32000")
+	if err := c.WriteByHandle(ctx, srv.AMSAddr(), sender, 7, []byte{5, 6, 7, 8}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := c.ReadByHandle(ctx, srv.AMSAddr(), sender, 7, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify.Values(t, "data", data, []byte{5, 6, 7, 8})
+
+	if _, err := c.ReadByHandle(ctx, srv.AMSAddr(), sender, 8, 4); !errors.Is(err, ams.ADSError(ams.DeviceInvalidHandle)) {
+		t.Fatalf("got error %v want %v", err, ams.ADSError(ams.DeviceInvalidHandle))
+	}
+	if err := c.WriteByHandle(ctx, srv.AMSAddr(), sender, 8, []byte{1}); !errors.Is(err, ams.ADSError(ams.DeviceInvalidHandle)) {
+		t.Fatalf("got error %v want %v", err, ams.ADSError(ams.DeviceInvalidHandle))
+	}
+}
@@ -0,0 +1,89 @@
+package goads
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+)
+
+// VerifyError is returned by WriteAndVerify when the value read back
+// differs from the written value, e.g. because the PLC clamped it.
+type VerifyError struct {
+	Name     string
+	DataType string
+	Want     []byte // written
+	Got      []byte // read back
+}
+
+func (e *VerifyError) Error() string {
+	if IsPrimitiveType(e.DataType) {
+		want, err1 := DecodeFieldValueStrict(e.Want, e.DataType)
+		got, err2 := DecodeFieldValueStrict(e.Got, e.DataType)
+		if err1 == nil && err2 == nil {
+			return fmt.Sprintf("verify %s: wrote %v, read back %v", e.Name, want, got)
+		}
+	}
+	i := 0
+	for i < len(e.Want) && i < len(e.Got) && e.Want[i] == e.Got[i] {
+		i++
+	}
+	return fmt.Sprintf("verify %s: wrote % x, read back % x (first difference at byte %d)", e.Name, e.Want, e.Got, i)
+}
+
+// SetVerifyEpsilon sets the maximum difference between the written and
+// the read back value of REAL and LREAL variables in WriteAndVerify.
+// The default of zero requires the same value.
+func (s *Session) SetVerifyEpsilon(epsilon float64) {
+	s.mu.Lock()
+	s.verifyEpsilon = math.Abs(epsilon)
+	s.mu.Unlock()
+}
+
+// WriteAndVerify writes a variable and reads it back. It returns a
+// *VerifyError if the value read back differs from data. If data is
+// shorter than the variable only its length is compared.
+func (s *Session) WriteAndVerify(ctx context.Context, name string, data []byte) error {
+	if err := s.Write(ctx, name, data); err != nil {
+		return err
+	}
+	got, info, err := s.Read(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to read back %s: %w", name, err)
+	}
+	if len(got) > len(data) {
+		got = got[:len(data)]
+	}
+
+	s.mu.RLock()
+	epsilon := s.verifyEpsilon
+	s.mu.RUnlock()
+
+	if !sameValue(data, got, info.DataType, epsilon) {
+		return &VerifyError{Name: name, DataType: info.DataType, Want: data, Got: got}
+	}
+	return nil
+}
+
+// sameValue returns whether the encoded values are equal. REAL and
+// LREAL values may differ by epsilon. Equal bytes are the same value,
+// which includes NaN.
+func sameValue(want, got []byte, dataType string, epsilon float64) bool {
+	if bytes.Equal(want, got) {
+		return true
+	}
+	if len(want) != len(got) {
+		return false
+	}
+	switch {
+	case dataType == "REAL" && len(want) == 4:
+		w := math.Float32frombits(ByteOrder.Uint32(want))
+		g := math.Float32frombits(ByteOrder.Uint32(got))
+		return math.Abs(float64(w)-float64(g)) <= epsilon
+	case dataType == "LREAL" && len(want) == 8:
+		w := math.Float64frombits(ByteOrder.Uint64(want))
+		g := math.Float64frombits(ByteOrder.Uint64(got))
+		return math.Abs(w-g) <= epsilon
+	}
+	return false
+}
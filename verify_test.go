@@ -0,0 +1,112 @@
+package goads
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/mrpasztoradam/goads/ams"
+	"github.com/mrpasztoradam/goads/goadstest"
+	"github.com/pascaldekloe/goe/verify"
+)
+
+func TestWriteAndVerify(t *testing.T) {
+	srv := goadstest.NewServer()
+	defer srv.Close()
+
+	memory := make(map[uint32][]byte)
+	srv.HandleWrite(0xF005, func(req *ams.WriteRequest) uint32 {
+		data := append([]byte(nil), req.Data...)
+		// handle 2 clamps the INT value to 100
+		if req.IndexOffset == 2 && ByteOrder.Uint16(data) > 100 {
+			ByteOrder.PutUint16(data, 100)
+		}
+		// handle 3 rounds the REAL value to 1/1024
+		if req.IndexOffset == 3 {
+			f := math.Float32frombits(ByteOrder.Uint32(data))
+			ByteOrder.PutUint32(data, math.Float32bits(float32(math.Round(float64(f)*1024)/1024)))
+		}
+		memory[req.IndexOffset] = data
+		return ams.NoError
+	})
+	srv.HandleRead(0xF005, func(req *ams.ReadRequest) ([]byte, uint32) {
+		return memory[req.IndexOffset], ams.NoError
+	})
+
+	c := &Client{Addr: srv.Addr()}
+	if err := c.Dial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	s := c.NewSession(srv.AMSAddr(), ams.MustParseAddr("10.0.0.1.1.1:32000"))
+	s.registry.Set("MAIN.nValue", &SymbolInfo{Name: "MAIN.nValue", DataType: "INT", Size: 2, Handle: 1})
+	s.registry.Set("MAIN.nLimited", &SymbolInfo{Name: "MAIN.nLimited", DataType: "INT", Size: 2, Handle: 2})
+	s.registry.Set("MAIN.rSetpoint", &SymbolInfo{Name: "MAIN.rSetpoint", DataType: "REAL", Size: 4, Handle: 3})
+
+	ctx := context.Background()
+	realBytes := func(f float32) []byte {
+		b := make([]byte, 4)
+		ByteOrder.PutUint32(b, math.Float32bits(f))
+		return b
+	}
+
+	if err := s.WriteAndVerify(ctx, "MAIN.nValue", []byte{200, 0}); err != nil {
+		t.Error(err)
+	}
+	if err := s.WriteAndVerify(ctx, "MAIN.nLimited", []byte{50, 0}); err != nil {
+		t.Error(err)
+	}
+
+	err := s.WriteAndVerify(ctx, "MAIN.nLimited", []byte{200, 0})
+	var verr *VerifyError
+	if !errors.As(err, &verr) {
+		t.Fatalf("got error %v want VerifyError", err)
+	}
+	verify.Values(t, "read back", verr.Got, []byte{100, 0})
+	verify.Values(t, "message", err.Error(), "verify MAIN.nLimited: wrote 200, read back 100")
+
+	if err := s.WriteAndVerify(ctx, "MAIN.rSetpoint", realBytes(0.1)); !errors.As(err, &verr) {
+		t.Fatalf("got error %v want VerifyError", err)
+	}
+	s.SetVerifyEpsilon(0.001)
+	if err := s.WriteAndVerify(ctx, "MAIN.rSetpoint", realBytes(0.1)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSameValue(t *testing.T) {
+	lrealBytes := func(f float64) []byte {
+		b := make([]byte, 8)
+		ByteOrder.PutUint64(b, math.Float64bits(f))
+		return b
+	}
+	realBytes := func(f float32) []byte {
+		b := make([]byte, 4)
+		ByteOrder.PutUint32(b, math.Float32bits(f))
+		return b
+	}
+
+	tests := []struct {
+		name      string
+		want, got []byte
+		dataType  string
+		same      bool
+	}{
+		{"equal INT", []byte{1, 0}, []byte{1, 0}, "INT", true},
+		{"other INT", []byte{1, 0}, []byte{2, 0}, "INT", false},
+		{"LREAL within epsilon", lrealBytes(1), lrealBytes(1.0005), "LREAL", true},
+		{"LREAL above epsilon", lrealBytes(1), lrealBytes(1.1), "LREAL", false},
+		{"LREAL NaN", lrealBytes(math.NaN()), lrealBytes(math.NaN()), "LREAL", true},
+		{"LREAL NaN and number", lrealBytes(math.NaN()), lrealBytes(1), "LREAL", false},
+		{"REAL NaN", realBytes(float32(math.NaN())), realBytes(float32(math.NaN())), "REAL", true},
+		{"REAL signed zero", realBytes(0), realBytes(float32(math.Copysign(0, -1))), "REAL", true},
+		{"other length", []byte{1, 0}, []byte{1}, "INT", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verify.Values(t, "same", sameValue(tt.want, tt.got, tt.dataType, 0.001), tt.same)
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package goads
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+)
+
+// WatchEvent is emitted by Watch when the value of a variable changes
+type WatchEvent struct {
+	Name string      // Variable name
+	Old  interface{} // Previous decoded value
+	New  interface{} // Current decoded value
+	Time time.Time   // Time the change was detected
+}
+
+// WatchOptions configures the change detection of WatchWithOptions
+type WatchOptions struct {
+	// Epsilon is the absolute difference below which REAL and LREAL
+	// values are considered equal.
+	Epsilon float64
+	// RelEpsilon is the difference relative to the larger magnitude
+	// below which REAL and LREAL values are considered equal.
+	RelEpsilon float64
+}
+
+// equal compares decoded values. Floats are equal if their difference
+// is within one of the tolerances, all other values must match exactly.
+func (o WatchOptions) equal(a, b interface{}) bool {
+	x, okA := toFloat(a)
+	y, okB := toFloat(b)
+	if !okA || !okB {
+		return reflect.DeepEqual(a, b)
+	}
+	if x == y {
+		return true
+	}
+	d := math.Abs(x - y)
+	return d <= o.Epsilon || d <= o.RelEpsilon*math.Max(math.Abs(x), math.Abs(y))
+}
+
+// toFloat returns the value of a float32 or float64
+func toFloat(v interface{}) (float64, bool) {
+	switch f := v.(type) {
+	case float32:
+		return float64(f), true
+	case float64:
+		return f, true
+	}
+	return 0, false
+}
+
+// Watch polls a variable with the given interval and emits an event
+// on the returned channel whenever its decoded value changes. The first
+// read only establishes the initial value. Polling stops and the channel
+// is closed when the context is cancelled.
+//
+// Watch does not depend on ADS notifications and can be used with PLCs
+// which do not support them. Read and decode errors are skipped.
+func (s *Session) Watch(ctx context.Context, name string, interval time.Duration) (<-chan WatchEvent, error) {
+	return s.WatchWithOptions(ctx, name, interval, WatchOptions{})
+}
+
+// WatchWithOptions is like Watch but floats which differ less than the
+// tolerances of opts do not emit an event. The value of the last event
+// is the reference, so slow drift still emits an event eventually.
+func (s *Session) WatchWithOptions(ctx context.Context, name string, interval time.Duration, opts WatchOptions) (<-chan WatchEvent, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("invalid watch interval: %s", interval)
+	}
+
+	// read once to fail early for unknown symbols
+	data, info, err := s.Read(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	old, err := DecodeFieldValueStrict(data, info.DataType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", name, err)
+	}
+
+	ch := make(chan WatchEvent)
+	go func() {
+		defer close(ch)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			data, info, err := s.Read(ctx, name)
+			if err != nil {
+				continue
+			}
+			cur, err := DecodeFieldValueStrict(data, info.DataType)
+			if err != nil {
+				continue
+			}
+			if opts.equal(old, cur) {
+				continue
+			}
+
+			ev := WatchEvent{Name: name, Old: old, New: cur, Time: time.Now()}
+			select {
+			case <-ctx.Done():
+				return
+			case ch <- ev:
+			}
+			old = cur
+		}
+	}()
+
+	return ch, nil
+}
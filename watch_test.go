@@ -0,0 +1,29 @@
+package goads
+
+import "testing"
+
+func TestWatchOptionsEqual(t *testing.T) {
+	tests := []struct {
+		name string
+		opts WatchOptions
+		a, b interface{}
+		want bool
+	}{
+		{"float exact", WatchOptions{}, 1.5, 1.5, true},
+		{"float noise", WatchOptions{}, 1.5, 1.5000001, false},
+		{"absolute", WatchOptions{Epsilon: 1e-3}, 1.5, 1.5000001, true},
+		{"absolute exceeded", WatchOptions{Epsilon: 1e-3}, 1.5, 1.502, false},
+		{"relative", WatchOptions{RelEpsilon: 1e-6}, float32(1000), float32(1000.0001), true},
+		{"relative exceeded", WatchOptions{RelEpsilon: 1e-6}, float32(1000), float32(1000.1), false},
+		{"int exact", WatchOptions{Epsilon: 10}, int16(1), int16(2), false},
+		{"string exact", WatchOptions{Epsilon: 10}, "a", "a", true},
+		{"mixed types", WatchOptions{Epsilon: 10}, 1.0, int16(1), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.opts.equal(tt.a, tt.b); got != tt.want {
+				t.Errorf("equal(%v, %v) = %v want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}